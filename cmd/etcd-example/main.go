@@ -36,7 +36,10 @@ func main() {
 	c.WithAllPlugins(conf.Core)
 
 	l := c.Logger()
-	etcdP := c.MustGet(etcd.Name).(*etcd.Plugin)
+	etcdP, err := core.Get[*etcd.Plugin](c)
+	if err != nil {
+		l.WithError(err).Fatal("etcd plugin not available")
+	}
 
 	c.CLI(func() {
 		l.Debug("started")
@@ -1,15 +1,42 @@
 package aggregate
 
-import "github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+import (
+	"encoding/json"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+)
 
 type (
+	// BlaBlubEvent is blablub@2.0. Version 1.0 had no source.
 	BlaBlubEvent struct {
 		M           eventsourcing.Meta `json:"meta"`
 		FirstValue  string             `json:"first_value"`
 		SecondValue string             `json:"second_value"`
+		Source      string             `json:"source"`
 	}
 )
 
+// Events decodes the stored events of the example aggregates.
+var Events = eventsourcing.NewRegistry()
+
+func init() {
+	if err := Events.Register("blablub", "2.0", eventsourcing.JSONDecoder[BlaBlubEvent]()); err != nil {
+		panic(err)
+	}
+	if err := Events.Upcast("blablub", "1.0", "2.0", upcastBlaBlubV1); err != nil {
+		panic(err)
+	}
+}
+
 func (e *BlaBlubEvent) Meta() eventsourcing.Meta {
 	return e.M
 }
+
+func upcastBlaBlubV1(data []byte) ([]byte, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	payload["source"] = "legacy"
+	return json.Marshal(payload)
+}
@@ -2,51 +2,54 @@ package aggregate
 
 import (
 	"encoding/json"
-	"errors"
 
 	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
 )
 
 type (
 	ExampleAggregate struct {
-		id     eventsourcing.AggregateID
-		events []eventsourcing.Event
+		eventsourcing.BaseAggregate
 
 		First  string
 		Second string
 	}
+
+	exampleSnapshot struct {
+		First  string `json:"first"`
+		Second string `json:"second"`
+	}
 )
 
 func NewExampleAggregate(id eventsourcing.AggregateID) *ExampleAggregate {
 	return &ExampleAggregate{
-		id: id,
+		BaseAggregate: eventsourcing.NewBaseAggregate(id),
 	}
 }
 
-func (a *ExampleAggregate) AggregateID() eventsourcing.AggregateID {
-	return a.id
-}
-
 func (a *ExampleAggregate) On(e eventsourcing.Event) error {
 	switch event := e.(type) {
 	case *BlaBlubEvent:
 		a.First = event.FirstValue
 		a.Second = event.SecondValue
 	}
-	a.events = append(a.events, e)
+	a.Record(e)
 	return nil
 }
 
 func (a *ExampleAggregate) OnRaw(name string, version string, e []byte) error {
-	switch true {
-	case name == "blablub" && version == "1.0":
-		var event *BlaBlubEvent
-		json.Unmarshal(e, &event)
-		return a.On(event)
-	}
-	return errors.New("unknown event type and version")
+	return Events.Apply(a, name, version, e)
 }
 
-func (a *ExampleAggregate) Events() []eventsourcing.Event {
-	return a.events
+func (a *ExampleAggregate) Snapshot() ([]byte, error) {
+	return json.Marshal(exampleSnapshot{First: a.First, Second: a.Second})
+}
+
+func (a *ExampleAggregate) RestoreSnapshot(data []byte) error {
+	var snapshot exampleSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	a.First = snapshot.First
+	a.Second = snapshot.Second
+	return nil
 }
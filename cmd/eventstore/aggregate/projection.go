@@ -0,0 +1,53 @@
+package aggregate
+
+import (
+	"sync"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// BlaBlubCounter counts the blablub events of all example aggregates.
+	BlaBlubCounter struct {
+		l      logrus.FieldLogger
+		mutex  sync.Mutex
+		counts map[eventsourcing.AggregateID]int
+	}
+)
+
+func NewBlaBlubCounter(l logrus.FieldLogger) *BlaBlubCounter {
+	return &BlaBlubCounter{
+		l:      l,
+		counts: map[eventsourcing.AggregateID]int{},
+	}
+}
+
+func (p *BlaBlubCounter) Name() string {
+	return "blablub-counter"
+}
+
+func (p *BlaBlubCounter) Handle(e eventsourcing.RecordedEvent) error {
+	event, err := Events.Decode(e.Name, e.Version, e.Data)
+	if err != nil {
+		return err
+	}
+	if _, isOk := event.(*BlaBlubEvent); !isOk {
+		return nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.counts[e.AggregateID]++
+	p.l.WithField("aggregate", e.AggregateID).WithField("count", p.counts[e.AggregateID]).Debug("blablub counted")
+	return nil
+}
+
+func (p *BlaBlubCounter) Reset() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.counts = map[eventsourcing.AggregateID]int{}
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	"github.com/siklol/zinc/cmd/eventstore/aggregate"
 	"github.com/siklol/zinc/core"
 	"github.com/siklol/zinc/plugins/eventstore"
@@ -16,6 +18,9 @@ type (
 
 	CLIOptions struct {
 		ConfiguratorURL string `short:"u" long:"configurator" description:"Configurator URL" default:"https://config.example.com"`
+		Projection      string `short:"p" long:"projection" description:"Projection to rebuild" default:"blablub-counter"`
+		From            string `long:"from" description:"Storage to migrate from" default:"boltdb"`
+		To              string `long:"to" description:"Storage to migrate to" default:"postgres"`
 	}
 )
 
@@ -32,29 +37,67 @@ func main() {
 
 	l := c.Logger()
 
-	c.CLI(func() {
-		defer func() { c.SendSigIntSignal() }()
-		esP := c.MustGet(eventstore.Name).(*eventstore.Plugin)
+	esP, getErr := core.Get[*eventstore.Plugin](c)
+	if getErr != nil {
+		l.WithError(getErr).Fatal("eventstore plugin not available")
+	}
+	if err := esP.RegisterProjection(aggregate.NewBlaBlubCounter(l)); err != nil {
+		l.WithError(err).Warn("projections not available")
+	}
+
+	c.CLI(map[string]func(){
+		"default": func() {
+			defer func() { c.SendSigIntSignal() }()
+
+			l.Debugf("%s eventstore", esP.Name())
+			store := esP.EventStore()
+
+			a := aggregate.NewExampleAggregate("abcdefg")
+			if err := store.Load(db, a); err != nil {
+				l.WithError(err).Fatal("error loading aggregate")
+			}
+			err(l, a.On(&aggregate.BlaBlubEvent{M: eventsourcing.NewMeta("blablub", "2.0"), FirstValue: "schnippi", SecondValue: "schnapp", Source: "example"}))
+			err(l, a.On(&aggregate.BlaBlubEvent{M: eventsourcing.NewMeta("blablub", "2.0"), FirstValue: "hasdasd", SecondValue: "etzjetzj", Source: "example"}))
+			err(l, a.On(&aggregate.BlaBlubEvent{M: eventsourcing.NewMeta("blablub", "2.0"), FirstValue: "wertwrgqerg", SecondValue: "netznetzn", Source: "example"}))
+
+			if err := store.Persist(db, a, a.Version()); err != nil {
+				l.WithError(err).Fatal("error saving eventstore")
+			}
 
-		l.Debugf("%s eventstore", esP.Name())
-		a := aggregate.NewExampleAggregate("abcdefg")
-		err(l, a.On(&aggregate.BlaBlubEvent{M: eventsourcing.NewMetaWithID("abc-123", "blablub", "1.0"), FirstValue: "schnippi", SecondValue: "schnapp"}))
-		err(l, a.On(&aggregate.BlaBlubEvent{M: eventsourcing.NewMetaWithID("xyz", "blablub", "1.0"), FirstValue: "hasdasd", SecondValue: "etzjetzj"}))
-		err(l, a.On(&aggregate.BlaBlubEvent{M: eventsourcing.NewMetaWithID("5hwrzjedtum", "blablub", "1.0"), FirstValue: "wertwrgqerg", SecondValue: "netznetzn"}))
+			a2 := aggregate.NewExampleAggregate("abcdefg")
+			if err := store.Load(db, a2); err != nil {
+				l.WithError(err).Fatal("error saving eventstore")
+			}
+			l.WithFields(logrus.Fields{"first": a2.First, "second": a2.Second}).Warn("a2 aggregate after loading")
 
-		store := esP.EventStore()
+			l.Info("done")
+		},
+		"rebuild-projection": func() {
+			defer func() { c.SendSigIntSignal() }()
 
-		if err := store.Persist(db, a); err != nil {
-			l.WithError(err).Fatal("error saving eventstore")
-		}
+			if err := esP.RebuildProjection(context.Background(), cliOpts.Projection); err != nil {
+				l.WithError(err).Fatal("error rebuilding projection")
+			}
+			l.WithField("projection", cliOpts.Projection).Info("projection rebuilt")
+		},
+		"migrate-storage": func() {
+			defer func() { c.SendSigIntSignal() }()
 
-		a2 := aggregate.NewExampleAggregate("abcdefg")
-		if err := store.Load(db, a2); err != nil {
-			l.WithError(err).Fatal("error saving eventstore")
-		}
-		l.WithFields(logrus.Fields{"first": a2.First, "second": a2.Second}).Warn("a2 aggregate after loading")
+			report, err := esP.Migrate(context.Background(), cliOpts.From, cliOpts.To, false)
+			if err != nil {
+				l.WithError(err).Fatal("error migrating storage")
+			}
+			l.WithField("report", report).Info("storage migrated")
+		},
+		"verify-storage": func() {
+			defer func() { c.SendSigIntSignal() }()
 
-		l.Info("done")
+			report, err := esP.Migrate(context.Background(), cliOpts.From, cliOpts.To, true)
+			if err != nil {
+				l.WithError(err).Fatal("storages differ")
+			}
+			l.WithField("report", report).Info("storages match")
+		},
 	})
 }
 
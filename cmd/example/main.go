@@ -47,8 +47,14 @@ func main() {
 
 func writeKafka(c *core.Core) {
 	l := c.Logger()
-	k := c.MustGet(kafkaPlugin.Name).(*kafkaPlugin.Plugin)
-	bDB := c.MustGet(boltdb.Name).(*boltdb.Plugin)
+	k, err := core.Get[*kafkaPlugin.Plugin](c)
+	if err != nil {
+		l.WithError(err).Fatal("kafka plugin not available")
+	}
+	bDB, err := core.Get[*boltdb.Plugin](c)
+	if err != nil {
+		l.WithError(err).Fatal("boltdb plugin not available")
+	}
 
 	topic := "events"
 	ticker := time.NewTicker(10 * time.Second)
@@ -94,7 +100,10 @@ func writeKafka(c *core.Core) {
 
 func readKafka(c *core.Core) {
 	l := c.Logger()
-	k := c.MustGet(kafkaPlugin.Name).(*kafkaPlugin.Plugin)
+	k, err := core.Get[*kafkaPlugin.Plugin](c)
+	if err != nil {
+		l.WithError(err).Fatal("kafka plugin not available")
+	}
 
 	ch := make(chan kafkaPlugin.Message, 1024)
 
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/siklol/zinc/plugins"
+)
+
+type (
+	// BootReport holds the boot outcome of every registered plugin. Failed
+	// plugins are registered in a disabled state.
+	BootReport struct {
+		Plugins map[string]PluginBoot `json:"plugins"`
+	}
+
+	PluginBoot struct {
+		Enabled  bool   `json:"enabled"`
+		Optional bool   `json:"optional"`
+		Error    string `json:"error,omitempty"`
+		err      error
+	}
+)
+
+var (
+	ErrPluginBootFailure = errors.New("plugins failed to boot")
+)
+
+func newBootReport() *BootReport {
+	return &BootReport{Plugins: map[string]PluginBoot{}}
+}
+
+func (r *BootReport) add(p plugins.Plugin, optional bool) {
+	pb := PluginBoot{
+		Enabled:  p.IsEnabled(),
+		Optional: optional,
+	}
+	if br, isOk := p.(plugins.BootReporter); isOk && br.BootError() != nil {
+		pb.err = br.BootError()
+		pb.Error = pb.err.Error()
+	}
+
+	r.Plugins[p.Name()] = pb
+}
+
+// Failed returns the names of all plugins whose boot failed, sorted by name.
+func (r *BootReport) Failed() []string {
+	failed := []string{}
+	for name, pb := range r.Plugins {
+		if pb.err != nil {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// Err returns an error listing all failed plugins that are not optional.
+func (r *BootReport) Err() error {
+	msgs := []string{}
+	for _, name := range r.Failed() {
+		pb := r.Plugins[name]
+		if pb.Optional {
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, pb.Error))
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPluginBootFailure, strings.Join(msgs, "; "))
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type failedPlugin struct {
+	*testPlugin
+	err error
+}
+
+func (p *failedPlugin) IsEnabled() bool {
+	return false
+}
+
+func (p *failedPlugin) BootError() error {
+	return p.err
+}
+
+func TestBootReport(t *testing.T) {
+	errBoot := errors.New("connection refused")
+
+	tests := map[string]struct {
+		optional []string
+		failed   []string
+		wantErr  []string
+	}{
+		"none failed":      {},
+		"required failed":  {failed: []string{"db"}, wantErr: []string{"db: connection refused"}},
+		"optional failed":  {optional: []string{"db"}, failed: []string{"db"}},
+		"optional and not": {optional: []string{"db"}, failed: []string{"db", "kafka"}, wantErr: []string{"kafka: connection refused"}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newTestCore(t)
+			c.lifecycle.OptionalPlugins = tt.optional
+			if err := c.TryRegister(&testPlugin{name: "rest"}); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.failed {
+				if err := c.TryRegister(&failedPlugin{testPlugin: &testPlugin{name: name}, err: errBoot}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := c.BootReport()
+			if fmt.Sprint(r.Failed()) != fmt.Sprint(append([]string{}, tt.failed...)) {
+				t.Fatalf("got failed %v, want %v", r.Failed(), tt.failed)
+			}
+			for _, name := range tt.failed {
+				if pb := r.Plugins[name]; pb.Enabled || pb.Error != errBoot.Error() {
+					t.Fatalf("got %s %+v", name, pb)
+				}
+			}
+
+			err := r.Err()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("got error %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrPluginBootFailure) || !strings.Contains(err.Error(), strings.Join(tt.wantErr, "; ")) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
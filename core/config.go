@@ -0,0 +1,227 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/creasty/defaults"
+	"github.com/siklol/zinc/plugins"
+)
+
+const (
+	LayerDefaults          = "defaults"
+	LayerYaml              = "yaml"
+	LayerConfigurator      = "configurator"
+	LayerKafkaConfigurator = "kafkaconfigurator"
+	LayerEnv               = "env"
+	LayerFlags             = "flags"
+)
+
+type (
+	// ConfigLayer is one source of the layered config. Load applies the
+	// values of the source on top of conf. Layers are applied in the order
+	// they are passed to LoadLayeredConfig, so later layers win.
+	ConfigLayer struct {
+		Name string
+		Load func(c *Core, conf interface{}) error
+	}
+
+	// ConfigSources maps the yaml path of every config value to the name of
+	// the layer that set it last. Values no layer touched are missing.
+	ConfigSources map[string]string
+)
+
+var (
+	ErrInvalidFlagOverride = errors.New("flag override must look like path=value")
+)
+
+// DefaultsLayer applies the default struct tags.
+func DefaultsLayer() ConfigLayer {
+	return ConfigLayer{Name: LayerDefaults, Load: func(c *Core, conf interface{}) error {
+		return defaults.Set(conf)
+	}}
+}
+
+// YamlLayer decodes files in order. Files that do not exist are skipped.
+func YamlLayer(files ...string) ConfigLayer {
+	return ConfigLayer{Name: LayerYaml, Load: func(c *Core, conf interface{}) error {
+		for _, file := range files {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+				c.Logger().WithField("yaml", file).Debug("yaml config not found. skipping")
+				continue
+			}
+			if err := c.yl.DecodeYamlConfig(file, conf); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+		}
+		return nil
+	}}
+}
+
+// ConfiguratorLayer fetches the config of service from the configurator. The
+// layer is skipped if service or url is empty.
+func ConfiguratorLayer(service string, url string) ConfigLayer {
+	return ConfigLayer{Name: LayerConfigurator, Load: func(c *Core, conf interface{}) error {
+		if service == "" || url == "" {
+			return nil
+		}
+		return c.cl.DecodeConfig(url, service, conf)
+	}}
+}
+
+// KafkaConfiguratorLayer decodes the latest json config published to topic.
+// Every later message rebuilds all layers and reloads the plugins. The layer
+// is skipped if brokers is empty.
+func KafkaConfiguratorLayer(brokers string, topic string) ConfigLayer {
+	var (
+		mutex      sync.Mutex
+		latest     string
+		subscribed bool
+	)
+
+	return ConfigLayer{Name: LayerKafkaConfigurator, Load: func(c *Core, conf interface{}) error {
+		if brokers == "" {
+			return nil
+		}
+
+		mutex.Lock()
+		if subscribed {
+			cfg := latest
+			mutex.Unlock()
+			return json.Unmarshal([]byte(cfg), conf)
+		}
+		subscribed = true
+		mutex.Unlock()
+
+		isBooted := false
+		return c.kcl.LoadConfig(brokers, topic, func(cfg string) error {
+			mutex.Lock()
+			latest = cfg
+			mutex.Unlock()
+
+			if !isBooted {
+				isBooted = true
+				return json.Unmarshal([]byte(cfg), conf)
+			}
+			c.rebuildConfig()
+			return nil
+		})
+	}}
+}
+
+// EnvLayer parses the env struct tags.
+func EnvLayer() ConfigLayer {
+	return ConfigLayer{Name: LayerEnv, Load: func(c *Core, conf interface{}) error {
+		return env.Parse(conf)
+	}}
+}
+
+// FlagsLayer applies overrides of the form path=value, e.g.
+// core.rest.port=8080. path is the dot separated yaml path of the field.
+// Slices take comma separated values.
+func FlagsLayer(overrides []string) ConfigLayer {
+	return ConfigLayer{Name: LayerFlags, Load: func(c *Core, conf interface{}) error {
+		for _, o := range overrides {
+			path, value, isOk := strings.Cut(o, "=")
+			if !isOk || path == "" {
+				return fmt.Errorf("%w: %s", ErrInvalidFlagOverride, o)
+			}
+			if err := setConfigPath(conf, strings.TrimSpace(path), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
+
+// BuildConfig applies layers to conf in order and returns which layer set
+// each value. Secret references are resolved after the last layer, see
+// plugins.ResolveSecrets.
+func (c *Core) BuildConfig(conf interface{}, layers ...ConfigLayer) (ConfigSources, error) {
+	l := c.Logger().WithField("component", "BuildConfig")
+
+	if v := reflect.ValueOf(conf); v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, fmt.Errorf("%w: config must be a pointer", ErrUnsupportedConfigKind)
+	}
+
+	sources := ConfigSources{}
+	before := flattenConfig(conf)
+	for _, layer := range layers {
+		if err := layer.Load(c, conf); err != nil {
+			return sources, fmt.Errorf("config layer %s: %w", layer.Name, err)
+		}
+
+		after := flattenConfig(conf)
+		for path, value := range after {
+			if old, isOk := before[path]; !isOk || !reflect.DeepEqual(old, value) {
+				sources[path] = layer.Name
+			}
+		}
+		before = after
+		l.WithField("layer", layer.Name).Trace("config layer applied")
+	}
+
+	if err := plugins.ResolveSecrets(conf); err != nil {
+		return sources, err
+	}
+
+	return sources, nil
+}
+
+// LoadLayeredConfig builds conf from layers, validates it and remembers the
+// source of every value. See ConfigSources.
+func LoadLayeredConfig(layers ...ConfigLayer) Option {
+	return func(c *Core, conf interface{}) error {
+		l := c.Logger().WithField("component", "LoadLayeredConfig")
+
+		sources, err := c.BuildConfig(conf, layers...)
+		if err != nil {
+			l.WithError(err).Fatal("could not build config")
+		}
+
+		if err := ValidateConfig(conf); err != nil {
+			l.WithError(err).Fatal("invalid config")
+		}
+
+		c.configLayers = layers
+		c.appConfig = conf
+		c.configSources = sources
+		c.traceConfig(l, conf)
+		return nil
+	}
+}
+
+// ConfigSources returns the source of every value of the config loaded with
+// LoadLayeredConfig.
+func (c *Core) ConfigSources() ConfigSources {
+	return c.configSources
+}
+
+// rebuildConfig applies all layers to a new config and reloads the plugins
+// with it.
+func (c *Core) rebuildConfig() {
+	l := c.Logger().WithField("component", "LoadLayeredConfig")
+
+	newConf := newConfigLike(c.appConfig)
+	sources, err := c.BuildConfig(newConf, c.configLayers...)
+	if err != nil {
+		l.WithError(err).Warn("could not rebuild config")
+		return
+	}
+	if err := ValidateConfig(newConf); err != nil {
+		l.WithError(err).Warn("invalid config. keeping the current one")
+		return
+	}
+
+	c.configSources = sources
+	c.reloadFrom(newConf)
+}
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/siklol/zinc/plugins/yamlloader"
+	"gopkg.in/yaml.v3"
+)
+
+type layerTestConfig struct {
+	Host    string        `yaml:"host" env:"LAYER_TEST_HOST" default:"localhost"`
+	Port    int           `yaml:"port" env:"LAYER_TEST_PORT" default:"8080"`
+	Timeout time.Duration `yaml:"timeout" default:"1s"`
+	Tags    []string      `yaml:"tags"`
+	Token   string        `yaml:"token" secret:"true"`
+	Section struct {
+		Enable bool   `yaml:"enable"`
+		Mode   string `yaml:"mode" validate:"required,oneof=fast safe"`
+	} `yaml:"section"`
+}
+
+func TestBuildConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("host: yaml\nport: 9090\ntimeout: 5s\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LAYER_TEST_PORT", "7070")
+
+	c := newTestCore(t)
+	c.yl = yamlloader.New().Boot(nil).(*yamlloader.Plugin)
+
+	conf := &layerTestConfig{}
+	sources, err := c.BuildConfig(conf,
+		DefaultsLayer(),
+		YamlLayer(file, filepath.Join(t.TempDir(), "missing.yaml")),
+		EnvLayer(),
+		FlagsLayer([]string{"timeout=10s", "tags=a,b"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Host != "yaml" || conf.Port != 7070 || conf.Timeout != 10*time.Second || len(conf.Tags) != 2 {
+		t.Fatalf("got config %+v", conf)
+	}
+
+	want := ConfigSources{"host": LayerYaml, "port": LayerEnv, "timeout": LayerFlags, "tags": LayerFlags}
+	for path, layer := range want {
+		if sources[path] != layer {
+			t.Errorf("got source %s of %s, want %s", sources[path], path, layer)
+		}
+	}
+	if _, isOk := sources["token"]; isOk {
+		t.Error("untouched value has a source")
+	}
+}
+
+func TestFlagsLayerErrors(t *testing.T) {
+	c := newTestCore(t)
+
+	tests := map[string]error{
+		"port":          ErrInvalidFlagOverride,
+		"=1":            ErrInvalidFlagOverride,
+		"missing.path=": ErrUnknownConfigPath,
+	}
+	for override, want := range tests {
+		_, err := c.BuildConfig(&layerTestConfig{}, FlagsLayer([]string{override}))
+		if !errors.Is(err, want) {
+			t.Errorf("%s: got error %v, want %v", override, err, want)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := map[string]struct {
+		enable bool
+		mode   string
+		want   error
+	}{
+		"disabled section": {enable: false, mode: ""},
+		"valid":            {enable: true, mode: "fast"},
+		"missing":          {enable: true, mode: "", want: ErrConfigRequired},
+		"not allowed":      {enable: true, mode: "slow", want: ErrConfigNotOneOf},
+	}
+
+	for name, tt := range tests {
+		conf := layerTestConfig{}
+		conf.Section.Enable = tt.enable
+		conf.Section.Mode = tt.mode
+
+		err := ValidateConfig(conf)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: got error %v", name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidConfig) || !errors.Is(err, tt.want) {
+			t.Errorf("%s: got error %v, want %v", name, err, tt.want)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	tests := map[string]struct {
+		value interface{}
+		rule  string
+		want  error
+	}{
+		"min int":       {value: 3, rule: "min=1"},
+		"min int fails": {value: 0, rule: "min=1", want: ErrConfigOutOfRange},
+		"max string":    {value: "abcd", rule: "max=3", want: ErrConfigOutOfRange},
+		"max slice":     {value: []string{"a"}, rule: "max=3"},
+		"unknown rule":  {value: 1, rule: "email", want: ErrUnknownRule},
+		"min on bool":   {value: true, rule: "min=1", want: ErrUnknownRule},
+		"empty rule":    {value: "", rule: ""},
+	}
+
+	for name, tt := range tests {
+		err := validateRule(reflect.ValueOf(tt.value), tt.rule)
+		if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("%s: got error %v, want %v", name, err, tt.want)
+		}
+	}
+}
+
+func TestDumpConfigRedactsTaggedFields(t *testing.T) {
+	data, err := DumpConfig(layerTestConfig{Host: "db", Token: "token", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["token"] != redacted || got["host"] != "db" || got["timeout"] != "1s" {
+		t.Fatalf("got dump\n%s", data)
+	}
+
+	// empty secrets stay visible so that missing values can be spotted
+	data, err = DumpConfig(layerTestConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["token"] != "" {
+		t.Fatalf("got empty token %v", got["token"])
+	}
+}
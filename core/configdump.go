@@ -0,0 +1,118 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/siklol/zinc/plugins"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	redacted  = "******"
+	undecoded = "<undecoded>"
+)
+
+// DumpConfig renders conf as yaml. Values of fields tagged with
+// secret:"true" and of fields that held secret references are replaced
+// unless they are empty. Sections in AllPluginConfig.Plugins are rendered
+// with the config type of their registration.
+func DumpConfig(conf interface{}) ([]byte, error) {
+	return yaml.Marshal(redactedValue(reflect.ValueOf(conf), "", false))
+}
+
+// DumpConfig renders the config loaded with LoadLayeredConfig, see DumpConfig.
+func (c *Core) DumpConfig() ([]byte, error) {
+	if c.appConfig == nil {
+		return DumpConfig(c.config)
+	}
+	return DumpConfig(c.appConfig)
+}
+
+func (c *Core) printConfig() {
+	data, err := c.DumpConfig()
+	if err != nil {
+		c.Logger().WithError(err).Error("could not dump config")
+		return
+	}
+	fmt.Print(string(data))
+}
+
+func (c *Core) traceConfig(l *logrus.Entry, conf interface{}) {
+	if !l.Logger.IsLevelEnabled(logrus.TraceLevel) {
+		return
+	}
+
+	data, err := DumpConfig(conf)
+	if err != nil {
+		l.WithError(err).Warn("could not dump config")
+		return
+	}
+	l.Trace(string(data))
+}
+
+func redactedValue(v reflect.Value, key string, isSecret bool) interface{} {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	if isSecret && !v.IsZero() {
+		return redacted
+	}
+
+	switch val := v.Interface().(type) {
+	case string:
+		return val
+	case time.Duration:
+		return val.String()
+	case plugins.RawConfig:
+		r, isRegistered := registrationFor(key)
+		if !isRegistered {
+			return undecoded
+		}
+		conf, err := decodeRawConfig(r, val)
+		if err != nil {
+			return undecoded
+		}
+		return redactedValue(reflect.ValueOf(conf), key, false)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if isConfigLeaf(v.Type()) {
+			return v.Interface()
+		}
+		m := map[string]interface{}{}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			name, isOk := yamlName(f)
+			if !isOk {
+				continue
+			}
+			m[name] = redactedValue(v.Field(i), name, isSecretField(f) || plugins.IsSecretField(v.Type(), f.Name))
+		}
+		return m
+	case reflect.Map:
+		m := map[string]interface{}{}
+		for _, k := range v.MapKeys() {
+			name := fmt.Sprint(k.Interface())
+			m[name] = redactedValue(v.MapIndex(k), name, false)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = redactedValue(v.Index(i), key, false)
+		}
+		return s
+	}
+
+	return v.Interface()
+}
+
+func isSecretField(f reflect.StructField) bool {
+	return strings.EqualFold(f.Tag.Get("secret"), "true")
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/siklol/zinc/plugins"
+	"gopkg.in/yaml.v3"
+)
+
+type dumpTestConfig struct {
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+	Port     string `yaml:"port"`
+}
+
+func TestDumpConfigRedactsReferences(t *testing.T) {
+	t.Setenv("DUMP_TEST_PASSWORD", "postgres")
+
+	conf := dumpTestConfig{Password: "env:DUMP_TEST_PASSWORD", Database: "postgres", Port: "5432"}
+	if err := plugins.ResolveSecrets(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := DumpConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// the database shares the value of the secret but was not a reference
+	want := map[string]string{"password": redacted, "database": "postgres", "port": "5432"}
+	for key, value := range want {
+		if got[key] != value {
+			t.Fatalf("got dump\n%s", strings.TrimSpace(string(data)))
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// configField is a section or value of a config struct addressed by its
+	// yaml path. Field is the struct field declaring the value; for map
+	// entries it is the map field.
+	configField struct {
+		Path  string
+		Field reflect.StructField
+		Value reflect.Value
+	}
+)
+
+var (
+	ErrUnknownConfigPath     = errors.New("unknown config path")
+	ErrUnsupportedConfigKind = errors.New("unsupported config value kind")
+)
+
+// walkConfig calls fn for every field below v in declaration order. If fn
+// returns false the children of that field are skipped.
+func walkConfig(v reflect.Value, path string, fn func(cf configField) bool) {
+	v = indirect(v)
+	if !v.IsValid() || v.Kind() != reflect.Struct || isConfigLeaf(v.Type()) {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, isOk := yamlName(f)
+		if !isOk {
+			continue
+		}
+
+		cf := configField{Path: joinConfigPath(path, name), Field: f, Value: v.Field(i)}
+		if !fn(cf) {
+			continue
+		}
+
+		walkConfigValue(cf, fn)
+	}
+}
+
+func walkConfigValue(cf configField, fn func(cf configField) bool) {
+	v := indirect(cf.Value)
+	if !v.IsValid() {
+		return
+	}
+
+	switch {
+	case v.Kind() == reflect.Struct:
+		walkConfig(v, cf.Path, fn)
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			entry := configField{Path: joinConfigPath(cf.Path, k.String()), Field: cf.Field, Value: v.MapIndex(k)}
+			if fn(entry) {
+				walkConfigValue(entry, fn)
+			}
+		}
+	}
+}
+
+// flattenConfig returns a copy of every leaf value of conf keyed by its path.
+func flattenConfig(conf interface{}) map[string]interface{} {
+	values := map[string]interface{}{}
+	walkConfig(reflect.ValueOf(conf), "", func(cf configField) bool {
+		v := indirect(cf.Value)
+		switch {
+		case !v.IsValid():
+			values[cf.Path] = nil
+		case v.Kind() == reflect.Slice:
+			cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			reflect.Copy(cp, v)
+			values[cf.Path] = cp.Interface()
+		case v.Kind() != reflect.Map && isConfigLeaf(v.Type()):
+			values[cf.Path] = v.Interface()
+		}
+		return true
+	})
+	return values
+}
+
+// setConfigPath parses value and assigns it to the field of conf addressed by
+// the dot separated yaml path.
+func setConfigPath(conf interface{}, path string, value string) error {
+	v := reflect.ValueOf(conf)
+	if v.Kind() != reflect.Ptr {
+		return fmt.Errorf("%w: config must be a pointer", ErrUnsupportedConfigKind)
+	}
+
+	if err := setConfigValue(v.Elem(), strings.Split(path, "."), value); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func setConfigValue(v reflect.Value, segs []string, value string) error {
+	if len(segs) == 0 {
+		return setFromString(v, value)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setConfigValue(v.Elem(), segs, value)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if name, isOk := yamlName(v.Type().Field(i)); isOk && name == segs[0] {
+				return setConfigValue(v.Field(i), segs[1:], value)
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return ErrUnsupportedConfigKind
+		}
+		key := reflect.ValueOf(segs[0]).Convert(v.Type().Key())
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		} else if existing := v.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := setConfigValue(elem, segs[1:], value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	}
+
+	return ErrUnknownConfigPath
+}
+
+func setFromString(v reflect.Value, value string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if k := v.Type().Elem().Kind(); k == reflect.Slice || k == reflect.Struct || k == reflect.Map {
+			return ErrUnsupportedConfigKind
+		}
+		parts := strings.Split(value, ",")
+		s := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setFromString(s.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	default:
+		return ErrUnsupportedConfigKind
+	}
+
+	return nil
+}
+
+func yamlName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+
+	name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return strings.ToLower(f.Name), true
+	}
+	return name, true
+}
+
+// isConfigLeaf reports whether t is a value rather than a config section.
+// Structs without exported fields such as time.Time count as values.
+func isConfigLeaf(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return t.Kind() != reflect.Map
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return false
+		}
+	}
+	return true
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func joinConfigPath(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
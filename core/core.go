@@ -1,10 +1,18 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	"github.com/creasty/defaults"
 	"github.com/denisbrodbeck/machineid"
 	"github.com/siklol/zinc/plugins"
 	"github.com/siklol/zinc/plugins/boltdb"
@@ -13,9 +21,6 @@ import (
 	"github.com/siklol/zinc/plugins/configurator"
 	"github.com/siklol/zinc/plugins/etcd"
 	"github.com/siklol/zinc/plugins/eventstore"
-	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
-	boltdb2 "github.com/siklol/zinc/plugins/eventstore/storage/boltdb"
-	postgres2 "github.com/siklol/zinc/plugins/eventstore/storage/postgres"
 	"github.com/siklol/zinc/plugins/githelper"
 	heartbeat_consumer "github.com/siklol/zinc/plugins/heartbeat-consumer"
 	heartbeat_publisher "github.com/siklol/zinc/plugins/heartbeat-publisher"
@@ -46,6 +51,18 @@ type (
 		kcl             *kafkaconfigurator.Plugin
 		cliD            *clidaemon.Plugin
 		cliShutdownFunc func()
+		lifecycle       LifecycleConfig
+		shutdownReport  *ShutdownReport
+		bootReport      *BootReport
+		ll              *loglevel.Plugin
+		config          *AllPluginConfig
+		appConfig       interface{}
+		configLayers    []ConfigLayer
+		configSources   ConfigSources
+		reloadMutex     sync.Mutex
+		ctx             context.Context
+		cancel          context.CancelFunc
+		shuttingDown    atomic.Bool
 	}
 
 	MinimalPluginConfig struct {
@@ -56,6 +73,7 @@ type (
 
 	AllPluginConfig struct {
 		Home               boot.Config                `yaml:"home" json:"home"`
+		Lifecycle          LifecycleConfig            `yaml:"lifecycle" json:"lifecycle"`
 		BoltDB             boltdb.Config              `yaml:"boltdb" json:"boltDb"`
 		EventStore         eventstore.Config          `yaml:"eventstore" json:"eventstore"`
 		CLIDaemon          clidaemon.Config           `yaml:"cliDaemon" json:"cliDaemon"`
@@ -75,6 +93,9 @@ type (
 		Slack              slack.Config               `yaml:"slack" json:"slack"`
 		Telegram           telegram.Config            `yaml:"telegram" json:"telegram"`
 		Usermanager        usermanager.Config         `yaml:"usermanager" json:"usermanager"`
+		// Plugins holds the config sections of registered plugins that are not
+		// part of this struct, keyed by their config key.
+		Plugins map[string]plugins.RawConfig `yaml:"plugins" json:"plugins"`
 		// TODO enable libp2p when ready
 		// Libp2p             libp2p.Config              `yaml:"libp2p" json:"libp2p"`
 	}
@@ -95,18 +116,25 @@ func NewCore(bootConf interface{}, flags interface{}) *Core {
 	l := logrus.WithField("component", "core")
 
 	c := &Core{
-		bp:      boot.New().Boot(bootConf).(*boot.Plugin),
-		yl:      yamlloader.New().Boot(bootConf).(*yamlloader.Plugin),
-		cl:      configurator.New().Boot(bootConf).(*configurator.Plugin),
-		kcl:     kafkaconfigurator.New().Boot(bootConf).(*kafkaconfigurator.Plugin),
-		cliD:    clidaemon.New().Boot(nil, l).(*clidaemon.Plugin),
-		logger:  l,
-		plugins: map[string]plugins.Plugin{},
+		bp:         boot.New().Boot(bootConf).(*boot.Plugin),
+		yl:         yamlloader.New().Boot(bootConf).(*yamlloader.Plugin),
+		cl:         configurator.New().Boot(bootConf).(*configurator.Plugin),
+		kcl:        kafkaconfigurator.New().Boot(bootConf).(*kafkaconfigurator.Plugin),
+		cliD:       clidaemon.New().Boot(nil, l).(*clidaemon.Plugin),
+		logger:     l,
+		plugins:    map[string]plugins.Plugin{},
+		bootReport: newBootReport(),
 		cliShutdownFunc: func() {
 			l.Debug("shutting down...")
 		},
 	}
 
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	if err := defaults.Set(&c.lifecycle); err != nil {
+		l.WithError(err).Fatal("error setting lifecycle defaults")
+	}
+
 	if _, err := c.cliD.ParseFlags(flags, os.Args); err != nil {
 		l.WithError(err).Fatal("error parsing flags")
 	}
@@ -135,54 +163,53 @@ func (c *Core) WithMinimalPlugins() *Core {
 }
 
 func (c *Core) WithAllPlugins(config AllPluginConfig) *Core {
+	if err := c.BootAllPlugins(config); err != nil {
+		c.Logger().WithError(err).Fatal("error booting plugins")
+	}
+
+	return c
+}
+
+// BootAllPlugins boots and registers every plugin registered via
+// plugins.Register whose config section is enabled. Plugins that fail to boot
+// are registered disabled. The returned error lists all failed plugins
+// that are not configured as optional.
+func (c *Core) BootAllPlugins(config AllPluginConfig) error {
 	c.SetLogLevel(config.Logging)
+	c.lifecycle = config.Lifecycle
 
 	l := c.Logger()
-	c.Register(c.yl, c.cl, c.cliD)
 
-	pr := prometheus.New().Boot(config.Prometheus, l).(*prometheus.Plugin)
-	k := kafka.New().Boot(config.Kafka, l, pr).(*kafka.Plugin)
-	p := postgres.New().Boot(config.Postgres, l).(*postgres.Plugin)
-	n := nats.New().Boot(config.NATS, l).(*nats.Plugin)
-	um := usermanager.New().Boot(config.Usermanager, l, p).(*usermanager.Plugin)
-	es := eventstore.New().Boot(config.EventStore, l).(*eventstore.Plugin)
-	r := rest.New().Boot(config.REST, l).(*rest.Plugin)
-
-	c.Register(boltdb.New().Boot(config.BoltDB, l).(*boltdb.Plugin))
-	c.Register(postgres_crud.New().Boot(config.PostgresCrud, l, p).(*postgres_crud.Plugin))
-	c.Register(heartbeat_consumer.New().Boot(config.HeartbeatConsumer, c.bp.ID, l, n).(*heartbeat_consumer.Plugin))
-	c.Register(heartbeat_publisher.New().Boot(config.HeartbeatPublisher, c.bp.ID, l, n).(*heartbeat_publisher.Plugin))
-	c.Register(telegram.New().Boot(config.Telegram, l, um).(*telegram.Plugin))
-	c.Register(s3file.New().Boot(config.S3File, l).(*s3file.Plugin))
-	c.Register(slack.New().Boot(config.Slack, l).(*slack.Plugin))
-	c.Register(githelper.New().Boot(config.GitHelper, l).(*githelper.Plugin))
-	c.Register(etcd.New().Boot(config.Etcd, l, c.bp.ID).(*etcd.Plugin))
-	c.Register(restjwt.New().Boot(config.RestJWT, l).(*restjwt.Plugin))
-	// c.Register(libp2p.New().Boot(config.Libp2p, l, r.Router()).(*libp2p.Plugin))
-	c.Register(p, pr, n, um, es, r, k)
-
-	if es.IsEnabled() {
-		storages := []eventsourcing.Storage{}
-		if config.EventStore.Storages.Postgres.Enable {
-			storages = append(storages, postgres2.NewPostgresEventStorage(
-				l,
-				config.EventStore.Storages.Postgres,
-				postgres.NewGolangMigrator(l, config.EventStore.Storages.Postgres.MigrationsTable),
-			))
-		}
+	booted, err := c.bootRegistered(config)
+	if err != nil {
+		return err
+	}
 
-		if config.EventStore.Storages.BoltDB.Enable {
-			storages = append(storages, boltdb2.NewBoltDBEventStorage(l, config.EventStore.Storages.BoltDB))
-		}
+	if err := c.TryRegister(append([]plugins.Plugin{c.yl, c.cl, c.cliD}, booted...)...); err != nil {
+		return err
+	}
 
-		es.BootStorage(storages...)
+	if r, isOk := Lookup[*rest.Plugin](c); isOk && r.IsEnabled() {
+		c.MountHealthEndpoints(r.Router())
 	}
 
-	return c
+	c.config = &config
+
+	for _, name := range c.bootReport.Failed() {
+		l.WithField("plugin", name).
+			WithField("optional", c.bootReport.Plugins[name].Optional).
+			Warn("plugin failed to boot and is disabled")
+	}
+
+	return c.bootReport.Err()
 }
 
 func (c *Core) SetLogLevel(conf loglevel.Config) *Core {
-	loglevel.New().Boot(conf).Start()
+	if c.ll != nil {
+		c.ll.Close()
+	}
+	c.ll = loglevel.New().Boot(conf).(*loglevel.Plugin)
+	c.ll.Start()
 	c.logger = logrus.WithField("component", "core")
 	return c
 }
@@ -191,9 +218,14 @@ func (c *Core) MachineID() (string, error) {
 	return machineid.ID()
 }
 
+// BootReport returns the boot outcome of all registered plugins.
+func (c *Core) BootReport() *BootReport {
+	return c.bootReport
+}
+
 func (c *Core) Register(plugins ...plugins.Plugin) *Core {
-	for _, p := range plugins {
-		c.plugins[p.Name()] = p
+	if err := c.TryRegister(plugins...); err != nil {
+		c.logger.WithError(err).Fatal("error registering plugins")
 	}
 
 	return c
@@ -201,7 +233,7 @@ func (c *Core) Register(plugins ...plugins.Plugin) *Core {
 
 func (c *Core) CLI(f any) {
 	l := c.Logger()
-	cli := c.MustGet(clidaemon.Name).(*clidaemon.Plugin)
+	cli := c.cliD
 
 	cliMap := map[string]func(){}
 	switch f.(type) {
@@ -213,6 +245,25 @@ func (c *Core) CLI(f any) {
 		l.Fatal("invalid cli input type given. should be either func() or map[string]func().")
 	}
 
+	for _, p := range c.plugins {
+		cp, isOk := p.(plugins.CLIProvider)
+		if !isOk || !p.IsEnabled() {
+			continue
+		}
+		for arg, cmd := range cp.CLICommands() {
+			if _, isOk := cliMap[arg]; !isOk {
+				cliMap[arg] = c.cliCommand(arg, cmd)
+			}
+		}
+	}
+
+	if _, isOk := cliMap["boot-report"]; !isOk {
+		cliMap["boot-report"] = c.printBootReport
+	}
+	if _, isOk := cliMap["config-dump"]; !isOk {
+		cliMap["config-dump"] = c.printConfig
+	}
+
 	for arg, cliF := range cliMap {
 		cli.Register(arg, cliF)
 	}
@@ -226,13 +277,32 @@ func (c *Core) CLI(f any) {
 	l.Info("exiting")
 }
 
+// cliCommand runs cmd with the positional arguments of the command line.
+func (c *Core) cliCommand(arg string, cmd plugins.CLICommand) func() {
+	return func() {
+		if err := cmd(c.cliD.Args()); err != nil {
+			c.Logger().WithError(err).WithField("command", arg).Error("cli command failed")
+		}
+	}
+}
+
+func (c *Core) printBootReport() {
+	data, err := json.MarshalIndent(c.bootReport, "", "  ")
+	if err != nil {
+		c.Logger().WithError(err).Error("could not marshal boot report")
+		return
+	}
+	fmt.Println(string(data))
+}
+
 func (c *Core) CustomConfig(name string, conf interface{}) {
 	l := c.Logger()
-	if err := c.MustGet(yamlloader.Name).(*yamlloader.Plugin).LoadYamlConfig(name, conf); err != nil {
+	if err := c.yl.LoadYamlConfig(name, conf); err != nil {
 		l.WithError(err).Fatalf("error loading config: %s", name)
 	}
 }
 
+// MustGet exits if no plugin is registered under name. Prefer Get or Lookup.
 func (c *Core) MustGet(name string) plugins.Plugin {
 	if _, isOk := c.plugins[name]; !isOk {
 		c.logger.WithField("name", name).Fatal("core plugin not found or booted")
@@ -244,17 +314,32 @@ func (c *Core) Logger() *logrus.Entry {
 	return c.logger
 }
 
-func (c *Core) StartPlugins() {
-	for pn, p := range c.plugins {
-		go func(name string, cp plugins.Plugin) {
-			if err := cp.Start(); err != nil {
-				c.logger.
-					WithError(err).
-					WithField("component", "start-plugin-"+name).
-					Error("error starting plugin")
-			}
-		}(pn, p)
+func (c *Core) StartPlugins() error {
+	ctx, cancel := withOptionalTimeout(context.Background(), c.lifecycle.StartTimeout)
+	defer cancel()
+
+	return c.StartPluginsContext(ctx)
+}
+
+func (c *Core) StartPluginsContext(ctx context.Context) error {
+	order, err := pluginOrder(c.plugins)
+	if err != nil {
+		c.logger.WithError(err).Error("could not resolve plugin start order")
+		return err
 	}
+
+	for _, name := range order {
+		c.logger.Tracef("starting %s", name)
+		if err := startPlugin(ctx, c.plugins[name]); err != nil {
+			c.logger.
+				WithError(err).
+				WithField("component", "start-plugin-"+name).
+				Error("error starting plugin")
+			return fmt.Errorf("%w: %s: %s", ErrPluginStartFailure, name, err)
+		}
+	}
+
+	return nil
 }
 
 func (c *Core) ID() string {
@@ -265,24 +350,52 @@ func (c *Core) SendSigIntSignal() {
 	c.bp.SignalChan <- syscall.SIGINT
 }
 
-func (c *Core) close() {
+func (c *Core) close() *ShutdownReport {
 	l := c.Logger()
+	start := time.Now()
+	report := &ShutdownReport{Failed: map[string]error{}}
+
+	order, err := pluginOrder(c.plugins)
+	if err != nil {
+		l.WithError(err).Warn("could not resolve plugin close order. closing in name order")
+		order = make([]string, 0, len(c.plugins))
+		for name := range c.plugins {
+			order = append(order, name)
+		}
+		sort.Strings(order)
+	}
 
-	wg := sync.WaitGroup{}
-	wg.Add(len(c.plugins))
-	for pn, p := range c.plugins {
-		go func(name string, cp plugins.Plugin) {
-			l.Tracef("closing %s", name)
-			if err := cp.Close(); err != nil {
-				c.logger.
-					WithError(err).
-					WithField("component", "start-plugin-"+name).
-					Error("error starting plugin")
-			}
-			wg.Done()
-		}(pn, p)
+	ctx, cancel := withOptionalTimeout(context.Background(), c.lifecycle.ShutdownTimeout)
+	defer cancel()
+
+	for i := len(order) - 1; i >= 0; i-- {
+		name := order[i]
+		l.Tracef("closing %s", name)
+
+		pCtx, pCancel := withOptionalTimeout(ctx, c.lifecycle.pluginShutdownTimeout(name))
+		err := closePlugin(pCtx, c.plugins[name])
+		pCancel()
+
+		switch {
+		case errors.Is(err, ErrPluginTimeout), errors.Is(err, context.DeadlineExceeded):
+			report.TimedOut = append(report.TimedOut, name)
+			l.WithError(err).WithField("component", "close-plugin-"+name).Warn("plugin did not close in time")
+		case err != nil:
+			report.Failed[name] = err
+			l.WithError(err).WithField("component", "close-plugin-"+name).Error("error closing plugin")
+		default:
+			report.Closed = append(report.Closed, name)
+		}
 	}
-	wg.Wait()
+
+	report.Duration = time.Since(start)
+	return report
+}
+
+// ShutdownReport returns the result of the last shutdown or nil if core has
+// not been shut down yet.
+func (c *Core) ShutdownReport() *ShutdownReport {
+	return c.shutdownReport
 }
 
 func (c *Core) Shutdown(f func()) {
@@ -292,8 +405,13 @@ func (c *Core) Shutdown(f func()) {
 			l := c.logger.WithField("component", "shutdown")
 			l.Infof("Received %s event...", event)
 
+			c.shuttingDown.Store(true)
+			c.cancel()
 			f()
-			c.close()
+			c.shutdownReport = c.close()
+			if c.shutdownReport.HasTimeouts() {
+				l.WithField("plugins", c.shutdownReport.TimedOut).Warn("plugins failed to stop in time")
+			}
 
 			l.Trace("pre-cleanup shutdown chan")
 			c.bp.CleanupDone <- true
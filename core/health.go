@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/siklol/zinc/plugins"
+)
+
+const (
+	HealthStatusUp       = "up"
+	HealthStatusDown     = "down"
+	HealthStatusDisabled = "disabled"
+)
+
+type (
+	HealthReport struct {
+		Status    string                  `json:"status"`
+		CheckedAt time.Time               `json:"checkedAt"`
+		Plugins   map[string]PluginHealth `json:"plugins"`
+	}
+
+	PluginHealth struct {
+		Status   string        `json:"status"`
+		Error    string        `json:"error,omitempty"`
+		Duration time.Duration `json:"duration"`
+	}
+)
+
+// Health runs the checks of every registered plugin implementing
+// plugins.HealthChecker concurrently. Disabled plugins are reported but never
+// mark the report as down.
+func (c *Core) Health(ctx context.Context) *HealthReport {
+	report := &HealthReport{
+		Status:    HealthStatusUp,
+		CheckedAt: time.Now(),
+		Plugins:   map[string]PluginHealth{},
+	}
+
+	names := make([]string, 0, len(c.plugins))
+	for name, p := range c.plugins {
+		if _, isOk := p.(plugins.HealthChecker); isOk {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	wg.Add(len(names))
+	for _, name := range names {
+		go func(name string, p plugins.Plugin) {
+			defer wg.Done()
+
+			ph := c.checkPlugin(ctx, p)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Plugins[name] = ph
+			if ph.Status == HealthStatusDown {
+				report.Status = HealthStatusDown
+			}
+		}(name, c.plugins[name])
+	}
+	wg.Wait()
+
+	return report
+}
+
+// MountHealthEndpoints adds /healthz (liveness) and /readyz (readiness) to the
+// given router. Liveness only fails while core is shutting down, readiness
+// fails as soon as one enabled plugin reports an unhealthy dependency. Readiness
+// returns the checks of every plugin only if LifecycleConfig.HealthDetails is
+// set, failed checks are logged instead.
+func (c *Core) MountHealthEndpoints(r *echo.Echo) {
+	r.GET("/healthz", c.liveness)
+	r.GET("/readyz", c.readiness)
+}
+
+func (c *Core) checkPlugin(ctx context.Context, p plugins.Plugin) PluginHealth {
+	if !p.IsEnabled() {
+		return PluginHealth{Status: HealthStatusDisabled}
+	}
+
+	ctx, cancel := withOptionalTimeout(ctx, c.lifecycle.HealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runUntilDone(ctx, func() error { return p.(plugins.HealthChecker).Health(ctx) })
+	ph := PluginHealth{Status: HealthStatusUp, Duration: time.Since(start)}
+	if err != nil {
+		ph.Status = HealthStatusDown
+		ph.Error = err.Error()
+	}
+
+	return ph
+}
+
+func (c *Core) liveness(ec echo.Context) error {
+	if c.shuttingDown.Load() {
+		return ec.JSON(http.StatusServiceUnavailable, map[string]string{"status": HealthStatusDown})
+	}
+	return ec.JSON(http.StatusOK, map[string]string{"status": HealthStatusUp})
+}
+
+func (c *Core) readiness(ec echo.Context) error {
+	report := c.Health(ec.Request().Context())
+	if c.shuttingDown.Load() {
+		report.Status = HealthStatusDown
+	}
+
+	code := http.StatusOK
+	if report.Status != HealthStatusUp {
+		code = http.StatusServiceUnavailable
+	}
+	for name, ph := range report.Plugins {
+		if ph.Status == HealthStatusDown {
+			c.Logger().WithField("plugin", name).WithField("error", ph.Error).Warn("plugin not ready")
+		}
+	}
+	if !c.lifecycle.HealthDetails {
+		return ec.JSON(code, map[string]string{"status": report.Status})
+	}
+	return ec.JSON(code, report)
+}
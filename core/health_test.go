@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/siklol/zinc/plugins"
+)
+
+type (
+	healthPlugin struct {
+		*testPlugin
+		err  error
+		wait time.Duration
+	}
+
+	disabledHealthPlugin struct {
+		*healthPlugin
+	}
+)
+
+func (p *healthPlugin) Health(ctx context.Context) error {
+	time.Sleep(p.wait)
+	return p.err
+}
+
+func (p *disabledHealthPlugin) IsEnabled() bool {
+	return false
+}
+
+func TestHealth(t *testing.T) {
+	errDown := errors.New("dial tcp db:5432: connection refused")
+
+	tests := map[string]struct {
+		plugins []plugins.Plugin
+		status  string
+		plugin  map[string]string
+	}{
+		"no checks": {
+			plugins: []plugins.Plugin{&testPlugin{name: "a"}},
+			status:  HealthStatusUp,
+			plugin:  map[string]string{},
+		},
+		"all up": {
+			plugins: []plugins.Plugin{&healthPlugin{testPlugin: &testPlugin{name: "a"}}, &healthPlugin{testPlugin: &testPlugin{name: "b"}}},
+			status:  HealthStatusUp,
+			plugin:  map[string]string{"a": HealthStatusUp, "b": HealthStatusUp},
+		},
+		"one down": {
+			plugins: []plugins.Plugin{&healthPlugin{testPlugin: &testPlugin{name: "a"}}, &healthPlugin{testPlugin: &testPlugin{name: "b"}, err: errDown}},
+			status:  HealthStatusDown,
+			plugin:  map[string]string{"a": HealthStatusUp, "b": HealthStatusDown},
+		},
+		"disabled failing": {
+			plugins: []plugins.Plugin{&disabledHealthPlugin{&healthPlugin{testPlugin: &testPlugin{name: "a"}, err: errDown}}},
+			status:  HealthStatusUp,
+			plugin:  map[string]string{"a": HealthStatusDisabled},
+		},
+		"timeout": {
+			plugins: []plugins.Plugin{&healthPlugin{testPlugin: &testPlugin{name: "a"}, wait: time.Second}},
+			status:  HealthStatusDown,
+			plugin:  map[string]string{"a": HealthStatusDown},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newTestCore(t, tt.plugins...)
+			c.lifecycle.HealthCheckTimeout = 10 * time.Millisecond
+
+			report := c.Health(context.Background())
+			if report.Status != tt.status {
+				t.Fatalf("got status %s, want %s", report.Status, tt.status)
+			}
+			got := map[string]string{}
+			for name, ph := range report.Plugins {
+				got[name] = ph.Status
+			}
+			if len(got) != len(tt.plugin) {
+				t.Fatalf("got plugins %v, want %v", got, tt.plugin)
+			}
+			for name, status := range tt.plugin {
+				if got[name] != status {
+					t.Fatalf("got plugins %v, want %v", got, tt.plugin)
+				}
+			}
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	errDown := errors.New("dial tcp db:5432: connection refused")
+
+	for _, details := range []bool{false, true} {
+		c := newTestCore(t, &healthPlugin{testPlugin: &testPlugin{name: "db"}, err: errDown})
+		c.lifecycle.HealthDetails = details
+		e := echo.New()
+		c.MountHealthEndpoints(e)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("details %t: got code %d", details, rec.Code)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body["status"] != HealthStatusDown {
+			t.Fatalf("details %t: got body %s", details, rec.Body)
+		}
+		if hasError := strings.Contains(rec.Body.String(), "db:5432"); hasError != details {
+			t.Fatalf("details %t: got body %s", details, rec.Body)
+		}
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	c := newTestCore(t)
+	e := echo.New()
+	c.MountHealthEndpoints(e)
+
+	for _, shuttingDown := range []bool{false, true} {
+		c.shuttingDown.Store(shuttingDown)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		want := http.StatusOK
+		if shuttingDown {
+			want = http.StatusServiceUnavailable
+		}
+		if rec.Code != want {
+			t.Fatalf("shutting down %t: got code %d, want %d", shuttingDown, rec.Code, want)
+		}
+	}
+}
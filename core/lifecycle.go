@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/siklol/zinc/plugins"
+)
+
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+type (
+	LifecycleConfig struct {
+		StartTimeout          time.Duration            `env:"CORE_START_TIMEOUT" default:"30s" yaml:"startTimeout" json:"startTimeout"`
+		ShutdownTimeout       time.Duration            `env:"CORE_SHUTDOWN_TIMEOUT" default:"30s" yaml:"shutdownTimeout" json:"shutdownTimeout"`
+		PluginShutdownTimeout time.Duration            `env:"CORE_PLUGIN_SHUTDOWN_TIMEOUT" default:"10s" yaml:"pluginShutdownTimeout" json:"pluginShutdownTimeout"`
+		PluginTimeouts        map[string]time.Duration `yaml:"pluginTimeouts" json:"pluginTimeouts"`
+		HealthCheckTimeout    time.Duration            `env:"CORE_HEALTH_CHECK_TIMEOUT" default:"5s" yaml:"healthCheckTimeout" json:"healthCheckTimeout"`
+		// HealthDetails adds the checks of every plugin to /readyz. Errors
+		// of drivers can contain hosts or DSNs, so only enable it if the
+		// endpoint is not public.
+		HealthDetails   bool     `env:"CORE_HEALTH_DETAILS" default:"false" yaml:"healthDetails" json:"healthDetails"`
+		OptionalPlugins []string `env:"CORE_OPTIONAL_PLUGINS" yaml:"optionalPlugins" json:"optionalPlugins"`
+	}
+
+	// ShutdownReport lists the outcome of closing every registered plugin.
+	ShutdownReport struct {
+		Closed   []string
+		Failed   map[string]error
+		TimedOut []string
+		Duration time.Duration
+	}
+)
+
+var (
+	ErrPluginCycle        = errors.New("plugin dependency cycle")
+	ErrPluginDependency   = errors.New("plugin dependency not registered")
+	ErrPluginStartFailure = errors.New("plugin could not be started")
+	ErrPluginTimeout      = errors.New("plugin did not finish in time")
+)
+
+// pluginOrder returns the registered plugin names so that every plugin comes
+// after all plugins it depends on. Plugins without dependencies keep a stable
+// alphabetical order.
+func pluginOrder(registered map[string]plugins.Plugin) ([]string, error) {
+	names := make([]string, 0, len(registered))
+	deps := map[string][]string{}
+	for name, p := range registered {
+		names = append(names, name)
+		deps[name] = dependenciesOf(p)
+	}
+
+	return dependencyOrder(names, deps)
+}
+
+// dependencyOrder sorts names topologically by deps. Every dependency has to
+// be part of names.
+func dependencyOrder(names []string, deps map[string][]string) ([]string, error) {
+	names = append([]string{}, names...)
+	sort.Strings(names)
+
+	known := map[string]bool{}
+	for _, name := range names {
+		known[name] = true
+	}
+
+	order := make([]string, 0, len(names))
+	state := map[string]int{}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrPluginCycle, strings.Join(append(path, name), " -> "))
+		}
+
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if !known[dep] {
+				return fmt.Errorf("%w: %s depends on %s", ErrPluginDependency, name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+func dependenciesOf(p plugins.Plugin) []string {
+	dp, isOk := p.(plugins.Dependent)
+	if !isOk {
+		return nil
+	}
+
+	deps := append([]string{}, dp.DependsOn()...)
+	sort.Strings(deps)
+	return deps
+}
+
+func (lc LifecycleConfig) isOptional(name string) bool {
+	for _, o := range lc.OptionalPlugins {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (lc LifecycleConfig) pluginShutdownTimeout(name string) time.Duration {
+	if d, isOk := lc.PluginTimeouts[name]; isOk {
+		return d
+	}
+	return lc.PluginShutdownTimeout
+}
+
+// HasTimeouts reports whether any plugin failed to stop before its deadline.
+func (r *ShutdownReport) HasTimeouts() bool {
+	return len(r.TimedOut) > 0
+}
+
+func withOptionalTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func startPlugin(ctx context.Context, p plugins.Plugin) error {
+	if cs, isOk := p.(plugins.ContextStarter); isOk {
+		return runUntilDone(ctx, func() error { return cs.StartContext(ctx) })
+	}
+	return runUntilDone(ctx, p.Start)
+}
+
+func closePlugin(ctx context.Context, p plugins.Plugin) error {
+	if cc, isOk := p.(plugins.ContextCloser); isOk {
+		return runUntilDone(ctx, func() error { return cc.CloseContext(ctx) })
+	}
+	return runUntilDone(ctx, p.Close)
+}
+
+// runUntilDone returns as soon as either f returns or ctx is done. f keeps
+// running in the background when the context expires first.
+func runUntilDone(ctx context.Context, f func() error) error {
+	errC := make(chan error, 1)
+	go func() {
+		errC <- f()
+	}()
+
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %s", ErrPluginTimeout, ctx.Err())
+	}
+}
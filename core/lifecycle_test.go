@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/siklol/zinc/plugins"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// testPlugin records its starts and closes in log.
+	testPlugin struct {
+		name      string
+		deps      []string
+		log       *eventLog
+		startWait time.Duration
+		closeWait time.Duration
+		closeErr  error
+	}
+
+	eventLog struct {
+		mutex  sync.Mutex
+		events []string
+	}
+)
+
+func (p *testPlugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plugin {
+	return p
+}
+
+func (p *testPlugin) Start() error {
+	time.Sleep(p.startWait)
+	p.log.add("start " + p.name)
+	return nil
+}
+
+func (p *testPlugin) Name() string {
+	return p.name
+}
+
+func (p *testPlugin) Close() error {
+	time.Sleep(p.closeWait)
+	p.log.add("close " + p.name)
+	return p.closeErr
+}
+
+func (p *testPlugin) IsEnabled() bool {
+	return true
+}
+
+func (p *testPlugin) DependsOn() []string {
+	return p.deps
+}
+
+func (l *eventLog) add(event string) {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) String() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return fmt.Sprint(l.events)
+}
+
+// newTestCore returns a core without the built-in plugins and flag parsing
+// of NewCore.
+func newTestCore(t *testing.T, ps ...plugins.Plugin) *Core {
+	t.Helper()
+
+	c := &Core{
+		logger:     logrus.WithField("test", t.Name()),
+		plugins:    map[string]plugins.Plugin{},
+		bootReport: newBootReport(),
+	}
+	if err := c.TryRegister(ps...); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDependencyOrder(t *testing.T) {
+	order, err := dependencyOrder([]string{"rest", "kafka", "eventstore", "postgres"}, map[string][]string{
+		"rest":       {"eventstore"},
+		"eventstore": {"kafka", "postgres"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[kafka postgres eventstore rest]"; fmt.Sprint(order) != want {
+		t.Fatalf("got order %v, want %s", order, want)
+	}
+}
+
+func TestDependencyOrderErrors(t *testing.T) {
+	_, err := dependencyOrder([]string{"a", "b"}, map[string][]string{"a": {"b"}, "b": {"a"}})
+	if !errors.Is(err, ErrPluginCycle) {
+		t.Fatalf("got error %v, want %v", err, ErrPluginCycle)
+	}
+
+	_, err = dependencyOrder([]string{"a"}, map[string][]string{"a": {"missing"}})
+	if !errors.Is(err, ErrPluginDependency) {
+		t.Fatalf("got error %v, want %v", err, ErrPluginDependency)
+	}
+}
+
+func TestStartAndCloseInDependencyOrder(t *testing.T) {
+	log := &eventLog{}
+	c := newTestCore(t,
+		&testPlugin{name: "rest", deps: []string{"eventstore"}, log: log},
+		&testPlugin{name: "eventstore", deps: []string{"postgres"}, log: log},
+		&testPlugin{name: "postgres", log: log},
+	)
+
+	if err := c.StartPluginsContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	report := c.close()
+
+	want := "[start postgres start eventstore start rest close rest close eventstore close postgres]"
+	if log.String() != want {
+		t.Fatalf("got %s, want %s", log, want)
+	}
+	if fmt.Sprint(report.Closed) != "[rest eventstore postgres]" || len(report.Failed) > 0 || report.HasTimeouts() {
+		t.Fatalf("got report %+v", report)
+	}
+}
+func TestStartTimeout(t *testing.T) {
+	c := newTestCore(t, &testPlugin{name: "slow", log: &eventLog{}, startWait: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := c.StartPluginsContext(ctx)
+	if !errors.Is(err, ErrPluginStartFailure) {
+		t.Fatalf("got error %v, want %v", err, ErrPluginStartFailure)
+	}
+}
+
+func TestCloseReport(t *testing.T) {
+	errClose := errors.New("close failed")
+	c := newTestCore(t,
+		&testPlugin{name: "ok", log: &eventLog{}},
+		&testPlugin{name: "failing", log: &eventLog{}, closeErr: errClose},
+		&testPlugin{name: "slow", log: &eventLog{}, closeWait: time.Second},
+	)
+	c.lifecycle = LifecycleConfig{
+		PluginShutdownTimeout: time.Second,
+		PluginTimeouts:        map[string]time.Duration{"slow": 10 * time.Millisecond},
+	}
+
+	report := c.close()
+	if fmt.Sprint(report.Closed) != "[ok]" {
+		t.Fatalf("got closed %v", report.Closed)
+	}
+	if !errors.Is(report.Failed["failing"], errClose) {
+		t.Fatalf("got failed %v", report.Failed)
+	}
+	if fmt.Sprint(report.TimedOut) != "[slow]" {
+		t.Fatalf("got timed out %v", report.TimedOut)
+	}
+}
+
+// contextPlugin starts and closes only through the context methods.
+type contextPlugin struct {
+	*testPlugin
+	deadline bool
+}
+
+func (p *contextPlugin) StartContext(ctx context.Context) error {
+	_, p.deadline = ctx.Deadline()
+	p.log.add("start context " + p.name)
+	return nil
+}
+
+func (p *contextPlugin) CloseContext(ctx context.Context) error {
+	p.log.add("close context " + p.name)
+	return nil
+}
+
+func TestContextMethodsPreferred(t *testing.T) {
+	log := &eventLog{}
+	p := &contextPlugin{testPlugin: &testPlugin{name: "ctx", log: log}}
+	c := newTestCore(t, p)
+	c.lifecycle = LifecycleConfig{StartTimeout: time.Second}
+
+	if err := c.StartPlugins(); err != nil {
+		t.Fatal(err)
+	}
+	c.close()
+
+	if want := "[start context ctx close context ctx]"; log.String() != want {
+		t.Fatalf("got %s, want %s", log, want)
+	}
+	if !p.deadline {
+		t.Fatal("start context has no deadline")
+	}
+}
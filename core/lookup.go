@@ -0,0 +1,91 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/siklol/zinc/plugins"
+)
+
+var (
+	ErrPluginNotFound  = errors.New("plugin not found or booted")
+	ErrAmbiguousPlugin = errors.New("more than one plugin matches type")
+	ErrDuplicatePlugin = errors.New("plugin name already registered")
+)
+
+// Lookup returns the registered plugin of type T. T is usually the plugin
+// pointer type, e.g. *kafka.Plugin, but can also be an interface implemented
+// by exactly one registered plugin.
+func Lookup[T any](c *Core) (T, bool) {
+	p, err := Get[T](c)
+	return p, err == nil
+}
+
+// Get works like Lookup but explains why no plugin could be resolved.
+func Get[T any](c *Core) (T, error) {
+	var (
+		found T
+		names []string
+	)
+
+	for name, p := range c.plugins {
+		if tp, isOk := p.(T); isOk {
+			found = tp
+			names = append(names, name)
+		}
+	}
+
+	switch len(names) {
+	case 1:
+		return found, nil
+	case 0:
+		var zero T
+		return zero, fmt.Errorf("%w: %s", ErrPluginNotFound, typeName[T]())
+	default:
+		var zero T
+		sort.Strings(names)
+		return zero, fmt.Errorf("%w: %s: %v", ErrAmbiguousPlugin, typeName[T](), names)
+	}
+}
+
+// GetByName returns the plugin registered under name if it is of type T.
+func GetByName[T any](c *Core, name string) (T, error) {
+	var zero T
+
+	p, isOk := c.plugins[name]
+	if !isOk {
+		return zero, fmt.Errorf("%w: %s", ErrPluginNotFound, name)
+	}
+
+	tp, isOk := p.(T)
+	if !isOk {
+		return zero, fmt.Errorf("%w: %s is %T, not %s", ErrPluginNotFound, name, p, typeName[T]())
+	}
+
+	return tp, nil
+}
+
+// TryRegister registers the given plugins and fails if another plugin with
+// the same name is already registered. Registering the same instance twice is
+// a no-op.
+func (c *Core) TryRegister(ps ...plugins.Plugin) error {
+	for _, p := range ps {
+		if existing, isOk := c.plugins[p.Name()]; isOk {
+			if existing == p {
+				continue
+			}
+			return fmt.Errorf("%w: %s (%T and %T)", ErrDuplicatePlugin, p.Name(), existing, p)
+		}
+
+		c.plugins[p.Name()] = p
+		c.bootReport.add(p, c.lifecycle.isOptional(p.Name()))
+	}
+
+	return nil
+}
+
+func typeName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
+}
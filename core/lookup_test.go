@@ -0,0 +1,61 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/siklol/zinc/plugins"
+)
+
+func TestLookup(t *testing.T) {
+	p := &testPlugin{name: "a"}
+	c := newTestCore(t, p, &failedPlugin{testPlugin: &testPlugin{name: "b"}})
+
+	got, isOk := Lookup[*testPlugin](c)
+	if !isOk || got != p {
+		t.Fatalf("got %v, %t", got, isOk)
+	}
+
+	if _, isOk := Lookup[plugins.BootReporter](c); !isOk {
+		t.Fatal("interface implemented by one plugin not found")
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	c := newTestCore(t, &testPlugin{name: "a"}, &testPlugin{name: "b"}, &failedPlugin{testPlugin: &testPlugin{name: "c"}})
+
+	if _, err := Get[plugins.Dependent](c); !errors.Is(err, ErrAmbiguousPlugin) {
+		t.Fatalf("got error %v, want %v", err, ErrAmbiguousPlugin)
+	}
+	if _, err := Get[plugins.HealthChecker](c); !errors.Is(err, ErrPluginNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrPluginNotFound)
+	}
+}
+
+func TestGetByName(t *testing.T) {
+	p := &testPlugin{name: "a"}
+	c := newTestCore(t, p)
+
+	got, err := GetByName[*testPlugin](c, "a")
+	if err != nil || got != p {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if _, err := GetByName[*failedPlugin](c, "a"); !errors.Is(err, ErrPluginNotFound) {
+		t.Fatalf("got error %v for wrong type, want %v", err, ErrPluginNotFound)
+	}
+	if _, err := GetByName[*testPlugin](c, "missing"); !errors.Is(err, ErrPluginNotFound) {
+		t.Fatalf("got error %v for missing name, want %v", err, ErrPluginNotFound)
+	}
+}
+
+func TestTryRegister(t *testing.T) {
+	p := &testPlugin{name: "a"}
+	c := newTestCore(t, p)
+
+	if err := c.TryRegister(p); err != nil {
+		t.Fatalf("registering the same instance again: %s", err)
+	}
+	if err := c.TryRegister(&testPlugin{name: "a"}); !errors.Is(err, ErrDuplicatePlugin) {
+		t.Fatalf("got error %v, want %v", err, ErrDuplicatePlugin)
+	}
+}
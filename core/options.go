@@ -1,8 +1,12 @@
 package core
 
 import (
+	"os"
+	"time"
+
 	"github.com/caarlos0/env/v6"
 	"github.com/creasty/defaults"
+	"github.com/siklol/zinc/plugins"
 )
 
 type (
@@ -22,11 +26,17 @@ func LoadEnv() Option {
 			l.WithError(err).Fatal("Could not load environment variables")
 		}
 
+		if err := plugins.ResolveSecrets(conf); err != nil {
+			l.WithError(err).Fatal("Could not resolve secrets")
+		}
+
 		l.Trace("finished loading configs from env")
 		return nil
 	}
 }
 
+// LoadConfigFromEnvironment loads either the yaml file or the configurator
+// config, whichever succeeds first. Use LoadLayeredConfig to combine sources.
 func LoadConfigFromEnvironment(yamlFile string, service string, url string) Option {
 	return func(c *Core, conf interface{}) error {
 		l := c.Logger().WithFields(map[string]any{
@@ -39,7 +49,7 @@ func LoadConfigFromEnvironment(yamlFile string, service string, url string) Opti
 		if yamlFile != "" {
 			err := c.yl.LoadYamlConfig(yamlFile, conf)
 			if err == nil {
-				l.Tracef("%#v", conf)
+				c.traceConfig(l, conf)
 				return nil
 			}
 			l.WithError(err).Warn("error loading yaml config")
@@ -48,7 +58,7 @@ func LoadConfigFromEnvironment(yamlFile string, service string, url string) Opti
 		if service != "" && url != "" {
 			err := c.cl.LoadConfig(url, service, conf)
 			if err == nil {
-				l.Tracef("%#v", conf)
+				c.traceConfig(l, conf)
 				return nil
 			}
 			l.WithError(err).Warn("error loading yaml config")
@@ -66,7 +76,7 @@ func LoadOptionalYamlConfig(filename string) Option {
 		if err := c.yl.LoadYamlConfig(filename, conf); err != nil {
 			l.WithError(err).Warn("error loading yaml config. Ignoring...")
 		}
-		l.Tracef("%#v", conf)
+		c.traceConfig(l, conf)
 		return nil
 	}
 }
@@ -78,7 +88,7 @@ func LoadYamlConfig(filename string) Option {
 		if err := c.yl.LoadYamlConfig(filename, conf); err != nil {
 			l.WithError(err).Fatal("error loading yaml config")
 		}
-		l.Tracef("%#v", conf)
+		c.traceConfig(l, conf)
 		return nil
 	}
 }
@@ -95,7 +105,7 @@ func LoadConfigurator(service string, url string) Option {
 		if err := c.cl.LoadConfig(url, service, conf); err != nil {
 			l.WithError(err).Fatal("error loading configurator config")
 		}
-		l.Tracef("%#v", conf)
+		c.traceConfig(l, conf)
 		return nil
 	}
 }
@@ -108,10 +118,21 @@ func LoadKafkaConfigurator(brokers string, topic string, updateFn UpdateFn) Opti
 			panic(err)
 		}
 
-		if err := c.kcl.LoadConfig(brokers, topic, updateFn); err != nil {
+		reloadingFn := func(cfg string) error {
+			if err := updateFn(cfg); err != nil {
+				return err
+			}
+			if err := plugins.ResolveSecrets(conf); err != nil {
+				return err
+			}
+			c.reloadFrom(conf)
+			return nil
+		}
+
+		if err := c.kcl.LoadConfig(brokers, topic, reloadingFn); err != nil {
 			l.WithError(err).Fatal("error loading configurator config")
 		}
-		l.Tracef("%#v", conf)
+		c.traceConfig(l, conf)
 		return nil
 	}
 }
@@ -122,3 +143,68 @@ func CLIShutdownFunc(f func()) Option {
 		return nil
 	}
 }
+
+func Lifecycle(lc LifecycleConfig) Option {
+	return func(c *Core, conf interface{}) error {
+		c.lifecycle = lc
+		return nil
+	}
+}
+
+// WatchYamlConfig reloads the plugins from filename whenever its modification
+// time changes or the process receives SIGHUP.
+func WatchYamlConfig(filename string, interval time.Duration) Option {
+	return func(c *Core, conf interface{}) error {
+		l := c.Logger().WithFields(map[string]any{"component": "WatchYamlConfig", "yaml": filename})
+
+		lastMod := time.Time{}
+		if fi, err := os.Stat(filename); err == nil {
+			lastMod = fi.ModTime()
+		}
+
+		load := func() {
+			newConf := newConfigLike(conf)
+			if err := c.yl.LoadYamlConfig(filename, newConf); err != nil {
+				l.WithError(err).Warn("error loading yaml config")
+				return
+			}
+			c.reloadFrom(newConf)
+		}
+
+		go c.watch(interval, func() {
+			fi, err := os.Stat(filename)
+			if err != nil {
+				l.WithError(err).Warn("could not stat yaml config")
+				return
+			}
+			if fi.ModTime().Equal(lastMod) {
+				return
+			}
+			lastMod = fi.ModTime()
+			load()
+		}, load)
+
+		return nil
+	}
+}
+
+// WatchConfigurator polls the configurator every interval and on SIGHUP and
+// reloads the plugins if their config changed.
+func WatchConfigurator(service string, url string, interval time.Duration) Option {
+	return func(c *Core, conf interface{}) error {
+		l := c.Logger().WithFields(map[string]any{"component": "WatchConfigurator", "service": service})
+
+		load := func() {
+			newConf := newConfigLike(conf)
+			if err := c.cl.LoadConfig(url, service, newConf); err != nil {
+				l.WithError(err).Warn("error loading configurator config")
+				return
+			}
+			c.reloadFrom(newConf)
+		}
+
+		go c.watch(interval, load, load)
+
+		return nil
+	}
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/creasty/defaults"
+	"github.com/siklol/zinc/plugins"
+)
+
+// bootRegistered builds every registered plugin whose config section is
+// present and enabled. Plugins are booted in dependency order so that each
+// Boot receives the already booted plugins matching its declared dependency
+// types.
+func (c *Core) bootRegistered(config AllPluginConfig) ([]plugins.Plugin, error) {
+	l := c.Logger()
+
+	confs := map[string]interface{}{}
+	instances := map[string]plugins.Plugin{}
+	regs := map[string]plugins.Registration{}
+	names := []string{}
+
+	for _, r := range plugins.Registrations() {
+		conf, isPresent, err := pluginConfig(config, r)
+		if err != nil {
+			return nil, fmt.Errorf("config of plugin %s: %w", r.Name, err)
+		}
+		if !isPresent || !isConfigEnabled(conf) {
+			l.WithField("plugin", r.Name).Trace("plugin not configured or enabled. skipping")
+			continue
+		}
+
+		confs[r.Name] = conf
+		instances[r.Name] = r.New()
+		regs[r.Name] = r
+		names = append(names, r.Name)
+	}
+
+	deps := map[string][]string{}
+	for _, name := range names {
+		for _, t := range regs[name].Dependencies {
+			for _, other := range names {
+				if other != name && reflect.TypeOf(instances[other]).AssignableTo(t) {
+					deps[name] = append(deps[name], other)
+				}
+			}
+		}
+	}
+
+	order, err := dependencyOrder(names, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	provided := []interface{}{l, c.bp.ID}
+	booted := make([]plugins.Plugin, 0, len(order))
+	for _, name := range order {
+		resolved := []interface{}{}
+		for _, t := range regs[name].Dependencies {
+			if d := resolveDependency(provided, t); d != nil {
+				resolved = append(resolved, d)
+			}
+		}
+
+		l.WithField("plugin", name).Trace("booting plugin")
+		p := instances[name].Boot(confs[name], resolved...)
+		provided = append(provided, p)
+		booted = append(booted, p)
+	}
+
+	return booted, nil
+}
+
+// pluginConfig returns the config value of r. Built-in plugins read their
+// typed section of AllPluginConfig, all others are decoded from
+// AllPluginConfig.Plugins.
+func pluginConfig(config AllPluginConfig, r plugins.Registration) (interface{}, bool, error) {
+	cv := reflect.ValueOf(config)
+	ct := cv.Type()
+	for i := 0; i < ct.NumField(); i++ {
+		key := strings.Split(ct.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == r.ConfigKey && cv.Field(i).Type() == reflect.TypeOf(r.Config()).Elem() {
+			return cv.Field(i).Interface(), true, nil
+		}
+	}
+
+	raw, isOk := config.Plugins[r.ConfigKey]
+	if !isOk {
+		return nil, false, nil
+	}
+
+	conf, err := decodeRawConfig(r, raw)
+	return conf, true, err
+}
+
+// keepSection returns config with the section of r replaced by the one of
+// old.
+func keepSection(config AllPluginConfig, old AllPluginConfig, r plugins.Registration) AllPluginConfig {
+	cv := reflect.ValueOf(&config).Elem()
+	ct := cv.Type()
+	for i := 0; i < ct.NumField(); i++ {
+		key := strings.Split(ct.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == r.ConfigKey && cv.Field(i).Type() == reflect.TypeOf(r.Config()).Elem() {
+			cv.Field(i).Set(reflect.ValueOf(old).Field(i))
+			return config
+		}
+	}
+
+	sections := make(map[string]plugins.RawConfig, len(config.Plugins))
+	for key, raw := range config.Plugins {
+		sections[key] = raw
+	}
+	if raw, isOk := old.Plugins[r.ConfigKey]; isOk {
+		sections[r.ConfigKey] = raw
+	} else {
+		delete(sections, r.ConfigKey)
+	}
+	config.Plugins = sections
+	return config
+}
+
+// decodeRawConfig decodes raw into the config type of r after applying its
+// defaults and before applying environment variables and resolving secrets.
+func decodeRawConfig(r plugins.Registration, raw plugins.RawConfig) (interface{}, error) {
+	conf := r.Config()
+	if err := defaults.Set(conf); err != nil {
+		return nil, err
+	}
+	if err := raw.Decode(conf); err != nil {
+		return nil, err
+	}
+	if err := env.Parse(conf); err != nil {
+		return nil, err
+	}
+	if err := plugins.ResolveSecrets(conf); err != nil {
+		return nil, err
+	}
+
+	return reflect.ValueOf(conf).Elem().Interface(), nil
+}
+
+// registrationFor returns the registration using configKey.
+func registrationFor(configKey string) (plugins.Registration, bool) {
+	for _, r := range plugins.Registrations() {
+		if r.ConfigKey == configKey {
+			return r, true
+		}
+	}
+	return plugins.Registration{}, false
+}
+
+// isConfigEnabled returns the value of the Enable field of conf. Configs
+// without such a field are always enabled.
+func isConfigEnabled(conf interface{}) bool {
+	v := reflect.Indirect(reflect.ValueOf(conf))
+	if v.Kind() != reflect.Struct {
+		return true
+	}
+
+	f := v.FieldByName("Enable")
+	if !f.IsValid() || f.Kind() != reflect.Bool {
+		return true
+	}
+
+	return f.Bool()
+}
+
+func resolveDependency(provided []interface{}, t reflect.Type) interface{} {
+	for _, d := range provided {
+		if reflect.TypeOf(d).AssignableTo(t) {
+			return d
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/siklol/zinc/plugins"
+	"github.com/siklol/zinc/plugins/boot"
+	"github.com/siklol/zinc/plugins/kafka"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	registryTestConfig struct {
+		Enable bool   `yaml:"enable" json:"enable"`
+		Value  string `yaml:"value" json:"value" default:"default"`
+	}
+
+	// registryTestPlugin keeps its config and the dependencies Boot received.
+	registryTestPlugin struct {
+		testPlugin
+		conf registryTestConfig
+		deps []interface{}
+	}
+
+	registryBasePlugin struct {
+		registryTestPlugin
+	}
+)
+
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      "coretest-base",
+		ConfigKey: "coretestBase",
+		Config:    func() interface{} { return &registryTestConfig{} },
+		New: func() plugins.Plugin {
+			return &registryBasePlugin{registryTestPlugin{testPlugin: testPlugin{name: "coretest-base"}}}
+		},
+	})
+	plugins.Register(plugins.Registration{
+		Name:      "coretest-dependent",
+		ConfigKey: "coretestDependent",
+		Config:    func() interface{} { return &registryTestConfig{} },
+		New:       func() plugins.Plugin { return &registryTestPlugin{testPlugin: testPlugin{name: "coretest-dependent"}} },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+			plugins.TypeOf[*registryBasePlugin](),
+			plugins.TypeOf[*kafka.Plugin](),
+		},
+	})
+}
+
+func (p *registryTestPlugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plugin {
+	p.conf = conf.(registryTestConfig)
+	p.deps = dependencies
+	return p
+}
+
+func (p *registryBasePlugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plugin {
+	p.registryTestPlugin.Boot(conf, dependencies...)
+	return p
+}
+
+func TestBootRegistered(t *testing.T) {
+	tests := map[string]struct {
+		sections string
+		booted   string
+	}{
+		"none configured": {sections: `{}`, booted: "[]"},
+		"disabled":        {sections: `{"coretestBase": {"enable": false}}`, booted: "[]"},
+		"dependency first": {
+			sections: `{"coretestDependent": {"enable": true}, "coretestBase": {"enable": true}}`,
+			booted:   "[coretest-base coretest-dependent]",
+		},
+		"dependency missing": {sections: `{"coretestDependent": {"enable": true}}`, booted: "[coretest-dependent]"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newTestCore(t)
+			c.bp = &boot.Plugin{ID: xid.New()}
+
+			config := AllPluginConfig{}
+			if err := json.Unmarshal([]byte(tt.sections), &config.Plugins); err != nil {
+				t.Fatal(err)
+			}
+
+			booted, err := c.bootRegistered(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, p := range booted {
+				names = append(names, p.Name())
+			}
+			if fmt.Sprint(names) != tt.booted {
+				t.Fatalf("got booted %v, want %s", names, tt.booted)
+			}
+		})
+	}
+}
+
+func TestBootRegisteredDependencies(t *testing.T) {
+	c := newTestCore(t)
+	c.bp = &boot.Plugin{ID: xid.New()}
+
+	config := AllPluginConfig{}
+	sections := `{"coretestDependent": {"enable": true}, "coretestBase": {"enable": true, "value": "set"}}`
+	if err := json.Unmarshal([]byte(sections), &config.Plugins); err != nil {
+		t.Fatal(err)
+	}
+
+	booted, err := c.bootRegistered(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, dependent := booted[0].(*registryBasePlugin), booted[1].(*registryTestPlugin)
+
+	if base.conf.Value != "set" || dependent.conf.Value != "default" {
+		t.Fatalf("got configs %+v and %+v", base.conf, dependent.conf)
+	}
+	// kafka is not booted, so only the logger and base are passed
+	if len(dependent.deps) != 2 || dependent.deps[1] != base {
+		t.Fatalf("got dependencies %v", dependent.deps)
+	}
+	if _, isOk := dependent.deps[0].(*logrus.Entry); !isOk {
+		t.Fatalf("got %T as first dependency", dependent.deps[0])
+	}
+}
+
+func TestPluginConfig(t *testing.T) {
+	config := AllPluginConfig{Kafka: kafka.Config{Enable: true, Brokers: "kafka:9092"}}
+
+	conf, isPresent, err := pluginConfig(config, plugins.Registration{
+		ConfigKey: "kafka",
+		Config:    func() interface{} { return &kafka.Config{} },
+	})
+	if err != nil || !isPresent || conf.(kafka.Config).Brokers != "kafka:9092" {
+		t.Fatalf("got %v, %t, %v for a typed section", conf, isPresent, err)
+	}
+
+	_, isPresent, err = pluginConfig(config, plugins.Registration{
+		ConfigKey: "missing",
+		Config:    func() interface{} { return &registryTestConfig{} },
+	})
+	if err != nil || isPresent {
+		t.Fatalf("got %t, %v for a missing section", isPresent, err)
+	}
+}
+
+func TestIsConfigEnabled(t *testing.T) {
+	tests := map[string]struct {
+		conf interface{}
+		want bool
+	}{
+		"enabled":        {registryTestConfig{Enable: true}, true},
+		"disabled":       {registryTestConfig{}, false},
+		"pointer":        {&registryTestConfig{Enable: true}, true},
+		"without enable": {struct{ Value string }{}, true},
+		"not a struct":   {"value", true},
+	}
+
+	for name, tt := range tests {
+		if got := isConfigEnabled(tt.conf); got != tt.want {
+			t.Errorf("%s: got %t, want %t", name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/siklol/zinc/plugins"
+)
+
+var (
+	ErrNotBooted        = errors.New("core plugins not booted yet")
+	ErrNoCoreConfig     = errors.New("config does not contain core.AllPluginConfig")
+	ErrReloadIncomplete = errors.New("config reload incomplete")
+)
+
+// Reload applies config to the running plugins. Only plugins whose config
+// section differs from the currently applied config are reloaded. Changed
+// sections of plugins that are not plugins.Reconfigurable are reported and
+// require a restart. Sections that were not applied keep their old value, so
+// the next reload reports them again.
+func (c *Core) Reload(config AllPluginConfig) error {
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+
+	l := c.Logger().WithField("component", "reload")
+
+	if c.config == nil {
+		return ErrNotBooted
+	}
+	old := *c.config
+	applied := config
+
+	errs := []error{}
+	if !reflect.DeepEqual(old.Logging, config.Logging) {
+		l.Debug("reloading logging config")
+		if err := c.ll.Reload(config.Logging); err != nil {
+			errs = append(errs, fmt.Errorf("logging: %w", err))
+			applied.Logging = old.Logging
+		}
+	}
+
+	for _, r := range plugins.Registrations() {
+		p, isRegistered := c.plugins[r.Name]
+		if !isRegistered {
+			continue
+		}
+
+		oldConf, _, err := pluginConfig(old, r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name, err))
+			continue
+		}
+		newConf, isPresent, err := pluginConfig(config, r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name, err))
+			applied = keepSection(applied, old, r)
+			continue
+		}
+		if !isPresent || reflect.DeepEqual(oldConf, newConf) {
+			continue
+		}
+
+		rp, isOk := p.(plugins.Reconfigurable)
+		if !isOk {
+			l.WithField("plugin", r.Name).Warn("config changed but plugin cannot be reloaded. restart required")
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name, plugins.ErrRestartRequired))
+			applied = keepSection(applied, old, r)
+			continue
+		}
+
+		l.WithField("plugin", r.Name).Debug("reloading plugin config")
+		if err := rp.Reload(newConf); err != nil {
+			l.WithError(err).WithField("plugin", r.Name).Warn("error reloading plugin")
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name, err))
+			applied = keepSection(applied, old, r)
+		}
+	}
+
+	c.config = &applied
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrReloadIncomplete, errors.Join(errs...))
+	}
+	return nil
+}
+
+// reloadFrom extracts the core section of conf and reloads it. Updates that
+// arrive before the plugins are booted are ignored.
+func (c *Core) reloadFrom(conf interface{}) {
+	l := c.Logger().WithField("component", "reload")
+
+	if c.config == nil {
+		l.Trace("plugins not booted yet. skipping reload")
+		return
+	}
+
+	config, err := coreConfigOf(conf)
+	if err != nil {
+		l.WithError(err).Warn("could not reload config")
+		return
+	}
+
+	if err := c.Reload(config); err != nil {
+		l.WithError(err).Warn("config reloaded with errors")
+		return
+	}
+	l.Info("config reloaded")
+}
+
+// watch calls onTick every interval and onSignal on SIGHUP until core shuts
+// down. An interval <= 0 disables the ticker.
+func (c *Core) watch(interval time.Duration, onTick func(), onSignal func()) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGHUP)
+	defer signal.Stop(sigC)
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-tick:
+			onTick()
+		case <-sigC:
+			onSignal()
+		}
+	}
+}
+
+// coreConfigOf returns conf itself if it is an AllPluginConfig or the first
+// field of conf with that type.
+func coreConfigOf(conf interface{}) (AllPluginConfig, error) {
+	v := reflect.Indirect(reflect.ValueOf(conf))
+	t := reflect.TypeOf(AllPluginConfig{})
+
+	if v.Type() == t {
+		return v.Interface().(AllPluginConfig), nil
+	}
+
+	if v.Kind() == reflect.Struct {
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).Type() == t {
+				return v.Field(i).Interface().(AllPluginConfig), nil
+			}
+		}
+	}
+
+	return AllPluginConfig{}, ErrNoCoreConfig
+}
+
+// newConfigLike returns a pointer to a new zero value of the type conf
+// points to.
+func newConfigLike(conf interface{}) interface{} {
+	return reflect.New(reflect.TypeOf(conf).Elem()).Interface()
+}
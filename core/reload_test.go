@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/siklol/zinc/plugins"
+	"github.com/siklol/zinc/plugins/kafka"
+)
+
+type (
+	reloadTestConfig struct {
+		Value string `yaml:"value" json:"value"`
+	}
+
+	// reconfigurablePlugin is a testPlugin implementing plugins.Reconfigurable.
+	reconfigurablePlugin struct {
+		*testPlugin
+		reloaded  []interface{}
+		reloadErr error
+	}
+)
+
+func (p *reconfigurablePlugin) Reload(newConf interface{}) error {
+	p.reloaded = append(p.reloaded, newConf)
+	return p.reloadErr
+}
+
+func init() {
+	for _, name := range []string{"coretest-reloadable", "coretest-static"} {
+		plugins.Register(plugins.Registration{
+			Name:   name,
+			Config: func() interface{} { return &reloadTestConfig{} },
+			New:    func() plugins.Plugin { return &testPlugin{} },
+		})
+	}
+}
+
+// reloadTestPlugins returns a config with the sections of the test plugins.
+func reloadTestPlugins(t *testing.T, sections string) AllPluginConfig {
+	t.Helper()
+
+	config := AllPluginConfig{}
+	if err := json.Unmarshal([]byte(sections), &config.Plugins); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestReloadChangedSections(t *testing.T) {
+	reloadable := &reconfigurablePlugin{testPlugin: &testPlugin{name: "coretest-reloadable"}}
+	c := newTestCore(t, reloadable)
+	config := reloadTestPlugins(t, `{"coretest-reloadable": {"value": "a"}}`)
+	c.config = &config
+
+	if err := c.Reload(reloadTestPlugins(t, `{"coretest-reloadable": {"value": "a"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(reloadable.reloaded) != 0 {
+		t.Fatalf("unchanged section reloaded: %v", reloadable.reloaded)
+	}
+
+	if err := c.Reload(reloadTestPlugins(t, `{"coretest-reloadable": {"value": "b"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(reloadable.reloaded) != 1 || reloadable.reloaded[0] != (reloadTestConfig{Value: "b"}) {
+		t.Fatalf("got reloads %v", reloadable.reloaded)
+	}
+}
+
+func TestReloadRestartRequired(t *testing.T) {
+	errReload := errors.New("reload failed")
+	c := newTestCore(t,
+		&reconfigurablePlugin{testPlugin: &testPlugin{name: "coretest-reloadable"}, reloadErr: errReload},
+		&testPlugin{name: "coretest-static"},
+	)
+	config := reloadTestPlugins(t, `{"coretest-reloadable": {"value": "a"}, "coretest-static": {"value": "a"}}`)
+	c.config = &config
+
+	next := reloadTestPlugins(t, `{"coretest-reloadable": {"value": "b"}, "coretest-static": {"value": "b"}}`)
+	err := c.Reload(next)
+	if !errors.Is(err, ErrReloadIncomplete) || !errors.Is(err, plugins.ErrRestartRequired) || !errors.Is(err, errReload) {
+		t.Fatalf("got error %v", err)
+	}
+
+	// the sections were not applied, so the next reload reports them again
+	err = c.Reload(next)
+	if !errors.Is(err, plugins.ErrRestartRequired) || !errors.Is(err, errReload) {
+		t.Fatalf("got error %v on second reload", err)
+	}
+}
+
+func TestReloadKeepsAppliedSections(t *testing.T) {
+	reloadable := &reconfigurablePlugin{testPlugin: &testPlugin{name: "coretest-reloadable"}}
+	c := newTestCore(t, reloadable, &testPlugin{name: "coretest-static"})
+	config := reloadTestPlugins(t, `{"coretest-reloadable": {"value": "a"}, "coretest-static": {"value": "a"}}`)
+	c.config = &config
+
+	next := reloadTestPlugins(t, `{"coretest-reloadable": {"value": "b"}, "coretest-static": {"value": "b"}}`)
+	if err := c.Reload(next); !errors.Is(err, plugins.ErrRestartRequired) {
+		t.Fatalf("got error %v", err)
+	}
+
+	// reverting the static section needs no restart, the reloadable one is
+	// applied already
+	if err := c.Reload(reloadTestPlugins(t, `{"coretest-reloadable": {"value": "b"}, "coretest-static": {"value": "a"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(reloadable.reloaded) != 1 {
+		t.Fatalf("got reloads %v", reloadable.reloaded)
+	}
+}
+
+func TestKeepSection(t *testing.T) {
+	old := AllPluginConfig{Kafka: kafka.Config{Brokers: "old:9092"}}
+	config := AllPluginConfig{Kafka: kafka.Config{Brokers: "new:9092"}}
+
+	kept := keepSection(config, old, plugins.Registration{ConfigKey: "kafka", Config: func() interface{} { return &kafka.Config{} }})
+	if kept.Kafka.Brokers != "old:9092" || config.Kafka.Brokers != "new:9092" {
+		t.Fatalf("got brokers %s, config %s", kept.Kafka.Brokers, config.Kafka.Brokers)
+	}
+}
+
+func TestReloadBeforeBoot(t *testing.T) {
+	c := newTestCore(t)
+
+	if err := c.Reload(AllPluginConfig{}); !errors.Is(err, ErrNotBooted) {
+		t.Fatalf("got error %v, want %v", err, ErrNotBooted)
+	}
+}
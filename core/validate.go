@@ -0,0 +1,132 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/siklol/zinc/plugins"
+)
+
+var (
+	ErrInvalidConfig    = errors.New("invalid config")
+	ErrConfigRequired   = errors.New("value is required")
+	ErrConfigNotOneOf   = errors.New("value is not allowed")
+	ErrConfigOutOfRange = errors.New("value is out of range")
+	ErrUnknownRule      = errors.New("unknown validation rule")
+)
+
+// ValidateConfig checks the validate struct tags of conf. Sections with an
+// Enable field set to false are skipped. Supported rules are required,
+// oneof=a b c, min=n and max=n. min and max compare numbers by value and
+// strings, slices and maps by length. Rules are separated by commas.
+// Sections in AllPluginConfig.Plugins are validated with the config type of
+// their registration.
+func ValidateConfig(conf interface{}) error {
+	if errs := validateConfig(reflect.ValueOf(conf), ""); len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, errors.Join(errs...))
+	}
+	return nil
+}
+
+func validateConfig(v reflect.Value, path string) []error {
+	errs := []error{}
+	walkConfig(v, path, func(cf configField) bool {
+		v := indirect(cf.Value)
+		if !v.IsValid() {
+			return true
+		}
+		if v.Kind() == reflect.Struct && !isConfigLeaf(v.Type()) && !isConfigEnabled(v.Interface()) {
+			return false
+		}
+
+		if raw, isOk := v.Interface().(plugins.RawConfig); isOk {
+			r, isRegistered := registrationFor(cf.Path[strings.LastIndex(cf.Path, ".")+1:])
+			if !isRegistered {
+				return true
+			}
+			conf, err := decodeRawConfig(r, raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", cf.Path, err))
+				return true
+			}
+			if isConfigEnabled(conf) {
+				errs = append(errs, validateConfig(reflect.ValueOf(conf), cf.Path)...)
+			}
+			return true
+		}
+
+		// map entries share the field of their map
+		if cf.Field.Type != cf.Value.Type() {
+			return true
+		}
+		for _, rule := range strings.Split(cf.Field.Tag.Get("validate"), ",") {
+			if err := validateRule(cf.Value, strings.TrimSpace(rule)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", cf.Path, err))
+			}
+		}
+		return true
+	})
+
+	return errs
+}
+
+func validateRule(v reflect.Value, rule string) error {
+	name, arg, _ := strings.Cut(rule, "=")
+	v = indirect(v)
+
+	switch name {
+	case "":
+		return nil
+	case "required":
+		if !v.IsValid() || v.IsZero() {
+			return ErrConfigRequired
+		}
+		return nil
+	case "oneof":
+		if !v.IsValid() {
+			return nil
+		}
+		value := fmt.Sprint(v.Interface())
+		for _, allowed := range strings.Fields(arg) {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s not in [%s]", ErrConfigNotOneOf, value, arg)
+	case "min", "max":
+		if !v.IsValid() {
+			return nil
+		}
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrUnknownRule, rule)
+		}
+		n, isOk := measure(v)
+		if !isOk {
+			return fmt.Errorf("%w: %s on %s", ErrUnknownRule, rule, v.Kind())
+		}
+		if name == "min" && n < limit || name == "max" && n > limit {
+			return fmt.Errorf("%w: %s, got %v", ErrConfigOutOfRange, rule, n)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownRule, rule)
+}
+
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
@@ -1,6 +1,8 @@
 package boltdb
 
 import (
+	"context"
+	"reflect"
 	"time"
 
 	"github.com/siklol/zinc/plugins"
@@ -10,19 +12,32 @@ import (
 
 type (
 	Plugin struct {
-		logger *logrus.Entry
-		conf   Config
-		db     *bolt.DB
+		logger  *logrus.Entry
+		conf    Config
+		db      *bolt.DB
+		bootErr error
 	}
 
 	Config struct {
 		Enable bool   `env:"BOLTDB_ENABLE" default:"false" yaml:"enable"`
-		File   string `env:"BOLTDB_FILE" default:"db.db" yaml:"file"`
+		File   string `env:"BOLTDB_FILE" default:"db.db" yaml:"file" validate:"required"`
 	}
 )
 
 const Name = "boltdb"
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "boltdb",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -49,11 +64,22 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 		return p
 	}
 
-	p.db = p.Open(p.conf.File)
+	db, err := p.OpenFile(p.conf.File)
+	if err != nil {
+		l.WithError(err).Error("error starting bolt db")
+		p.bootErr = err
+		p.conf.Enable = false
+		return p
+	}
+	p.db = db
 
 	return p
 }
 
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
 func (p *Plugin) Start() error {
 	return nil
 }
@@ -63,14 +89,17 @@ func (p *Plugin) Open(file string) *bolt.DB {
 		p.logger.Fatal("cannot open boltdb. plugin is not enabled")
 	}
 
-	l := p.logger
-	boltDB, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	boltDB, err := p.OpenFile(file)
 	if err != nil {
-		l.WithError(err).Fatal("error starting bolt db")
+		p.logger.WithError(err).Fatal("error starting bolt db")
 	}
 	return boltDB
 }
 
+func (p *Plugin) OpenFile(file string) (*bolt.DB, error) {
+	return bolt.Open(file, 0600, &bolt.Options{Timeout: 1 * time.Second})
+}
+
 func (p *Plugin) IsEnabled() bool {
 	return p.conf.Enable
 }
@@ -82,6 +111,15 @@ func (p *Plugin) Close() error {
 	return p.db.Close()
 }
 
+func (p *Plugin) Health(ctx context.Context) error {
+	if !p.conf.Enable {
+		return nil
+	}
+	return p.db.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
 func (p *Plugin) DB() *bolt.DB {
 	if !p.conf.Enable {
 		p.logger.Fatal("cannot use boltdb. plugin is not enabled")
@@ -16,6 +16,7 @@ type (
 		conf         Config
 		cliFuncs     map[string]CliFunc
 		shutdownFunc func()
+		args         []string
 	}
 
 	Config struct {
@@ -55,7 +56,20 @@ func (cliD *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.
 }
 
 func (cliD *Plugin) ParseFlags(opts interface{}, args []string) ([]string, error) {
-	return flags.ParseArgs(opts, args)
+	rest, err := flags.ParseArgs(opts, args)
+	if err == nil {
+		cliD.args = rest
+	}
+	return rest, err
+}
+
+// Args returns the positional arguments after the subcommand of the last
+// parsed command line.
+func (cliD *Plugin) Args() []string {
+	if len(cliD.args) < 3 {
+		return nil
+	}
+	return cliD.args[2:]
 }
 
 func (cliD *Plugin) IsEnabled() bool {
@@ -84,7 +98,7 @@ func (cliD *Plugin) Run() error {
 		first = os.Args[1]
 	}
 
-	if len(first) > 0 && first[0:1] == "-" && len(cliD.cliFuncs) == 1 {
+	if _, hasDefault := cliD.cliFuncs["default"]; len(first) > 0 && first[0:1] == "-" && hasDefault {
 		first = "default"
 	}
 
@@ -56,16 +56,38 @@ func (bp *Plugin) Close() error {
 }
 
 func (bp *Plugin) LoadConfig(url string, name string, conf interface{}) error {
-	l := bp.Logger
-
 	if err := defaults.Set(conf); err != nil {
 		panic(err)
 	}
 
+	if err := bp.DecodeConfig(url, name, conf); err != nil {
+		return err
+	}
+
+	if err := env.Parse(conf); err != nil {
+		bp.Logger.WithError(err).Error("could not parse environment variables")
+		return fmt.Errorf("parse env: %w", err)
+	}
+
+	if err := plugins.ResolveSecrets(conf); err != nil {
+		bp.Logger.WithError(err).Error("could not resolve secrets")
+		return err
+	}
+
+	return nil
+}
+
+// DecodeConfig fetches the config of service name from the configurator at url
+// and unmarshals it into conf without applying defaults or environment
+// variables.
+func (bp *Plugin) DecodeConfig(url string, name string, conf interface{}) error {
+	l := bp.Logger
+
 	resp, err := http.Get(fmt.Sprintf("%s/config/%s?raw=1", url, name))
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	l.WithField("code", resp.StatusCode).Trace("status code from config service")
 
 	if resp.StatusCode != http.StatusOK {
@@ -85,9 +107,5 @@ func (bp *Plugin) LoadConfig(url string, name string, conf interface{}) error {
 		return err
 	}
 
-	if err := env.Parse(conf); err != nil {
-		fmt.Printf("%+v\n", err)
-	}
-
-	return err
+	return nil
 }
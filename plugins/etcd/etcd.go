@@ -1,6 +1,11 @@
 package etcd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
 	"github.com/rs/xid"
 	"github.com/siklol/zinc/plugins"
 	"github.com/sirupsen/logrus"
@@ -11,22 +16,40 @@ import (
 
 type (
 	Plugin struct {
-		logger *logrus.Entry
-		conf   Config
-		e      *clientv3.Client
-		lead   *Leader
-		id     string
+		logger  *logrus.Entry
+		conf    Config
+		e       *clientv3.Client
+		lead    *Leader
+		id      string
+		bootErr error
 	}
 
 	Config struct {
 		Enable      bool          `env:"ETCD_ENABLE" default:"false" yaml:"enable"`
-		Endpoints   string        `env:"ETCD_ENDPOINTS" default:"localhost:2379" yaml:"host"`
+		Endpoints   string        `env:"ETCD_ENDPOINTS" default:"localhost:2379" yaml:"host" validate:"required"`
 		DialTimeout time.Duration `env:"ETCD_DIAL_TIMEOUT" default:"5m" yaml:"dialTimeout"`
 	}
 )
 
 const Name = "etcd"
 
+var (
+	ErrNoEndpoints = errors.New("no etcd endpoints configured")
+)
+
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "etcd",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+			plugins.TypeOf[xid.ID](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -64,7 +87,10 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	})
 
 	if err != nil {
-		l.WithError(err).Fatal("etcd could not connect. failing")
+		l.WithError(err).Error("etcd could not connect. failing")
+		p.bootErr = fmt.Errorf("etcd connect: %w", err)
+		p.conf.Enable = false
+		return p
 	}
 
 	p.e = e
@@ -72,6 +98,10 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	return p
 }
 
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
 func (p *Plugin) Start() error {
 	return nil
 }
@@ -81,12 +111,16 @@ func (p *Plugin) IsEnabled() bool {
 }
 
 func (p *Plugin) Close() error {
+	return p.CloseContext(context.Background())
+}
+
+func (p *Plugin) CloseContext(ctx context.Context) error {
 	if !p.conf.Enable {
 		return nil
 	}
 
 	if p.lead != nil {
-		if err := p.lead.Close(); err != nil {
+		if err := p.lead.CloseContext(ctx); err != nil {
 			p.logger.Warn("error resigning leader")
 		}
 	}
@@ -94,6 +128,26 @@ func (p *Plugin) Close() error {
 	return p.e.Close()
 }
 
+// Health succeeds as soon as one of the configured endpoints answers.
+func (p *Plugin) Health(ctx context.Context) error {
+	if !p.conf.Enable {
+		return nil
+	}
+
+	var err error
+	for _, ep := range p.e.Endpoints() {
+		if _, err = p.e.Status(ctx, ep); err == nil {
+			return nil
+		}
+		p.logger.WithError(err).WithField("endpoint", ep).Debug("etcd endpoint not healthy")
+	}
+
+	if err == nil {
+		return ErrNoEndpoints
+	}
+	return err
+}
+
 func (p *Plugin) Etcd() *clientv3.Client {
 	return p.e
 }
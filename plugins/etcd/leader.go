@@ -47,7 +47,15 @@ func NewLeader(e *clientv3.Client, l logrus.FieldLogger, serviceID string, opts
 }
 
 func (lead *Leader) Close() error {
-	if err := lead.election.Resign(lead.ctx); err != nil {
+	return lead.CloseContext(lead.ctx)
+}
+
+func (lead *Leader) CloseContext(ctx context.Context) error {
+	if lead.session == nil {
+		return nil
+	}
+
+	if err := lead.election.Resign(ctx); err != nil {
 		lead.logger.WithError(err).Warn("error resigning leader from election")
 	}
 
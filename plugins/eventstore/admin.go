@@ -0,0 +1,328 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/siklol/zinc/plugins"
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/restjwt"
+)
+
+type (
+	AdminConfig struct {
+		// Enable mounts the read-only admin endpoints on the rest router.
+		// They require restjwt.
+		Enable bool   `env:"EVENTSTORE_ADMIN_ENABLE" default:"false" yaml:"enable" json:"enable"`
+		Prefix string `env:"EVENTSTORE_ADMIN_PREFIX" default:"/admin/eventstore" yaml:"prefix" json:"prefix" validate:"required"`
+		// Storage is queried if a request names none. Empty uses postgres,
+		// boltdb or memory, whichever is enabled first.
+		Storage string `env:"EVENTSTORE_ADMIN_STORAGE" yaml:"storage" json:"storage"`
+	}
+
+	// EventView renders a RecordedEvent with its payload as json.
+	EventView struct {
+		eventsourcing.RecordedEvent
+		Data json.RawMessage `json:"data"`
+	}
+
+	CheckpointView struct {
+		Projection string `json:"projection"`
+		Position   int64  `json:"position"`
+	}
+)
+
+var (
+	ErrAdminRequiresJWT = errors.New("eventstore admin requires restjwt")
+	ErrMissingArgument  = errors.New("missing argument")
+)
+
+// mountAdmin adds the admin endpoints to the rest router:
+//
+//	GET <prefix>/aggregates?after=&limit=
+//	GET <prefix>/aggregates/:id/events?from=&to=
+//	GET <prefix>/events?name=&version=&correlation=&since=&until=&after=&limit=
+//	GET <prefix>/events/:id
+//	GET <prefix>/projections
+//
+// Every endpoint accepts ?storage= to query a storage other than the default.
+func (p *Plugin) mountAdmin() error {
+	if p.jwt == nil || !p.jwt.IsEnabled() {
+		return ErrAdminRequiresJWT
+	}
+
+	g := p.rest.Router().Group(p.conf.Admin.Prefix, requireJWT(p.jwt.Handler()))
+	g.GET("/aggregates", p.adminAggregates)
+	g.GET("/aggregates/:id/events", p.adminStream)
+	g.GET("/events", p.adminEvents)
+	g.GET("/events/:id", p.adminEvent)
+	g.GET("/projections", p.adminProjections)
+
+	p.logger.WithField("prefix", p.conf.Admin.Prefix).Debug("eventstore admin mounted")
+
+	return nil
+}
+
+// CLICommands adds subcommands dumping events as json lines to stdout:
+//
+//	eventstore-aggregates [storage]
+//	eventstore-stream <aggregate id> [storage]
+//	eventstore-events [storage]
+func (p *Plugin) CLICommands() map[string]plugins.CLICommand {
+	return map[string]plugins.CLICommand{
+		"eventstore-aggregates": func(args []string) error {
+			return p.dumpAggregates(os.Stdout, arg(args, 0))
+		},
+		"eventstore-stream": func(args []string) error {
+			id := arg(args, 0)
+			if id == "" {
+				return fmt.Errorf("%w: aggregate id", ErrMissingArgument)
+			}
+			return p.dumpStream(os.Stdout, eventsourcing.AggregateID(id), arg(args, 1))
+		},
+		"eventstore-events": func(args []string) error {
+			return p.dumpEvents(os.Stdout, arg(args, 0))
+		},
+	}
+}
+
+func (p *Plugin) adminAggregates(c echo.Context) error {
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		return err
+	}
+
+	ids, err := p.es.AggregateIDs(p.adminStorage(c.QueryParam("storage")), eventsourcing.AggregateID(c.QueryParam("after")), int(limit))
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(http.StatusOK, ids)
+}
+
+func (p *Plugin) adminStream(c echo.Context) error {
+	from, err := queryInt(c, "from")
+	if err != nil {
+		return err
+	}
+	to, err := queryInt(c, "to")
+	if err != nil {
+		return err
+	}
+
+	events, err := p.es.ReadStream(p.adminStorage(c.QueryParam("storage")), eventsourcing.AggregateID(c.Param("id")), from, to)
+	if err != nil {
+		return adminError(err)
+	}
+	if len(events) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "aggregate not found")
+	}
+	return c.JSON(http.StatusOK, eventViews(events))
+}
+
+func (p *Plugin) adminEvents(c echo.Context) error {
+	f := eventsourcing.EventFilter{
+		Name:          c.QueryParam("name"),
+		Version:       c.QueryParam("version"),
+		CorrelationID: c.QueryParam("correlation"),
+		AggregateID:   eventsourcing.AggregateID(c.QueryParam("aggregate")),
+	}
+
+	var err error
+	if f.After, err = queryInt(c, "after"); err != nil {
+		return err
+	}
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		return err
+	}
+	f.Limit = int(limit)
+	if f.Since, err = queryTime(c, "since"); err != nil {
+		return err
+	}
+	if f.Until, err = queryTime(c, "until"); err != nil {
+		return err
+	}
+
+	events, err := p.es.ReadEvents(p.adminStorage(c.QueryParam("storage")), f)
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(http.StatusOK, eventViews(events))
+}
+
+func (p *Plugin) adminEvent(c echo.Context) error {
+	e, isFound, err := p.es.EventByID(p.adminStorage(c.QueryParam("storage")), eventsourcing.EventID(c.Param("id")))
+	if err != nil {
+		return adminError(err)
+	}
+	if !isFound {
+		return echo.NewHTTPError(http.StatusNotFound, "event not found")
+	}
+	return c.JSON(http.StatusOK, NewEventView(e))
+}
+
+func (p *Plugin) adminProjections(c echo.Context) error {
+	projector, err := p.Projector()
+	if err != nil {
+		return adminError(err)
+	}
+
+	checkpoints := []CheckpointView{}
+	for _, name := range projector.Names() {
+		position, err := projector.s.Checkpoint(name)
+		if err != nil {
+			return adminError(err)
+		}
+		checkpoints = append(checkpoints, CheckpointView{Projection: name, Position: position})
+	}
+	return c.JSON(http.StatusOK, checkpoints)
+}
+
+func (p *Plugin) dumpAggregates(w io.Writer, storage string) error {
+	enc := json.NewEncoder(w)
+
+	var after eventsourcing.AggregateID
+	for {
+		ids, err := p.es.AggregateIDs(p.adminStorage(storage), after, eventsourcing.DefaultQueryLimit)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := enc.Encode(id); err != nil {
+				return err
+			}
+		}
+		if len(ids) < eventsourcing.DefaultQueryLimit {
+			return nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+func (p *Plugin) dumpStream(w io.Writer, id eventsourcing.AggregateID, storage string) error {
+	events, err := p.es.ReadStream(p.adminStorage(storage), id, 1, 0)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(NewEventView(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Plugin) dumpEvents(w io.Writer, storage string) error {
+	enc := json.NewEncoder(w)
+
+	f := eventsourcing.EventFilter{Limit: eventsourcing.DefaultQueryLimit}
+	for {
+		events, err := p.es.ReadEvents(p.adminStorage(storage), f)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err := enc.Encode(NewEventView(e)); err != nil {
+				return err
+			}
+		}
+		if len(events) < f.Limit {
+			return nil
+		}
+		f.After = events[len(events)-1].Position
+	}
+}
+
+// adminStorage returns name or the default admin storage.
+func (p *Plugin) adminStorage(name string) string {
+	if name != "" {
+		return name
+	}
+	if p.conf.Admin.Storage != "" {
+		return p.conf.Admin.Storage
+	}
+	for _, name := range []string{"postgres", "boltdb", "memory"} {
+		if _, isOk := p.storages[name]; isOk {
+			return name
+		}
+	}
+	return ""
+}
+
+func NewEventView(e eventsourcing.RecordedEvent) EventView {
+	return EventView{RecordedEvent: e, Data: json.RawMessage(e.Data)}
+}
+
+func eventViews(events []eventsourcing.RecordedEvent) []EventView {
+	views := make([]EventView, 0, len(events))
+	for _, e := range events {
+		views = append(views, NewEventView(e))
+	}
+	return views
+}
+
+// requireJWT rejects requests without a valid bearer token.
+func requireJWT(j *restjwt.Handler) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			auth := c.Request().Header.Get(echo.HeaderAuthorization)
+			if auth == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing token")
+			}
+			if _, err := j.Token(auth); err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+func adminError(err error) error {
+	switch {
+	case errors.Is(err, eventsourcing.ErrStorageNotAvailable), errors.Is(err, eventsourcing.ErrQueriesNotSupported):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrProjectorNotAvailable), errors.Is(err, ErrNoStreamStorage):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return err
+}
+
+func queryInt(c echo.Context, name string) (int64, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s: %s", name, v))
+	}
+	return i, nil
+}
+
+func queryTime(c echo.Context, name string) (time.Time, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s: %s", name, v))
+	}
+	return t, nil
+}
+
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package eventstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/sirupsen/logrus"
+)
+
+// newAdminTestPlugin boots the plugin with a memory storage holding three
+// events of a and one of b and mounts the admin handlers without jwt.
+func newAdminTestPlugin(t *testing.T) (*Plugin, *echo.Echo) {
+	t.Helper()
+
+	conf := Config{Enable: true}
+	conf.Storages.Memory.Enable = true
+	p := New().Boot(conf, logrus.WithField("test", t.Name())).(*Plugin)
+	if err := p.BootError(); err != nil {
+		t.Fatal(err)
+	}
+	appendEvents(t, p.storages["memory"], "a", 3)
+	appendEvents(t, p.storages["memory"], "b", 1)
+
+	e := echo.New()
+	e.GET("/aggregates", p.adminAggregates)
+	e.GET("/aggregates/:id/events", p.adminStream)
+	e.GET("/events", p.adminEvents)
+	e.GET("/events/:id", p.adminEvent)
+	e.GET("/projections", p.adminProjections)
+	return p, e
+}
+
+func TestAdminHandlers(t *testing.T) {
+	p, e := newAdminTestPlugin(t)
+	if err := p.RegisterProjection(&testProjection{name: "counter"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.storages["memory"].(eventsourcing.StreamStorage).SaveCheckpoint("counter", 2); err != nil {
+		t.Fatal(err)
+	}
+	events, err := p.es.ReadStream("memory", "a", 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventID := string(events[0].ID)
+
+	tests := map[string]struct {
+		path   string
+		status int
+		want   string
+	}{
+		"aggregates": {
+			path:   "/aggregates",
+			status: http.StatusOK,
+			want:   `["a","b"]`,
+		},
+		"aggregates after": {
+			path:   "/aggregates?after=a&limit=10",
+			status: http.StatusOK,
+			want:   `["b"]`,
+		},
+		"invalid limit": {
+			path:   "/aggregates?limit=x",
+			status: http.StatusBadRequest,
+		},
+		"unknown storage": {
+			path:   "/aggregates?storage=unknown",
+			status: http.StatusBadRequest,
+		},
+		"stream": {
+			path:   "/aggregates/a/events?from=2",
+			status: http.StatusOK,
+			want:   `"sequence":2`,
+		},
+		"missing stream": {
+			path:   "/aggregates/c/events",
+			status: http.StatusNotFound,
+		},
+		"events of aggregate": {
+			path:   "/events?aggregate=b",
+			status: http.StatusOK,
+			want:   `"position":4`,
+		},
+		"invalid since": {
+			path:   "/events?since=yesterday",
+			status: http.StatusBadRequest,
+		},
+		"event": {
+			path:   "/events/" + eventID,
+			status: http.StatusOK,
+			want:   `"id":"` + eventID + `"`,
+		},
+		"missing event": {
+			path:   "/events/unknown",
+			status: http.StatusNotFound,
+		},
+		"projections": {
+			path:   "/projections",
+			status: http.StatusOK,
+			want:   `[{"projection":"counter","position":2}]`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if rec.Code != test.status {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, test.status, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), test.want) {
+				t.Fatalf("got body %s, want it to contain %s", rec.Body, test.want)
+			}
+		})
+	}
+}
+
+func TestAdminEventView(t *testing.T) {
+	_, e := newAdminTestPlugin(t)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/aggregates/a/events", nil))
+
+	var views []struct {
+		Sequence int64           `json:"sequence"`
+		Data     json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &views); err != nil {
+		t.Fatal(err)
+	}
+	if len(views) != 3 {
+		t.Fatalf("got %d events, want 3", len(views))
+	}
+	// the payload is rendered as json, not base64
+	if !bytes.HasPrefix(views[0].Data, []byte(`{"meta"`)) {
+		t.Fatalf("got data %s", views[0].Data)
+	}
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, requireJWT(nil))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestMountAdminRequiresJWT(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	if err := p.mountAdmin(); !errors.Is(err, ErrAdminRequiresJWT) {
+		t.Fatalf("got error %v, want %v", err, ErrAdminRequiresJWT)
+	}
+}
+
+func TestDumpStream(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+
+	var buf bytes.Buffer
+	if err := p.dumpStream(&buf, "a", ""); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Fatalf("got %d lines, want 3", lines)
+	}
+
+	buf.Reset()
+	if err := p.dumpAggregates(&buf, "memory"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\"a\"\n\"b\"\n"; buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+
+	if err := p.CLICommands()["eventstore-stream"](nil); err == nil {
+		t.Fatal("no error without aggregate id")
+	}
+}
@@ -9,4 +9,69 @@ type (
 		OnRaw(name string, version string, e []byte) error
 		Events() []Event
 	}
+
+	// VersionedAggregate is implemented by aggregates that remember the
+	// version of their stream. EventStore sets it after loading and
+	// persisting.
+	VersionedAggregate interface {
+		Aggregate
+		Version() int64
+		SetVersion(version int64)
+	}
+
+	// CommittableAggregate returns only pending events from Events. The
+	// EventStore marks them committed after they were persisted and drops
+	// the events replayed while loading.
+	CommittableAggregate interface {
+		Aggregate
+		MarkCommitted()
+	}
+
+	// BaseAggregate implements the bookkeeping of VersionedAggregate and
+	// CommittableAggregate. Embed it and call Record from On for every
+	// applied event:
+	//
+	//	func (a *MyAggregate) On(e eventsourcing.Event) error {
+	//		// change state
+	//		a.Record(e)
+	//		return nil
+	//	}
+	BaseAggregate struct {
+		id      AggregateID
+		version int64
+		pending []Event
+	}
 )
+
+func NewBaseAggregate(id AggregateID) BaseAggregate {
+	return BaseAggregate{
+		id: id,
+	}
+}
+
+func (a *BaseAggregate) AggregateID() AggregateID {
+	return a.id
+}
+
+// Version returns the version of the stream without pending events.
+func (a *BaseAggregate) Version() int64 {
+	return a.version
+}
+
+func (a *BaseAggregate) SetVersion(version int64) {
+	a.version = version
+}
+
+// Record adds e to the pending events.
+func (a *BaseAggregate) Record(e Event) {
+	a.pending = append(a.pending, e)
+}
+
+// Events returns the pending events.
+func (a *BaseAggregate) Events() []Event {
+	return a.pending
+}
+
+func (a *BaseAggregate) MarkCommitted() {
+	a.pending = nil
+}
@@ -2,20 +2,32 @@ package eventsourcing
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// AnyVersion disables the stream version check of Persist.
+const AnyVersion int64 = -1
+
 type (
 	EventStore struct {
-		logger *logrus.Entry
-		s      map[string]Storage
+		logger        *logrus.Entry
+		s             map[string]Storage
+		snapshotEvery int64
 	}
 
+	// Storage keeps one stream of events per aggregate. The version of a
+	// stream is the number of events in it.
 	Storage interface {
 		Name() string
-		Upsert(a Aggregate) error
-		Load(a Aggregate) error
+		// Append adds events to the stream of a if the stream is still at
+		// expectedVersion and returns the new version. It fails with
+		// ErrConcurrencyConflict otherwise.
+		Append(a Aggregate, events []Event, expectedVersion int64) (int64, error)
+		// Load replays the stream of a and returns its version.
+		Load(a Aggregate) (int64, error)
 		Close() error
 	}
 )
@@ -23,6 +35,10 @@ type (
 var (
 	ErrStorageNotAvailable = errors.New("storage not available")
 	ErrNoAggregateID       = errors.New("no aggregate id")
+	ErrNoEvents            = errors.New("no events to persist")
+	ErrConcurrencyConflict = errors.New("aggregate was changed concurrently")
+	ErrPendingEvents       = errors.New("aggregate has pending events")
+	ErrNotCommittable      = errors.New("aggregate is not committable and storage does not support queries")
 )
 
 func NewEventStore(l logrus.FieldLogger) *EventStore {
@@ -32,14 +48,86 @@ func NewEventStore(l logrus.FieldLogger) *EventStore {
 	}
 }
 
-func (es *EventStore) Persist(storage string, a Aggregate) error {
+// Persist appends the events of a to its stream. expectedVersion is the
+// version a was loaded at, 0 for new aggregates or AnyVersion to skip the
+// check. Persist fails with ErrConcurrencyConflict if another writer appended
+// to the stream in the meantime. Events of a CommittableAggregate are marked
+// committed on success. Other aggregates return their whole history from
+// Events, so events whose ids are already in the stream are skipped; this
+// needs a QueryStorage and fails with ErrNotCommittable otherwise.
+func (es *EventStore) Persist(storage string, a Aggregate, expectedVersion int64) error {
 	if _, isOk := es.s[storage]; !isOk {
 		es.logger.WithField("storage", storage).Error("storage not available")
 		return ErrStorageNotAvailable
 	}
-	return es.s[storage].Upsert(a)
+
+	if a.AggregateID() == "" {
+		es.logger.WithField("storage", storage).Error("aggregate id not available")
+		return ErrNoAggregateID
+	}
+
+	ca, isCommittable := a.(CommittableAggregate)
+	events := a.Events()
+	if !isCommittable && len(events) > 0 {
+		var err error
+		if events, err = unstoredEvents(es.s[storage], a, events); err != nil {
+			return err
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	version, err := es.s[storage].Append(a, events, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	if va, isOk := a.(VersionedAggregate); isOk {
+		va.SetVersion(version)
+	}
+	if isCommittable {
+		ca.MarkCommitted()
+	}
+
+	// snapshots need the state without pending events
+	sa, isSnapshotter := a.(Snapshotter)
+	previous := version - int64(len(events))
+	if isCommittable && isSnapshotter && es.snapshotEvery > 0 && version/es.snapshotEvery > previous/es.snapshotEvery {
+		if err := es.TakeSnapshot(storage, sa); err != nil && !errors.Is(err, ErrSnapshotsNotSupported) {
+			es.logger.WithError(err).WithField("aggregate", a.AggregateID()).Warn("could not take snapshot")
+		}
+	}
+
+	return nil
+}
+
+// unstoredEvents returns the events whose ids are not in the stream of a.
+func unstoredEvents(s Storage, a Aggregate, events []Event) ([]Event, error) {
+	qs, isOk := s.(QueryStorage)
+	if !isOk {
+		return nil, fmt.Errorf("%w: %s", ErrNotCommittable, s.Name())
+	}
+	stored, err := qs.ReadStream(a.AggregateID(), 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[EventID]bool, len(stored))
+	for _, e := range stored {
+		ids[e.ID] = true
+	}
+
+	var unstored []Event
+	for _, e := range events {
+		if !ids[e.Meta().ID] {
+			unstored = append(unstored, e)
+		}
+	}
+	return unstored, nil
 }
 
+// Load replays the stream of a. CommittableAggregate aggregates must not have
+// pending events; the replayed events are marked committed.
 func (es *EventStore) Load(storage string, a Aggregate) error {
 	if _, isOk := es.s[storage]; !isOk {
 		es.logger.WithField("storage", storage).Error("storage not available")
@@ -51,9 +139,96 @@ func (es *EventStore) Load(storage string, a Aggregate) error {
 		return ErrNoAggregateID
 	}
 
-	return es.s[storage].Load(a)
+	ca, isCommittable := a.(CommittableAggregate)
+	if isCommittable && len(ca.Events()) > 0 {
+		return ErrPendingEvents
+	}
+
+	version, err := es.load(es.s[storage], a)
+	if err != nil {
+		return err
+	}
+
+	if va, isOk := a.(VersionedAggregate); isOk {
+		va.SetVersion(version)
+	}
+	if isCommittable {
+		ca.MarkCommitted()
+	}
+	return nil
+}
+
+// load restores the latest snapshot of a if both a and s support it and
+// replays the newer events.
+func (es *EventStore) load(s Storage, a Aggregate) (int64, error) {
+	sa, isSnapshotter := a.(Snapshotter)
+	ss, hasSnapshots := s.(SnapshotStorage)
+	if !isSnapshotter || !hasSnapshots {
+		return s.Load(a)
+	}
+
+	snapshot, isOk, err := ss.LatestSnapshot(a.AggregateID())
+	if err != nil {
+		return 0, err
+	}
+	if !isOk {
+		return s.Load(a)
+	}
+
+	if err := sa.RestoreSnapshot(snapshot.Data); err != nil {
+		return 0, err
+	}
+
+	version, err := ss.LoadAfter(a, snapshot.Version)
+	if err != nil {
+		return 0, err
+	}
+	if version < snapshot.Version {
+		return snapshot.Version, nil
+	}
+	return version, nil
+}
+
+// TakeSnapshot stores the state of a at its current version. a must not have
+// pending events.
+func (es *EventStore) TakeSnapshot(storage string, a Snapshotter) error {
+	if _, isOk := es.s[storage]; !isOk {
+		es.logger.WithField("storage", storage).Error("storage not available")
+		return ErrStorageNotAvailable
+	}
+
+	ss, isOk := es.s[storage].(SnapshotStorage)
+	if !isOk {
+		return ErrSnapshotsNotSupported
+	}
+	if len(a.Events()) > 0 {
+		return ErrPendingEvents
+	}
+
+	data, err := a.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	return ss.SaveSnapshot(Snapshot{
+		AggregateID: a.AggregateID(),
+		Version:     a.Version(),
+		CreatedAt:   time.Now(),
+		Data:        data,
+	})
+}
+
+// SnapshotEvery makes Persist take a snapshot of Snapshotter aggregates
+// whenever their stream passes a multiple of n events. n <= 0 disables it.
+func (es *EventStore) SnapshotEvery(n int64) {
+	es.snapshotEvery = n
 }
 
 func (es *EventStore) AddStorage(s Storage) {
 	es.s[s.Name()] = s
 }
+
+// ConflictError returns ErrConcurrencyConflict with both versions.
+func ConflictError(id AggregateID, expected int64, actual int64) error {
+	return fmt.Errorf("%w: %s expected version %d, got %d", ErrConcurrencyConflict, id, expected, actual)
+}
@@ -0,0 +1,215 @@
+package eventsourcing_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/eventstore/storage/memory"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	testEvent struct {
+		M     eventsourcing.Meta `json:"meta"`
+		Value int                `json:"value"`
+	}
+
+	// historyAggregate is not committable, Events returns every applied
+	// event including the replayed ones.
+	historyAggregate struct {
+		id     eventsourcing.AggregateID
+		events []eventsourcing.Event
+	}
+
+	// committableAggregate returns only pending events.
+	committableAggregate struct {
+		eventsourcing.BaseAggregate
+		values []int
+	}
+
+	// plainStorage hides the optional interfaces of the wrapped storage.
+	plainStorage struct {
+		eventsourcing.Storage
+	}
+)
+
+func (e *testEvent) Meta() eventsourcing.Meta {
+	return e.M
+}
+
+func (a *historyAggregate) AggregateID() eventsourcing.AggregateID {
+	return a.id
+}
+
+func (a *historyAggregate) On(e eventsourcing.Event) error {
+	a.events = append(a.events, e)
+	return nil
+}
+
+func (a *historyAggregate) OnRaw(name string, version string, data []byte) error {
+	e := &testEvent{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return err
+	}
+	return a.On(e)
+}
+
+func (a *historyAggregate) Events() []eventsourcing.Event {
+	return a.events
+}
+
+func (a *committableAggregate) On(e eventsourcing.Event) error {
+	a.values = append(a.values, e.(*testEvent).Value)
+	a.Record(e)
+	return nil
+}
+
+func (a *committableAggregate) OnRaw(name string, version string, data []byte) error {
+	e := &testEvent{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return err
+	}
+	return a.On(e)
+}
+
+func newEvent(value int) *testEvent {
+	return &testEvent{M: eventsourcing.NewMeta("value", "1"), Value: value}
+}
+
+func newTestStore(t *testing.T) (*eventsourcing.EventStore, *memory.Storage) {
+	t.Helper()
+
+	s := memory.NewMemoryEventStorage(logrus.WithField("test", t.Name()))
+	es := eventsourcing.NewEventStore(logrus.WithField("test", t.Name()))
+	es.AddStorage(s)
+	return es, s
+}
+
+func streamValues(t *testing.T, s eventsourcing.QueryStorage, id eventsourcing.AggregateID) string {
+	t.Helper()
+
+	events, err := s.ReadStream(id, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []int
+	for _, e := range events {
+		te := &testEvent{}
+		if err := json.Unmarshal(e.Data, te); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, te.Value)
+	}
+	return fmt.Sprint(values)
+}
+
+func TestPersistSkipsStoredEvents(t *testing.T) {
+	es, s := newTestStore(t)
+
+	a := &historyAggregate{id: "a"}
+	a.On(newEvent(1))
+	a.On(newEvent(2))
+	if err := es.Persist(s.Name(), a, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &historyAggregate{id: "a"}
+	if err := es.Load(s.Name(), loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.On(newEvent(3))
+	if err := es.Persist(s.Name(), loaded, 2); err != nil {
+		t.Fatal(err)
+	}
+	// nothing new
+	if err := es.Persist(s.Name(), loaded, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := streamValues(t, s, "a"), "[1 2 3]"; got != want {
+		t.Fatalf("got stream %s, want %s", got, want)
+	}
+}
+
+func TestPersistCommittable(t *testing.T) {
+	es, s := newTestStore(t)
+
+	a := &committableAggregate{BaseAggregate: eventsourcing.NewBaseAggregate("a")}
+	a.On(newEvent(1))
+	a.On(newEvent(2))
+	if err := es.Persist(s.Name(), a, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Events()) != 0 || a.Version() != 2 {
+		t.Fatalf("got %d pending events at version %d, want 0 at 2", len(a.Events()), a.Version())
+	}
+
+	loaded := &committableAggregate{BaseAggregate: eventsourcing.NewBaseAggregate("a")}
+	if err := es.Load(s.Name(), loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.On(newEvent(3))
+	if err := es.Persist(s.Name(), loaded, loaded.Version()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := streamValues(t, s, "a"), "[1 2 3]"; got != want {
+		t.Fatalf("got stream %s, want %s", got, want)
+	}
+	if err := es.Persist(s.Name(), a, a.Version()); err != nil {
+		t.Fatalf("persist without pending events: %s", err)
+	}
+}
+
+func TestPersistErrors(t *testing.T) {
+	plain := plainStorage{memory.NewMemoryEventStorage(logrus.WithField("test", t.Name()))}
+	es := eventsourcing.NewEventStore(logrus.WithField("test", t.Name()))
+	es.AddStorage(plain)
+
+	committable := func(id eventsourcing.AggregateID) eventsourcing.Aggregate {
+		a := &committableAggregate{BaseAggregate: eventsourcing.NewBaseAggregate(id)}
+		a.On(newEvent(1))
+		return a
+	}
+
+	tests := map[string]struct {
+		storage         string
+		aggregate       eventsourcing.Aggregate
+		expectedVersion int64
+		want            error
+	}{
+		"unknown storage": {
+			storage:   "unknown",
+			aggregate: committable("a"),
+			want:      eventsourcing.ErrStorageNotAvailable,
+		},
+		"no aggregate id": {
+			storage:   plain.Name(),
+			aggregate: committable(""),
+			want:      eventsourcing.ErrNoAggregateID,
+		},
+		"version conflict": {
+			storage:         plain.Name(),
+			aggregate:       committable("a"),
+			expectedVersion: 3,
+			want:            eventsourcing.ErrConcurrencyConflict,
+		},
+		"not committable without queries": {
+			storage:   plain.Name(),
+			aggregate: &historyAggregate{id: "a", events: []eventsourcing.Event{newEvent(1)}},
+			want:      eventsourcing.ErrNotCommittable,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := es.Persist(test.storage, test.aggregate, test.expectedVersion)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package eventsourcing
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,16 +14,37 @@ type (
 		Name      string    `json:"name" yaml:"name"`
 		CreatedAt time.Time `json:"created_at" yaml:"created_at"`
 		Version   string    `json:"version" yaml:"version"`
+		// CorrelationID is shared by all events of one business
+		// transaction, CausationID is the id of the event or request that
+		// caused this event.
+		CorrelationID string `json:"correlation_id,omitempty" yaml:"correlation_id,omitempty"`
+		CausationID   string `json:"causation_id,omitempty" yaml:"causation_id,omitempty"`
+		// Actor is the id of the user that caused the event.
+		Actor string `json:"actor,omitempty" yaml:"actor,omitempty"`
+		// Service is the boot id of the process that created the event.
+		Service string            `json:"service,omitempty" yaml:"service,omitempty"`
+		Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
 	}
+
+	// Metadata is the part of Meta that is propagated from a request or a
+	// consumed message to the events created while handling it.
+	Metadata struct {
+		CorrelationID string
+		CausationID   string
+		Actor         string
+		Headers       map[string]string
+	}
+
+	metadataKey struct{}
+)
+
+var (
+	serviceMutex sync.RWMutex
+	service      string
 )
 
 func NewMeta(name string, version string) Meta {
-	return Meta{
-		ID:        EventID(uuid.New().String()),
-		Name:      name,
-		CreatedAt: time.Now(),
-		Version:   version,
-	}
+	return NewMetaWithID(EventID(uuid.New().String()), name, version)
 }
 
 func NewMetaWithID(id EventID, name string, version string) Meta {
@@ -30,5 +53,73 @@ func NewMetaWithID(id EventID, name string, version string) Meta {
 		Name:      name,
 		CreatedAt: time.Now(),
 		Version:   version,
+		Service:   Service(),
+	}
+}
+
+// NewMetaFromContext returns a Meta carrying the Metadata of ctx, see
+// WithMetadata. Without a correlation id in ctx the event starts a new
+// correlation with its own id.
+func NewMetaFromContext(ctx context.Context, name string, version string) Meta {
+	m := NewMeta(name, version)
+
+	md := MetadataFrom(ctx)
+	m.CorrelationID = md.CorrelationID
+	if m.CorrelationID == "" {
+		m.CorrelationID = string(m.ID)
 	}
+	m.CausationID = md.CausationID
+	m.Actor = md.Actor
+	if len(md.Headers) > 0 {
+		m.Headers = make(map[string]string, len(md.Headers))
+		for k, v := range md.Headers {
+			m.Headers[k] = v
+		}
+	}
+
+	return m
+}
+
+// SetService sets the service id of all metas created afterwards. The
+// eventstore plugin sets it to the boot id.
+func SetService(id string) {
+	serviceMutex.Lock()
+	defer serviceMutex.Unlock()
+
+	service = id
+}
+
+func Service() string {
+	serviceMutex.RLock()
+	defer serviceMutex.RUnlock()
+
+	return service
+}
+
+// WithMetadata returns a copy of ctx carrying md.
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFrom returns the Metadata of ctx or an empty Metadata.
+func MetadataFrom(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return md
+}
+
+// CausedBy returns a copy of ctx whose events are caused by the event with
+// meta m. They join the correlation of m and keep its actor unless ctx
+// already has one.
+func CausedBy(ctx context.Context, m Meta) context.Context {
+	md := MetadataFrom(ctx)
+	md.CausationID = string(m.ID)
+	md.CorrelationID = m.CorrelationID
+	if md.CorrelationID == "" {
+		md.CorrelationID = string(m.ID)
+	}
+	if md.Actor == "" {
+		md.Actor = m.Actor
+	}
+
+	return WithMetadata(ctx, md)
 }
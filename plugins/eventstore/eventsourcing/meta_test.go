@@ -0,0 +1,96 @@
+package eventsourcing_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+)
+
+func TestNewMetaFromContext(t *testing.T) {
+	tests := map[string]struct {
+		md eventsourcing.Metadata
+		// own is replaced by the id of the new event
+		want string
+	}{
+		"no metadata": {
+			want: "own  ",
+		},
+		"request": {
+			md:   eventsourcing.Metadata{CorrelationID: "c-1", CausationID: "r-1", Actor: "u-1"},
+			want: "c-1 r-1 u-1",
+		},
+		"without correlation": {
+			md:   eventsourcing.Metadata{CausationID: "r-1"},
+			want: "own r-1 ",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := eventsourcing.NewMetaFromContext(eventsourcing.WithMetadata(context.Background(), test.md), "created", "1")
+
+			correlation := m.CorrelationID
+			if correlation == string(m.ID) {
+				correlation = "own"
+			}
+			if got := fmt.Sprintf("%s %s %s", correlation, m.CausationID, m.Actor); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewMetaFromContextCopiesHeaders(t *testing.T) {
+	headers := map[string]string{"tenant": "t-1"}
+	ctx := eventsourcing.WithMetadata(context.Background(), eventsourcing.Metadata{Headers: headers})
+
+	m := eventsourcing.NewMetaFromContext(ctx, "created", "1")
+	headers["tenant"] = "changed"
+	if m.Headers["tenant"] != "t-1" {
+		t.Fatalf("got headers %v, want a copy", m.Headers)
+	}
+}
+
+func TestNewMetaService(t *testing.T) {
+	defer eventsourcing.SetService(eventsourcing.Service())
+	eventsourcing.SetService("s-1")
+
+	if m := eventsourcing.NewMeta("created", "1"); m.Service != "s-1" {
+		t.Fatalf("got service %q, want s-1", m.Service)
+	}
+}
+
+func TestCausedBy(t *testing.T) {
+	tests := map[string]struct {
+		md   eventsourcing.Metadata
+		m    eventsourcing.Meta
+		want string
+	}{
+		"joins the correlation": {
+			m:    eventsourcing.Meta{ID: "e-1", CorrelationID: "c-1", Actor: "u-1"},
+			want: "c-1 e-1 u-1",
+		},
+		"starts a correlation": {
+			m:    eventsourcing.Meta{ID: "e-1"},
+			want: "e-1 e-1 ",
+		},
+		"keeps the actor": {
+			md:   eventsourcing.Metadata{CorrelationID: "c-0", CausationID: "r-0", Actor: "u-0"},
+			m:    eventsourcing.Meta{ID: "e-1", CorrelationID: "c-1", Actor: "u-1"},
+			want: "c-1 e-1 u-0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := eventsourcing.CausedBy(eventsourcing.WithMetadata(context.Background(), test.md), test.m)
+
+			md := eventsourcing.MetadataFrom(ctx)
+			if got := fmt.Sprintf("%s %s %s", md.CorrelationID, md.CausationID, md.Actor); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
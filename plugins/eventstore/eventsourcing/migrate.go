@@ -0,0 +1,243 @@
+package eventsourcing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// Migrator copies all aggregates from one storage to another. Runs are
+	// idempotent: events the target already has are verified instead of
+	// copied, so a migration can be repeated while a Replicated storage
+	// mirrors new events to the target.
+	Migrator struct {
+		logger    logrus.FieldLogger
+		from      QueryStorage
+		to        QueryStorage
+		batchSize int
+	}
+
+	// MigrationReport sums up a migration or verification run.
+	MigrationReport struct {
+		Aggregates int   `json:"aggregates"`
+		Events     int64 `json:"events"`
+		Copied     int64 `json:"copied"`
+	}
+
+	// rawEvent appends a recorded event unchanged.
+	rawEvent struct {
+		meta Meta
+		data []byte
+	}
+
+	// streamAggregate only identifies the stream to append to.
+	streamAggregate struct {
+		id AggregateID
+	}
+)
+
+var (
+	ErrMigrationMismatch = errors.New("migrated stream does not match source")
+)
+
+func NewMigrator(l logrus.FieldLogger, from QueryStorage, to QueryStorage) *Migrator {
+	return &Migrator{
+		logger:    l.WithField("module", "migrator").WithField("from", from.Name()).WithField("to", to.Name()),
+		from:      from,
+		to:        to,
+		batchSize: DefaultQueryLimit,
+	}
+}
+
+// Run copies the events missing in the target and verifies every stream
+// afterwards.
+func (m *Migrator) Run(ctx context.Context) (MigrationReport, error) {
+	return m.each(ctx, true)
+}
+
+// Verify compares the streams of both storages without copying.
+func (m *Migrator) Verify(ctx context.Context) (MigrationReport, error) {
+	return m.each(ctx, false)
+}
+
+func (m *Migrator) each(ctx context.Context, isCopy bool) (MigrationReport, error) {
+	var report MigrationReport
+
+	var after AggregateID
+	for {
+		ids, err := m.from.AggregateIDs(after, m.batchSize)
+		if err != nil {
+			return report, err
+		}
+
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+
+			events, copied, err := m.migrate(id, isCopy)
+			if err != nil {
+				return report, fmt.Errorf("aggregate %s: %w", id, err)
+			}
+			report.Aggregates++
+			report.Events += events
+			report.Copied += copied
+		}
+
+		if len(ids) < m.batchSize {
+			break
+		}
+		after = ids[len(ids)-1]
+	}
+
+	m.logger.WithFields(logrus.Fields{"aggregates": report.Aggregates, "events": report.Events, "copied": report.Copied}).
+		Info("migration finished")
+
+	return report, nil
+}
+
+// migrate copies the events of id the target is missing if isCopy is set
+// and verifies that the target starts with the source stream.
+func (m *Migrator) migrate(id AggregateID, isCopy bool) (int64, int64, error) {
+	source, err := m.from.ReadStream(id, 1, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	target, err := m.to.ReadStream(id, 1, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var copied int64
+	if isCopy && len(target) < len(source) {
+		if err := verifyStream(source[:len(target)], target); err != nil {
+			return 0, 0, err
+		}
+
+		missing := source[len(target):]
+		events := make([]Event, 0, len(missing))
+		for _, e := range missing {
+			re, err := newRawEvent(e)
+			if err != nil {
+				return 0, 0, err
+			}
+			events = append(events, re)
+		}
+
+		if _, err := m.to.Append(&streamAggregate{id: id}, events, int64(len(target))); err != nil {
+			return 0, 0, err
+		}
+		copied = int64(len(missing))
+
+		if target, err = m.to.ReadStream(id, 1, int64(len(source))); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if len(target) < len(source) {
+		return 0, 0, fmt.Errorf("%w: %d of %d events", ErrMigrationMismatch, len(target), len(source))
+	}
+	if err := verifyStream(source, target[:len(source)]); err != nil {
+		return 0, 0, err
+	}
+
+	return int64(len(source)), copied, nil
+}
+
+// verifyStream compares the checksums of two streams of equal length.
+func verifyStream(source []RecordedEvent, target []RecordedEvent) error {
+	want, err := StreamChecksum(source)
+	if err != nil {
+		return err
+	}
+	got, err := StreamChecksum(target)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w: checksum %s, want %s", ErrMigrationMismatch, got, want)
+	}
+	return nil
+}
+
+// StreamChecksum hashes the sequence, type and payload of events. Payloads are
+// compared as json values, so storages may reorder keys or whitespace.
+func StreamChecksum(events []RecordedEvent) (string, error) {
+	h := sha256.New()
+	for _, e := range events {
+		var payload interface{}
+		if err := json.Unmarshal(e.Data, &payload); err != nil {
+			return "", fmt.Errorf("sequence %d: %w", e.Sequence, err)
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return "", err
+		}
+
+		for _, field := range [][]byte{[]byte(strconv.FormatInt(e.Sequence, 10)), []byte(e.Name), []byte(e.Version), data} {
+			h.Write([]byte(strconv.Itoa(len(field))))
+			h.Write([]byte{':'})
+			h.Write(field)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newRawEvent restores the meta object of e from its payload and the stored
+// type and metadata.
+func newRawEvent(e RecordedEvent) (*rawEvent, error) {
+	var payload struct {
+		Meta Meta `json:"meta"`
+	}
+	if err := json.Unmarshal(e.Data, &payload); err != nil {
+		return nil, fmt.Errorf("sequence %d: %w", e.Sequence, err)
+	}
+
+	meta := payload.Meta
+	if meta.ID == "" {
+		meta.ID = EventID(uuid.New().String())
+	}
+	meta.Name = e.Name
+	meta.Version = e.Version
+	meta.CreatedAt = e.CreatedAt
+	meta.CorrelationID = e.CorrelationID
+	meta.CausationID = e.CausationID
+	meta.Actor = e.Actor
+	meta.Service = e.Service
+	meta.Headers = e.Headers
+
+	return &rawEvent{meta: meta, data: e.Data}, nil
+}
+
+func (e *rawEvent) Meta() Meta {
+	return e.meta
+}
+
+func (e *rawEvent) MarshalJSON() ([]byte, error) {
+	return e.data, nil
+}
+
+func (a *streamAggregate) AggregateID() AggregateID {
+	return a.id
+}
+
+func (a *streamAggregate) On(e Event) error {
+	return nil
+}
+
+func (a *streamAggregate) OnRaw(name string, version string, e []byte) error {
+	return nil
+}
+
+func (a *streamAggregate) Events() []Event {
+	return nil
+}
@@ -0,0 +1,130 @@
+package eventsourcing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMigrator(t *testing.T) {
+	from, to := newMemoryStorage(t), newMemoryStorage(t)
+	first := newEvent(1)
+	if _, err := from.Append(&historyAggregate{id: "a"}, []eventsourcing.Event{first, newEvent(2)}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := from.Append(&historyAggregate{id: "b"}, []eventsourcing.Event{newEvent(1), newEvent(2)}, 0); err != nil {
+		t.Fatal(err)
+	}
+	// the target already has the first event of a
+	if _, err := to.Append(&historyAggregate{id: "a"}, []eventsourcing.Event{first}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	m := eventsourcing.NewMigrator(logrus.WithField("test", t.Name()), from, to)
+	report, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Aggregates != 2 || report.Events != 4 || report.Copied != 3 {
+		t.Fatalf("got report %+v, want 2 aggregates, 4 events, 3 copied", report)
+	}
+
+	report, err = m.Run(context.Background())
+	if err != nil || report.Copied != 0 {
+		t.Fatalf("second run: got report %+v, %v, want nothing copied", report, err)
+	}
+	if _, err := m.Verify(context.Background()); err != nil {
+		t.Fatalf("verify: %s", err)
+	}
+	if got := streamValues(t, to, "b"); got != "[1 2]" {
+		t.Fatalf("got migrated stream %s, want [1 2]", got)
+	}
+}
+
+func TestMigratorMismatch(t *testing.T) {
+	tests := map[string]struct {
+		target []int
+		isCopy bool
+	}{
+		"missing events": {
+			target: []int{1},
+		},
+		"diverging payload": {
+			target: []int{1, 3},
+		},
+		"diverging payload before copy": {
+			target: []int{3},
+			isCopy: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			from, to := newMemoryStorage(t), newMemoryStorage(t)
+			if _, err := from.Append(&historyAggregate{id: "a"}, []eventsourcing.Event{newEvent(1), newEvent(2)}, 0); err != nil {
+				t.Fatal(err)
+			}
+			var events []eventsourcing.Event
+			for _, v := range test.target {
+				events = append(events, newEvent(v))
+			}
+			if _, err := to.Append(&historyAggregate{id: "a"}, events, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			m := eventsourcing.NewMigrator(logrus.WithField("test", t.Name()), from, to)
+			run := m.Verify
+			if test.isCopy {
+				run = m.Run
+			}
+			if _, err := run(context.Background()); !errors.Is(err, eventsourcing.ErrMigrationMismatch) {
+				t.Fatalf("got error %v, want %v", err, eventsourcing.ErrMigrationMismatch)
+			}
+		})
+	}
+}
+
+func TestStreamChecksum(t *testing.T) {
+	event := func(sequence int64, name string, version string, data string) eventsourcing.RecordedEvent {
+		return eventsourcing.RecordedEvent{Sequence: sequence, Name: name, Version: version, Data: []byte(data)}
+	}
+	base := event(1, "created", "1", `{"a":1,"b":2}`)
+
+	tests := map[string]struct {
+		e     eventsourcing.RecordedEvent
+		equal bool
+	}{
+		"same event":      {e: base, equal: true},
+		"key order":       {e: event(1, "created", "1", `{"b":2,"a":1}`), equal: true},
+		"whitespace":      {e: event(1, "created", "1", `{ "a": 1, "b": 2 }`), equal: true},
+		"other position":  {e: eventsourcing.RecordedEvent{Position: 9, Sequence: 1, Name: "created", Version: "1", Data: base.Data}, equal: true},
+		"sequence":        {e: event(2, "created", "1", `{"a":1,"b":2}`)},
+		"name":            {e: event(1, "changed", "1", `{"a":1,"b":2}`)},
+		"version":         {e: event(1, "created", "2", `{"a":1,"b":2}`)},
+		"payload":         {e: event(1, "created", "1", `{"a":1,"b":3}`)},
+		"shifted lengths": {e: event(1, "created1", "", `{"a":1,"b":2}`)},
+	}
+
+	want, err := eventsourcing.StreamChecksum([]eventsourcing.RecordedEvent{base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := eventsourcing.StreamChecksum([]eventsourcing.RecordedEvent{test.e})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == want) != test.equal {
+				t.Fatalf("got checksum %s, base %s, want equal %t", got, want, test.equal)
+			}
+		})
+	}
+
+	if _, err := eventsourcing.StreamChecksum([]eventsourcing.RecordedEvent{event(1, "created", "1", `x`)}); err == nil {
+		t.Fatal("no error for an invalid payload")
+	}
+}
@@ -0,0 +1,25 @@
+package eventsourcing
+
+import "time"
+
+type (
+	// OutboxEntry is an event waiting to be published. Storages write it in
+	// the same transaction as the event itself.
+	OutboxEntry struct {
+		ID       int64
+		EventID  EventID
+		Attempts int
+		Event    RecordedEvent
+	}
+
+	// Outbox is implemented by storages that keep a transactional outbox.
+	Outbox interface {
+		// PendingOutbox returns up to limit unsent entries that are due,
+		// ordered by position. Entries of an aggregate with an earlier
+		// entry still waiting for a retry are held back.
+		PendingOutbox(limit int) ([]OutboxEntry, error)
+		MarkSent(id int64) error
+		// MarkFailed records err and schedules the next attempt.
+		MarkFailed(id int64, nextAttempt time.Time, err error) error
+	}
+)
@@ -0,0 +1,154 @@
+package eventsourcing
+
+import (
+	"errors"
+	"time"
+)
+
+type (
+	// EventFilter selects events of the global stream. Zero fields do not
+	// filter.
+	EventFilter struct {
+		AggregateID AggregateID
+		Name        string
+		Version     string
+		// CorrelationID selects all events of one business transaction.
+		CorrelationID string
+		// Since is inclusive, Until exclusive. Both compare the creation
+		// time in the meta object of the event.
+		Since time.Time
+		Until time.Time
+		// After skips the events up to this position, e.g. the position of
+		// the last event of the previous page.
+		After int64
+		// Limit is ignored by CountEvents.
+		Limit int
+	}
+
+	// QueryStorage is implemented by storages that can read raw events
+	// without replaying them into an aggregate.
+	QueryStorage interface {
+		Storage
+		// ReadStream returns the events of the aggregate with sequences from
+		// from to to, both inclusive. to <= 0 reads to the end of the stream.
+		ReadStream(id AggregateID, from int64, to int64) ([]RecordedEvent, error)
+		// EventByID returns false if no event has the id.
+		EventByID(id EventID) (RecordedEvent, bool, error)
+		// ReadEvents returns the events matching f ordered by position.
+		ReadEvents(f EventFilter) ([]RecordedEvent, error)
+		// AggregateIDs returns up to limit ids greater than after in
+		// ascending order.
+		AggregateIDs(after AggregateID, limit int) ([]AggregateID, error)
+		CountEvents(f EventFilter) (int64, error)
+	}
+)
+
+// DefaultQueryLimit is used by ReadEvents and AggregateIDs if no limit is
+// given.
+const DefaultQueryLimit = 100
+
+var (
+	ErrQueriesNotSupported = errors.New("storage does not support queries")
+)
+
+// Matches reports whether e passes all conditions of f except After and
+// Limit.
+func (f EventFilter) Matches(e RecordedEvent) bool {
+	if f.AggregateID != "" && e.AggregateID != f.AggregateID {
+		return false
+	}
+	if f.Name != "" && e.Name != f.Name {
+		return false
+	}
+	if f.Version != "" && e.Version != f.Version {
+		return false
+	}
+	if f.CorrelationID != "" && e.CorrelationID != f.CorrelationID {
+		return false
+	}
+	if !f.Since.IsZero() && e.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.CreatedAt.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// ReadStream returns the raw events of the aggregate from version from to
+// to, see QueryStorage.
+func (es *EventStore) ReadStream(storage string, id AggregateID, from int64, to int64) ([]RecordedEvent, error) {
+	qs, err := es.queryStorage(storage)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, ErrNoAggregateID
+	}
+	if from < 1 {
+		from = 1
+	}
+
+	return qs.ReadStream(id, from, to)
+}
+
+// EventByID returns the raw event with the id or false if it does not exist.
+func (es *EventStore) EventByID(storage string, id EventID) (RecordedEvent, bool, error) {
+	qs, err := es.queryStorage(storage)
+	if err != nil {
+		return RecordedEvent{}, false, err
+	}
+
+	return qs.EventByID(id)
+}
+
+// ReadEvents returns the raw events matching f across all aggregates.
+func (es *EventStore) ReadEvents(storage string, f EventFilter) ([]RecordedEvent, error) {
+	qs, err := es.queryStorage(storage)
+	if err != nil {
+		return nil, err
+	}
+	if f.Limit <= 0 {
+		f.Limit = DefaultQueryLimit
+	}
+
+	return qs.ReadEvents(f)
+}
+
+// AggregateIDs lists the known aggregates page by page. Pass the last id of
+// the previous page as after.
+func (es *EventStore) AggregateIDs(storage string, after AggregateID, limit int) ([]AggregateID, error) {
+	qs, err := es.queryStorage(storage)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+
+	return qs.AggregateIDs(after, limit)
+}
+
+// CountEvents returns the number of events matching f.
+func (es *EventStore) CountEvents(storage string, f EventFilter) (int64, error) {
+	qs, err := es.queryStorage(storage)
+	if err != nil {
+		return 0, err
+	}
+
+	return qs.CountEvents(f)
+}
+
+func (es *EventStore) queryStorage(storage string) (QueryStorage, error) {
+	s, isOk := es.s[storage]
+	if !isOk {
+		es.logger.WithField("storage", storage).Error("storage not available")
+		return nil, ErrStorageNotAvailable
+	}
+
+	qs, isOk := s.(QueryStorage)
+	if !isOk {
+		return nil, ErrQueriesNotSupported
+	}
+	return qs, nil
+}
@@ -0,0 +1,184 @@
+package eventsourcing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type (
+	// EventType identifies the payload shape of an event by the name and
+	// version stored in its Meta.
+	EventType struct {
+		Name    string
+		Version string
+	}
+
+	// Decoder builds an event from its stored payload.
+	Decoder func(data []byte) (Event, error)
+
+	// Upcaster migrates a stored payload to the next version of its event
+	// type.
+	Upcaster func(data []byte) ([]byte, error)
+
+	// Registry decodes stored events by their type. Payloads of outdated
+	// versions are migrated by the registered upcasters first, so aggregates
+	// only handle the current event shapes.
+	Registry struct {
+		mutex     sync.RWMutex
+		decoders  map[EventType]Decoder
+		upcasters map[EventType]upcaster
+	}
+
+	upcaster struct {
+		to string
+		f  Upcaster
+	}
+)
+
+var (
+	ErrUnknownEventType   = errors.New("unknown event type")
+	ErrDuplicateEventType = errors.New("event type registered twice")
+	ErrUpcastCycle        = errors.New("upcaster cycle")
+)
+
+func NewRegistry() *Registry {
+	return &Registry{
+		decoders:  map[EventType]Decoder{},
+		upcasters: map[EventType]upcaster{},
+	}
+}
+
+func (t EventType) String() string {
+	return t.Name + "@" + t.Version
+}
+
+// Register adds the decoder of the event type name@version.
+func (r *Registry) Register(name string, version string, d Decoder) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	t := EventType{Name: name, Version: version}
+	if _, isOk := r.decoders[t]; isOk {
+		return fmt.Errorf("%w: %s", ErrDuplicateEventType, t)
+	}
+	r.decoders[t] = d
+
+	return nil
+}
+
+// Upcast migrates payloads of name@from to name@to with f when they are
+// decoded. Upcasters chain, e.g. 1.0 -> 2.0 -> 3.0. The version in the meta
+// object of the payload is updated after every step.
+func (r *Registry) Upcast(name string, from string, to string, f Upcaster) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	t := EventType{Name: name, Version: from}
+	if _, isOk := r.upcasters[t]; isOk {
+		return fmt.Errorf("%w: upcaster for %s", ErrDuplicateEventType, t)
+	}
+	r.upcasters[t] = upcaster{to: to, f: f}
+
+	return nil
+}
+
+// Decode upcasts data to the newest registered version of name and decodes
+// it.
+func (r *Registry) Decode(name string, version string, data []byte) (Event, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	t := EventType{Name: name, Version: version}
+	seen := map[EventType]bool{}
+	for {
+		up, isOk := r.upcasters[t]
+		if !isOk {
+			break
+		}
+		if seen[t] {
+			return nil, fmt.Errorf("%w: %s", ErrUpcastCycle, t)
+		}
+		seen[t] = true
+
+		upcasted, err := up.f(data)
+		if err != nil {
+			return nil, fmt.Errorf("upcast %s to %s: %w", t, up.to, err)
+		}
+		t.Version = up.to
+		if data, err = withMetaVersion(upcasted, t.Version); err != nil {
+			return nil, fmt.Errorf("upcast %s: %w", t, err)
+		}
+	}
+
+	d, isOk := r.decoders[t]
+	if !isOk {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, t)
+	}
+
+	e, err := d(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", t, err)
+	}
+	return e, nil
+}
+
+// Apply decodes the event and passes it to a.On. Aggregates use it to
+// implement OnRaw:
+//
+//	func (a *MyAggregate) OnRaw(name string, version string, e []byte) error {
+//		return events.Apply(a, name, version, e)
+//	}
+func (r *Registry) Apply(a Aggregate, name string, version string, data []byte) error {
+	e, err := r.Decode(name, version, data)
+	if err != nil {
+		return err
+	}
+	return a.On(e)
+}
+
+// JSONDecoder returns a Decoder unmarshalling the payload into a new T, e.g.
+// JSONDecoder[MyEvent]() for events implemented by *MyEvent.
+func JSONDecoder[T any, PT interface {
+	*T
+	Event
+}]() Decoder {
+	return func(data []byte) (Event, error) {
+		e := PT(new(T))
+		if err := json.Unmarshal(data, e); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+}
+
+// withMetaVersion sets meta.version of a json payload. Payloads without a
+// meta object are returned unchanged.
+func withMetaVersion(data []byte, version string) ([]byte, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	rawMeta, isOk := payload["meta"]
+	if !isOk {
+		return data, nil
+	}
+
+	var meta map[string]json.RawMessage
+	if err := json.Unmarshal(rawMeta, &meta); err != nil {
+		return nil, err
+	}
+
+	v, err := json.Marshal(version)
+	if err != nil {
+		return nil, err
+	}
+	meta["version"] = v
+
+	if payload["meta"], err = json.Marshal(meta); err != nil {
+		return nil, err
+	}
+	return json.Marshal(payload)
+}
@@ -0,0 +1,158 @@
+package eventsourcing_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+)
+
+// renameUpcaster replaces old with new in the payload.
+func renameUpcaster(old string, new string) eventsourcing.Upcaster {
+	return func(data []byte) ([]byte, error) {
+		return bytes.ReplaceAll(data, []byte(old), []byte(new)), nil
+	}
+}
+
+func TestRegistryDecode(t *testing.T) {
+	errUpcast := errors.New("upcast failed")
+
+	newRegistry := func(t *testing.T) *eventsourcing.Registry {
+		r := eventsourcing.NewRegistry()
+		if err := r.Register("value", "3", eventsourcing.JSONDecoder[testEvent]()); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Upcast("value", "1", "2", renameUpcaster(`"v"`, `"val"`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Upcast("value", "2", "3", renameUpcaster(`"val"`, `"value"`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Upcast("broken", "1", "2", func([]byte) ([]byte, error) { return nil, errUpcast }); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Upcast("cycle", "1", "2", renameUpcaster("", "")); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Upcast("cycle", "2", "1", renameUpcaster("", "")); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	tests := map[string]struct {
+		name        string
+		version     string
+		data        string
+		wantVersion string
+		wantValue   int
+		wantErr     error
+	}{
+		"current version": {
+			name:        "value",
+			version:     "3",
+			data:        `{"meta":{"version":"3"},"value":3}`,
+			wantVersion: "3",
+			wantValue:   3,
+		},
+		"one step": {
+			name:        "value",
+			version:     "2",
+			data:        `{"meta":{"version":"2"},"val":2}`,
+			wantVersion: "3",
+			wantValue:   2,
+		},
+		"chain": {
+			name:        "value",
+			version:     "1",
+			data:        `{"meta":{"version":"1"},"v":1}`,
+			wantVersion: "3",
+			wantValue:   1,
+		},
+		"chain without meta": {
+			name:      "value",
+			version:   "1",
+			data:      `{"v":1}`,
+			wantValue: 1,
+		},
+		"unknown type": {
+			name:    "unknown",
+			version: "1",
+			data:    `{}`,
+			wantErr: eventsourcing.ErrUnknownEventType,
+		},
+		"no decoder for upcasted version": {
+			name:    "broken",
+			version: "2",
+			data:    `{}`,
+			wantErr: eventsourcing.ErrUnknownEventType,
+		},
+		"failing upcaster": {
+			name:    "broken",
+			version: "1",
+			data:    `{}`,
+			wantErr: errUpcast,
+		},
+		"cycle": {
+			name:    "cycle",
+			version: "1",
+			data:    `{}`,
+			wantErr: eventsourcing.ErrUpcastCycle,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := newRegistry(t).Decode(test.name, test.version, []byte(test.data))
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("got error %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			te := e.(*testEvent)
+			if te.Value != test.wantValue || te.M.Version != test.wantVersion {
+				t.Fatalf("got value %d at version %q, want %d at %q", te.Value, te.M.Version, test.wantValue, test.wantVersion)
+			}
+		})
+	}
+}
+
+func TestRegistryDuplicates(t *testing.T) {
+	r := eventsourcing.NewRegistry()
+	if err := r.Register("value", "1", eventsourcing.JSONDecoder[testEvent]()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("value", "1", eventsourcing.JSONDecoder[testEvent]()); !errors.Is(err, eventsourcing.ErrDuplicateEventType) {
+		t.Fatalf("got error %v, want %v", err, eventsourcing.ErrDuplicateEventType)
+	}
+
+	if err := r.Upcast("value", "0", "1", renameUpcaster("", "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Upcast("value", "0", "2", renameUpcaster("", "")); !errors.Is(err, eventsourcing.ErrDuplicateEventType) {
+		t.Fatalf("got error %v, want %v", err, eventsourcing.ErrDuplicateEventType)
+	}
+}
+
+func TestRegistryApply(t *testing.T) {
+	r := eventsourcing.NewRegistry()
+	if err := r.Register("value", "2", eventsourcing.JSONDecoder[testEvent]()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Upcast("value", "1", "2", renameUpcaster(`"v"`, `"value"`)); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &committableAggregate{BaseAggregate: eventsourcing.NewBaseAggregate("a")}
+	if err := r.Apply(a, "value", "1", []byte(`{"v":7}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.values) != 1 || a.values[0] != 7 {
+		t.Fatalf("got values %v, want [7]", a.values)
+	}
+}
@@ -0,0 +1,191 @@
+package eventsourcing
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// WritePolicy decides how Replicated handles failing secondaries.
+	WritePolicy string
+
+	// Replicated is a Storage writing to a primary and mirroring every
+	// append to secondaries. Reads always use the primary. The methods of
+	// SnapshotStorage, QueryStorage and StreamStorage are passed to the
+	// primary and fail with the matching not supported error if the primary
+	// does not implement them.
+	Replicated struct {
+		logger      logrus.FieldLogger
+		name        string
+		policy      WritePolicy
+		primary     Storage
+		secondaries []Storage
+	}
+)
+
+const (
+	// PolicyMirror fails only if the primary fails. Secondaries that fail
+	// are logged and fall behind until they are migrated again.
+	PolicyMirror WritePolicy = "mirror"
+	// PolicyAll fails if any storage fails. Storages that succeeded before
+	// the failure keep the events, there is no distributed rollback.
+	PolicyAll WritePolicy = "all"
+)
+
+var (
+	ErrUnknownWritePolicy = errors.New("unknown write policy")
+	ErrReplicationFailed  = errors.New("replication to secondary storage failed")
+)
+
+// NewReplicated returns a storage named name replicating primary to
+// secondaries.
+func NewReplicated(l logrus.FieldLogger, name string, policy WritePolicy, primary Storage, secondaries ...Storage) (*Replicated, error) {
+	if policy != PolicyMirror && policy != PolicyAll {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWritePolicy, policy)
+	}
+
+	return &Replicated{
+		logger:      l.WithField("module", "replication").WithField("storage", name),
+		name:        name,
+		policy:      policy,
+		primary:     primary,
+		secondaries: secondaries,
+	}, nil
+}
+
+func (r *Replicated) Name() string {
+	return r.name
+}
+
+// Primary returns the storage reads are served from.
+func (r *Replicated) Primary() Storage {
+	return r.primary
+}
+
+// Append appends to the primary first. Secondaries are expected to be at the
+// version the primary was at, so a secondary that fell behind fails with
+// ErrConcurrencyConflict instead of storing events at the wrong version.
+func (r *Replicated) Append(a Aggregate, events []Event, expectedVersion int64) (int64, error) {
+	version, err := r.primary.Append(a, events, expectedVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	previous := version - int64(len(events))
+	var errs []error
+	for _, s := range r.secondaries {
+		if _, err := s.Append(a, events, previous); err != nil {
+			r.logger.WithError(err).WithFields(logrus.Fields{"secondary": s.Name(), "aggregate": a.AggregateID()}).
+				Warn("could not replicate events")
+			errs = append(errs, fmt.Errorf("%w: %s: %w", ErrReplicationFailed, s.Name(), err))
+		}
+	}
+	if r.policy == PolicyAll && len(errs) > 0 {
+		return version, errors.Join(errs...)
+	}
+
+	return version, nil
+}
+
+func (r *Replicated) Load(a Aggregate) (int64, error) {
+	return r.primary.Load(a)
+}
+
+func (r *Replicated) LoadAfter(a Aggregate, version int64) (int64, error) {
+	ss, isOk := r.primary.(SnapshotStorage)
+	if !isOk {
+		return 0, ErrSnapshotsNotSupported
+	}
+	return ss.LoadAfter(a, version)
+}
+
+// SaveSnapshot saves to the primary only. Snapshots can be rebuilt from the
+// events and are not replicated.
+func (r *Replicated) SaveSnapshot(s Snapshot) error {
+	ss, isOk := r.primary.(SnapshotStorage)
+	if !isOk {
+		return ErrSnapshotsNotSupported
+	}
+	return ss.SaveSnapshot(s)
+}
+
+func (r *Replicated) LatestSnapshot(id AggregateID) (Snapshot, bool, error) {
+	ss, isOk := r.primary.(SnapshotStorage)
+	if !isOk {
+		return Snapshot{}, false, nil
+	}
+	return ss.LatestSnapshot(id)
+}
+
+func (r *Replicated) ReadStream(id AggregateID, from int64, to int64) ([]RecordedEvent, error) {
+	qs, isOk := r.primary.(QueryStorage)
+	if !isOk {
+		return nil, ErrQueriesNotSupported
+	}
+	return qs.ReadStream(id, from, to)
+}
+
+func (r *Replicated) EventByID(id EventID) (RecordedEvent, bool, error) {
+	qs, isOk := r.primary.(QueryStorage)
+	if !isOk {
+		return RecordedEvent{}, false, ErrQueriesNotSupported
+	}
+	return qs.EventByID(id)
+}
+
+func (r *Replicated) ReadEvents(f EventFilter) ([]RecordedEvent, error) {
+	qs, isOk := r.primary.(QueryStorage)
+	if !isOk {
+		return nil, ErrQueriesNotSupported
+	}
+	return qs.ReadEvents(f)
+}
+
+func (r *Replicated) AggregateIDs(after AggregateID, limit int) ([]AggregateID, error) {
+	qs, isOk := r.primary.(QueryStorage)
+	if !isOk {
+		return nil, ErrQueriesNotSupported
+	}
+	return qs.AggregateIDs(after, limit)
+}
+
+func (r *Replicated) CountEvents(f EventFilter) (int64, error) {
+	qs, isOk := r.primary.(QueryStorage)
+	if !isOk {
+		return 0, ErrQueriesNotSupported
+	}
+	return qs.CountEvents(f)
+}
+
+func (r *Replicated) ReadAll(after int64, limit int) ([]RecordedEvent, error) {
+	ss, isOk := r.primary.(StreamStorage)
+	if !isOk {
+		return nil, ErrStreamNotSupported
+	}
+	return ss.ReadAll(after, limit)
+}
+
+// Checkpoint and SaveCheckpoint use the primary only, consumers read the
+// stream of the primary.
+func (r *Replicated) Checkpoint(name string) (int64, error) {
+	ss, isOk := r.primary.(StreamStorage)
+	if !isOk {
+		return 0, ErrStreamNotSupported
+	}
+	return ss.Checkpoint(name)
+}
+
+func (r *Replicated) SaveCheckpoint(name string, position int64) error {
+	ss, isOk := r.primary.(StreamStorage)
+	if !isOk {
+		return ErrStreamNotSupported
+	}
+	return ss.SaveCheckpoint(name, position)
+}
+
+// Close does nothing, the replicated storages are closed by their owner.
+func (r *Replicated) Close() error {
+	return nil
+}
@@ -0,0 +1,140 @@
+package eventsourcing_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/eventstore/storage/memory"
+	"github.com/siklol/zinc/plugins/eventstore/storage/storagetest"
+	"github.com/sirupsen/logrus"
+)
+
+// failingStorage fails every append with err.
+type failingStorage struct {
+	eventsourcing.Storage
+	err error
+}
+
+var errAppend = errors.New("append failed")
+
+func (s failingStorage) Append(a eventsourcing.Aggregate, events []eventsourcing.Event, expectedVersion int64) (int64, error) {
+	return 0, s.err
+}
+
+func newMemoryStorage(t *testing.T) *memory.Storage {
+	return memory.NewMemoryEventStorage(logrus.WithField("test", t.Name()))
+}
+
+func TestReplicatedStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) eventsourcing.Storage {
+		r, err := eventsourcing.NewReplicated(logrus.WithField("test", t.Name()), "replicated", eventsourcing.PolicyAll, newMemoryStorage(t), newMemoryStorage(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
+
+func TestReplicatedPolicies(t *testing.T) {
+	tests := map[string]struct {
+		policy        eventsourcing.WritePolicy
+		failing       bool
+		wantErr       error
+		wantSecondary string
+	}{
+		"mirror": {
+			policy:        eventsourcing.PolicyMirror,
+			wantSecondary: "[1 2]",
+		},
+		"mirror with failing secondary": {
+			policy:        eventsourcing.PolicyMirror,
+			failing:       true,
+			wantSecondary: "[]",
+		},
+		"all": {
+			policy:        eventsourcing.PolicyAll,
+			wantSecondary: "[1 2]",
+		},
+		"all with failing secondary": {
+			policy:        eventsourcing.PolicyAll,
+			failing:       true,
+			wantErr:       eventsourcing.ErrReplicationFailed,
+			wantSecondary: "[]",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			primary, secondary := newMemoryStorage(t), newMemoryStorage(t)
+			var s eventsourcing.Storage = secondary
+			if test.failing {
+				s = failingStorage{Storage: secondary, err: errAppend}
+			}
+			r, err := eventsourcing.NewReplicated(logrus.WithField("test", t.Name()), "replicated", test.policy, primary, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			version, err := r.Append(&historyAggregate{id: "a"}, []eventsourcing.Event{newEvent(1), newEvent(2)}, 0)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if test.failing && test.wantErr != nil && !errors.Is(err, errAppend) {
+				t.Fatalf("got error %v, want it to wrap %v", err, errAppend)
+			}
+			if version != 2 {
+				t.Fatalf("got version %d, want 2", version)
+			}
+			if got := streamValues(t, primary, "a"); got != "[1 2]" {
+				t.Fatalf("got primary stream %s, want [1 2]", got)
+			}
+			if got := streamValues(t, secondary, "a"); got != test.wantSecondary {
+				t.Fatalf("got secondary stream %s, want %s", got, test.wantSecondary)
+			}
+		})
+	}
+}
+
+func TestReplicatedSecondaryBehind(t *testing.T) {
+	primary, secondary := newMemoryStorage(t), newMemoryStorage(t)
+	if _, err := primary.Append(&historyAggregate{id: "a"}, []eventsourcing.Event{newEvent(1)}, 0); err != nil {
+		t.Fatal(err)
+	}
+	r, err := eventsourcing.NewReplicated(logrus.WithField("test", t.Name()), "replicated", eventsourcing.PolicyAll, primary, secondary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Append(&historyAggregate{id: "a"}, []eventsourcing.Event{newEvent(2)}, 1)
+	if !errors.Is(err, eventsourcing.ErrConcurrencyConflict) {
+		t.Fatalf("got error %v, want %v", err, eventsourcing.ErrConcurrencyConflict)
+	}
+	if got := streamValues(t, secondary, "a"); got != "[]" {
+		t.Fatalf("got secondary stream %s, want []", got)
+	}
+}
+
+func TestReplicatedWithoutOptionalInterfaces(t *testing.T) {
+	r, err := eventsourcing.NewReplicated(logrus.WithField("test", t.Name()), "replicated", eventsourcing.PolicyMirror, plainStorage{newMemoryStorage(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.ReadStream("a", 1, 0); !errors.Is(err, eventsourcing.ErrQueriesNotSupported) {
+		t.Fatalf("read stream: got error %v, want %v", err, eventsourcing.ErrQueriesNotSupported)
+	}
+	if _, err := r.ReadAll(0, 10); !errors.Is(err, eventsourcing.ErrStreamNotSupported) {
+		t.Fatalf("read all: got error %v, want %v", err, eventsourcing.ErrStreamNotSupported)
+	}
+	if err := r.SaveSnapshot(eventsourcing.Snapshot{AggregateID: "a"}); !errors.Is(err, eventsourcing.ErrSnapshotsNotSupported) {
+		t.Fatalf("save snapshot: got error %v, want %v", err, eventsourcing.ErrSnapshotsNotSupported)
+	}
+}
+
+func TestNewReplicatedUnknownPolicy(t *testing.T) {
+	_, err := eventsourcing.NewReplicated(logrus.WithField("test", t.Name()), "replicated", "some", newMemoryStorage(t))
+	if !errors.Is(err, eventsourcing.ErrUnknownWritePolicy) {
+		t.Fatalf("got error %v, want %v", err, eventsourcing.ErrUnknownWritePolicy)
+	}
+}
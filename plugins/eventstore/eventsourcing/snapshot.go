@@ -0,0 +1,39 @@
+package eventsourcing
+
+import (
+	"errors"
+	"time"
+)
+
+type (
+	// Snapshotter is implemented by aggregates whose state can be stored.
+	// Loading restores the latest snapshot and replays only newer events.
+	Snapshotter interface {
+		VersionedAggregate
+		Snapshot() ([]byte, error)
+		RestoreSnapshot(data []byte) error
+	}
+
+	// Snapshot is the state of an aggregate after the event at Version.
+	Snapshot struct {
+		AggregateID AggregateID `json:"aggregate_id"`
+		Version     int64       `json:"version"`
+		CreatedAt   time.Time   `json:"created_at"`
+		Data        []byte      `json:"data"`
+	}
+
+	// SnapshotStorage is implemented by storages that can keep snapshots.
+	SnapshotStorage interface {
+		Storage
+		SaveSnapshot(s Snapshot) error
+		// LatestSnapshot returns false if the aggregate has no snapshot.
+		LatestSnapshot(id AggregateID) (Snapshot, bool, error)
+		// LoadAfter replays the events after version and returns the
+		// version of the stream.
+		LoadAfter(a Aggregate, version int64) (int64, error)
+	}
+)
+
+var (
+	ErrSnapshotsNotSupported = errors.New("storage does not support snapshots")
+)
@@ -0,0 +1,56 @@
+package eventsourcing
+
+import (
+	"errors"
+	"time"
+)
+
+type (
+	// RecordedEvent is a stored event with its position in the global stream
+	// of all aggregates. Positions increase monotonically in commit order.
+	RecordedEvent struct {
+		ID          EventID     `json:"id"`
+		Position    int64       `json:"position"`
+		AggregateID AggregateID `json:"aggregate_id"`
+		Sequence    int64       `json:"sequence"`
+		Name        string      `json:"name"`
+		Version     string      `json:"version"`
+		CreatedAt   time.Time   `json:"created_at"`
+		Data        []byte      `json:"data"`
+
+		CorrelationID string            `json:"correlation_id,omitempty"`
+		CausationID   string            `json:"causation_id,omitempty"`
+		Actor         string            `json:"actor,omitempty"`
+		Service       string            `json:"service,omitempty"`
+		Headers       map[string]string `json:"headers,omitempty"`
+	}
+
+	// StreamStorage is implemented by storages that keep the global stream
+	// and the checkpoints of its consumers.
+	StreamStorage interface {
+		Storage
+		// ReadAll returns up to limit events after position ordered by
+		// position.
+		ReadAll(after int64, limit int) ([]RecordedEvent, error)
+		// Checkpoint returns the last position consumer name handled or 0.
+		Checkpoint(name string) (int64, error)
+		SaveCheckpoint(name string, position int64) error
+	}
+)
+
+var (
+	ErrStreamNotSupported = errors.New("storage does not keep a global stream")
+)
+
+// SetMeta copies the id, type, creation time and metadata of m to e.
+func (e *RecordedEvent) SetMeta(m Meta) {
+	e.ID = m.ID
+	e.Name = m.Name
+	e.Version = m.Version
+	e.CreatedAt = m.CreatedAt
+	e.CorrelationID = m.CorrelationID
+	e.CausationID = m.CausationID
+	e.Actor = m.Actor
+	e.Service = m.Service
+	e.Headers = m.Headers
+}
@@ -0,0 +1,74 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/labstack/echo/v4"
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/kafka"
+	"github.com/siklol/zinc/plugins/restjwt"
+)
+
+const (
+	HeaderCorrelationID = "X-Correlation-ID"
+	HeaderCausationID   = "X-Causation-ID"
+)
+
+// EchoMetadata stores the eventsourcing.Metadata of a request in its
+// context. Handlers create events with
+//
+//	eventsourcing.NewMetaFromContext(c.Request().Context(), name, version)
+//
+// The correlation id is taken from the X-Correlation-ID header or the request
+// id. The request id is the causation. If jwt is not nil the subject of a
+// valid bearer token becomes the actor.
+func EchoMetadata(jwt *restjwt.Handler) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			md := eventsourcing.MetadataFrom(req.Context())
+			md.CausationID = req.Header.Get(HeaderCausationID)
+			if md.CausationID == "" {
+				md.CausationID = req.Header.Get(echo.HeaderXRequestID)
+			}
+			md.CorrelationID = req.Header.Get(HeaderCorrelationID)
+			if md.CorrelationID == "" {
+				md.CorrelationID = md.CausationID
+			}
+
+			if auth := req.Header.Get(echo.HeaderAuthorization); jwt != nil && auth != "" {
+				if token, err := jwt.Token(auth); err == nil {
+					md.Actor = jwt.UserInfo(token).ID
+				}
+			}
+
+			c.SetRequest(req.WithContext(eventsourcing.WithMetadata(req.Context(), md)))
+			return next(c)
+		}
+	}
+}
+
+// KafkaMessageContext returns a copy of ctx for handling m. If m carries an
+// event the events created while handling it are caused by that event. The
+// meta data is read from the headers of the outbox relay or else the meta of
+// the event json.
+func KafkaMessageContext(ctx context.Context, m kafka.Message) context.Context {
+	if id := m.Header(kafka.HeaderMetaID); id != "" {
+		return eventsourcing.CausedBy(ctx, eventsourcing.Meta{
+			ID:            eventsourcing.EventID(id),
+			CorrelationID: m.Header(KafkaHeaderCorrelationID),
+			Actor:         m.Header(KafkaHeaderActor),
+		})
+	}
+
+	var e struct {
+		Meta *eventsourcing.Meta `json:"meta"`
+	}
+	if err := json.Unmarshal(m.Value, &e); err != nil || e.Meta == nil || e.Meta.ID == "" {
+		return ctx
+	}
+
+	return eventsourcing.CausedBy(ctx, *e.Meta)
+}
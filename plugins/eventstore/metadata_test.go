@@ -0,0 +1,92 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/kafka"
+)
+
+func TestEchoMetadata(t *testing.T) {
+	tests := map[string]struct {
+		headers map[string]string
+		want    string
+	}{
+		"no headers": {
+			want: " ",
+		},
+		"request id": {
+			headers: map[string]string{echo.HeaderXRequestID: "r-1"},
+			want:    "r-1 r-1",
+		},
+		"correlation and causation": {
+			headers: map[string]string{echo.HeaderXRequestID: "r-1", HeaderCorrelationID: "c-1", HeaderCausationID: "e-1"},
+			want:    "c-1 e-1",
+		},
+		"bearer token without handler": {
+			headers: map[string]string{echo.HeaderAuthorization: "Bearer token", HeaderCorrelationID: "c-1"},
+			want:    "c-1 ",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			var md eventsourcing.Metadata
+			err := EchoMetadata(nil)(func(c echo.Context) error {
+				md = eventsourcing.MetadataFrom(c.Request().Context())
+				return nil
+			})(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%s %s", md.CorrelationID, md.CausationID); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+			if md.Actor != "" {
+				t.Fatalf("got actor %q without jwt handler", md.Actor)
+			}
+		})
+	}
+}
+
+func TestKafkaMessageContextFromEvent(t *testing.T) {
+	tests := map[string]struct {
+		value string
+		want  string
+	}{
+		"event meta": {
+			value: `{"meta":{"id":"e-1","correlation_id":"c-1","actor":"u-1"}}`,
+			want:  "c-1 e-1 u-1",
+		},
+		"no meta": {
+			value: `{"x":1}`,
+			want:  "  ",
+		},
+		"no json": {
+			value: `x`,
+			want:  "  ",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := KafkaMessageContext(context.Background(), kafka.Message{Value: []byte(test.value)})
+
+			md := eventsourcing.MetadataFrom(ctx)
+			if got := fmt.Sprintf("%s %s %s", md.CorrelationID, md.CausationID, md.Actor); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/kafka"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	OutboxConfig struct {
+		Enable bool `env:"EVENTSTORE_OUTBOX_ENABLE" default:"false" yaml:"enable" json:"enable"`
+		// Publisher is kafka or nats.
+		Publisher    string        `env:"EVENTSTORE_OUTBOX_PUBLISHER" default:"kafka" yaml:"publisher" json:"publisher" validate:"oneof=kafka nats"`
+		Topic        string        `env:"EVENTSTORE_OUTBOX_TOPIC" default:"events" yaml:"topic" json:"topic" validate:"required"`
+		PollInterval time.Duration `env:"EVENTSTORE_OUTBOX_POLL_INTERVAL" default:"1s" yaml:"pollInterval" json:"pollInterval"`
+		BatchSize    int           `env:"EVENTSTORE_OUTBOX_BATCH_SIZE" default:"100" yaml:"batchSize" json:"batchSize"`
+		MinBackoff   time.Duration `env:"EVENTSTORE_OUTBOX_MIN_BACKOFF" default:"1s" yaml:"minBackoff" json:"minBackoff"`
+		MaxBackoff   time.Duration `env:"EVENTSTORE_OUTBOX_MAX_BACKOFF" default:"5m" yaml:"maxBackoff" json:"maxBackoff"`
+	}
+
+	// OutboxPublisher delivers an outbox entry. A nil error means the broker
+	// accepted the event.
+	OutboxPublisher interface {
+		Publish(ctx context.Context, e eventsourcing.OutboxEntry) error
+	}
+
+	// LeaderChecker guards the relay so that only one replica publishes, see
+	// etcd.Leader.
+	LeaderChecker interface {
+		IsLeader() bool
+	}
+
+	// Relay publishes the pending outbox entries and marks them sent. Failed
+	// entries are retried with exponential backoff.
+	Relay struct {
+		logger    *logrus.Entry
+		outbox    eventsourcing.Outbox
+		publisher OutboxPublisher
+		leader    LeaderChecker
+		conf      OutboxConfig
+	}
+
+	kafkaPublisher struct {
+		k     *kafka.Plugin
+		topic string
+	}
+
+	natsPublisher struct {
+		nc      *nats.Conn
+		subject string
+	}
+)
+
+// Headers of published events in addition to the kafka meta headers. NATS
+// messages carry the same headers. The custom headers of an event are
+// prefixed with KafkaHeaderPrefix.
+const (
+	KafkaHeaderCorrelationID = "meta-correlation-id"
+	KafkaHeaderCausationID   = "meta-causation-id"
+	KafkaHeaderActor         = "meta-actor"
+	KafkaHeaderService       = "meta-service"
+	KafkaHeaderPrefix        = "meta-header-"
+)
+
+var (
+	ErrNoOutbox          = errors.New("no storage with an outbox")
+	ErrNoOutboxPublisher = errors.New("outbox publisher not available")
+)
+
+// NewRelay returns a relay publishing with publisher. leader may be nil if
+// only one replica runs.
+func NewRelay(l *logrus.Entry, outbox eventsourcing.Outbox, publisher OutboxPublisher, leader LeaderChecker, conf OutboxConfig) *Relay {
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 100
+	}
+	if conf.PollInterval <= 0 {
+		conf.PollInterval = time.Second
+	}
+
+	return &Relay{
+		logger:    l.WithField("module", "outbox-relay"),
+		outbox:    outbox,
+		publisher: publisher,
+		leader:    leader,
+		conf:      conf,
+	}
+}
+
+// NewKafkaPublisher publishes events to topic keyed by their aggregate id.
+func NewKafkaPublisher(k *kafka.Plugin, topic string) OutboxPublisher {
+	return &kafkaPublisher{k: k, topic: topic}
+}
+
+// NewNATSPublisher publishes events to subject and waits for the server to
+// receive them.
+func NewNATSPublisher(nc *nats.Conn, subject string) OutboxPublisher {
+	return &natsPublisher{nc: nc, subject: subject}
+}
+
+// Run relays until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	l := r.logger
+
+	for {
+		if r.leader == nil || r.leader.IsLeader() {
+			if err := r.relay(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				l.WithError(err).Warn("error relaying outbox")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			l.Debug("outbox relay stopped")
+			return
+		case <-time.After(r.conf.PollInterval):
+		}
+	}
+}
+
+// relay publishes batches until no entry is due.
+func (r *Relay) relay(ctx context.Context) error {
+	for {
+		entries, err := r.outbox.PendingOutbox(r.conf.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		// keep the order per aggregate: after a failure the later entries of
+		// the aggregate wait for the retry
+		failed := map[eventsourcing.AggregateID]bool{}
+		for _, e := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if failed[e.Event.AggregateID] {
+				continue
+			}
+
+			if err := r.publisher.Publish(ctx, e); err != nil {
+				failed[e.Event.AggregateID] = true
+				next := time.Now().Add(r.backoff(e.Attempts))
+				r.logger.WithError(err).WithFields(logrus.Fields{"entry": e.ID, "attempts": e.Attempts + 1, "next": next}).
+					Warn("could not publish outbox entry")
+				if err := r.outbox.MarkFailed(e.ID, next, err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := r.outbox.MarkSent(e.ID); err != nil {
+				return err
+			}
+		}
+
+		if len(entries) < r.conf.BatchSize || len(failed) > 0 {
+			return nil
+		}
+	}
+}
+
+// backoff doubles MinBackoff for every failed attempt up to MaxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.conf.MinBackoff
+	for i := 0; i < attempts && d < r.conf.MaxBackoff; i++ {
+		d *= 2
+	}
+	if r.conf.MaxBackoff > 0 && d > r.conf.MaxBackoff {
+		return r.conf.MaxBackoff
+	}
+	return d
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, e eventsourcing.OutboxEntry) error {
+	return p.k.WriteMessage(ctx, p.topic, kafkaEventMessage(e.Event))
+}
+
+// kafkaEventMessage keys e by its aggregate id and stores its meta data in the
+// headers kafka.Subscribe reads.
+func kafkaEventMessage(e eventsourcing.RecordedEvent) kafka.Message {
+	m := kafka.Message{
+		Key:   []byte(e.AggregateID),
+		Value: e.Data,
+		Time:  e.CreatedAt,
+	}
+	for _, h := range eventHeaders(e) {
+		m.Headers = append(m.Headers, kafka.Header{Key: h[0], Value: []byte(h[1])})
+	}
+	return m
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, e eventsourcing.OutboxEntry) error {
+	if err := p.nc.PublishMsg(natsEventMessage(p.subject, e.Event)); err != nil {
+		return err
+	}
+	return p.nc.FlushWithContext(ctx)
+}
+
+// natsEventMessage stores the meta data of e in the same headers as
+// kafkaEventMessage.
+func natsEventMessage(subject string, e eventsourcing.RecordedEvent) *nats.Msg {
+	m := nats.NewMsg(subject)
+	m.Data = e.Data
+	for _, h := range eventHeaders(e) {
+		m.Header.Set(h[0], h[1])
+	}
+	return m
+}
+
+// eventHeaders returns the header keys and values of e. Empty optional
+// values are left out, custom headers are sorted by key.
+func eventHeaders(e eventsourcing.RecordedEvent) [][2]string {
+	headers := [][2]string{
+		{kafka.HeaderContentType, kafka.JSONCodec{}.ContentType()},
+		{kafka.HeaderMetaID, string(e.ID)},
+		{kafka.HeaderMetaName, e.Name},
+		{kafka.HeaderMetaVersion, e.Version},
+		{kafka.HeaderMetaCreatedAt, e.CreatedAt.UTC().Format(time.RFC3339Nano)},
+	}
+	for _, h := range [][2]string{
+		{KafkaHeaderCorrelationID, e.CorrelationID},
+		{KafkaHeaderCausationID, e.CausationID},
+		{KafkaHeaderActor, e.Actor},
+		{KafkaHeaderService, e.Service},
+	} {
+		if h[1] != "" {
+			headers = append(headers, h)
+		}
+	}
+
+	keys := make([]string, 0, len(e.Headers))
+	for k := range e.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		headers = append(headers, [2]string{KafkaHeaderPrefix + k, e.Headers[k]})
+	}
+
+	return headers
+}
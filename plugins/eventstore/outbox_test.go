@@ -0,0 +1,90 @@
+package eventstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/kafka"
+)
+
+func TestKafkaEventMessage(t *testing.T) {
+	e := eventsourcing.RecordedEvent{
+		ID:            "e-1",
+		AggregateID:   "a-1",
+		Name:          "created",
+		Version:       "2",
+		CreatedAt:     time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		Data:          []byte(`{"x":1}`),
+		CorrelationID: "c-1",
+		CausationID:   "r-1",
+		Service:       "s-1",
+		Headers:       map[string]string{"tenant": "t-1"},
+	}
+
+	m := kafkaEventMessage(e)
+	if string(m.Key) != "a-1" || string(m.Value) != `{"x":1}` {
+		t.Fatalf("got key %q value %q", m.Key, m.Value)
+	}
+
+	meta := kafka.MetaFromHeaders(m)
+	if meta.ID != "e-1" || meta.Name != "created" || meta.Version != "2" || !meta.CreatedAt.Equal(e.CreatedAt) {
+		t.Fatalf("got meta %+v", meta)
+	}
+	if got := m.Header(KafkaHeaderCorrelationID); got != "c-1" {
+		t.Fatalf("got correlation id %q", got)
+	}
+	if got := m.Header(KafkaHeaderCausationID); got != "r-1" {
+		t.Fatalf("got causation id %q", got)
+	}
+	if got := m.Header(KafkaHeaderService); got != "s-1" {
+		t.Fatalf("got service %q", got)
+	}
+	if got := m.Header(KafkaHeaderPrefix + "tenant"); got != "t-1" {
+		t.Fatalf("got tenant header %q", got)
+	}
+	for _, h := range m.Headers {
+		if h.Key == KafkaHeaderActor {
+			t.Fatal("empty actor written")
+		}
+	}
+}
+
+func TestNATSEventMessage(t *testing.T) {
+	e := eventsourcing.RecordedEvent{
+		ID:            "e-1",
+		AggregateID:   "a-1",
+		Name:          "created",
+		Version:       "2",
+		CreatedAt:     time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		Data:          []byte(`{"x":1}`),
+		CorrelationID: "c-1",
+		Actor:         "u-1",
+		Headers:       map[string]string{"tenant": "t-1"},
+	}
+
+	m := natsEventMessage("events", e)
+	if m.Subject != "events" || string(m.Data) != `{"x":1}` {
+		t.Fatalf("got subject %q data %q", m.Subject, m.Data)
+	}
+
+	k := kafkaEventMessage(e)
+	if len(m.Header) != len(k.Headers) {
+		t.Fatalf("got %d nats headers, want %d", len(m.Header), len(k.Headers))
+	}
+	for _, h := range k.Headers {
+		if got := m.Header.Get(h.Key); got != string(h.Value) {
+			t.Fatalf("got nats header %s %q, want %q", h.Key, got, h.Value)
+		}
+	}
+}
+
+func TestKafkaMessageContextFromHeaders(t *testing.T) {
+	m := kafkaEventMessage(eventsourcing.RecordedEvent{ID: "e-1", CorrelationID: "c-1", Actor: "u-1"})
+
+	md := eventsourcing.MetadataFrom(KafkaMessageContext(context.Background(), m))
+	if md.CausationID != "e-1" || md.CorrelationID != "c-1" || md.Actor != "u-1" {
+		t.Fatalf("got metadata %+v", md)
+	}
+}
@@ -1,32 +1,83 @@
 package eventstore
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/rs/xid"
 	"github.com/siklol/zinc/plugins"
+	"github.com/siklol/zinc/plugins/etcd"
 	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
 	"github.com/siklol/zinc/plugins/eventstore/storage/boltdb"
+	"github.com/siklol/zinc/plugins/eventstore/storage/memory"
 	"github.com/siklol/zinc/plugins/eventstore/storage/postgres"
+	"github.com/siklol/zinc/plugins/kafka"
+	"github.com/siklol/zinc/plugins/nats"
+	postgresPlugin "github.com/siklol/zinc/plugins/postgres"
+	"github.com/siklol/zinc/plugins/rest"
+	"github.com/siklol/zinc/plugins/restjwt"
 	"github.com/sirupsen/logrus"
 )
 
 type (
 	Plugin struct {
-		logger   *logrus.Entry
-		conf     Config
-		storages map[string]eventsourcing.Storage
-		es       *eventsourcing.EventStore
+		logger    *logrus.Entry
+		conf      Config
+		storages  map[string]eventsourcing.Storage
+		es        *eventsourcing.EventStore
+		mutex     sync.Mutex
+		projector *Projector
+		ctx       context.Context
+		cancel    context.CancelFunc
+		kafka     *kafka.Plugin
+		nats      *nats.Plugin
+		etcd      *etcd.Plugin
+		rest      *rest.Plugin
+		jwt       *restjwt.Plugin
+		id        xid.ID
+		dependsOn []string
+		bootErr   error
 	}
 
 	Config struct {
-		Enable   bool `env:"EVENTSTORE_ENABLE" default:"false" yaml:"enable"`
-		Storages struct {
+		Enable bool `env:"EVENTSTORE_ENABLE" default:"false" yaml:"enable"`
+		// SnapshotEvery takes a snapshot of aggregates supporting it every n
+		// events. 0 disables automatic snapshots.
+		SnapshotEvery int64 `env:"EVENTSTORE_SNAPSHOT_EVERY" default:"0" yaml:"snapshotEvery" json:"snapshotEvery"`
+		Storages      struct {
 			BoltDB   boltdb.Config   `env:"EVENTSTORE_STORAGES_BOLTDB" yaml:"boltdb" json:"boltdb"`
 			Postgres postgres.Config `env:"EVENTSTORE_STORAGES_POSTGRES" yaml:"postgres" json:"postgres"`
+			Memory   memory.Config   `env:"EVENTSTORE_STORAGES_MEMORY" yaml:"memory" json:"memory"`
 		} `env:"EVENTSTORE_STORAGES" yaml:"storages" json:"storages"`
+		Projections ProjectionConfig  `yaml:"projections" json:"projections"`
+		Outbox      OutboxConfig      `yaml:"outbox" json:"outbox"`
+		Replication ReplicationConfig `yaml:"replication" json:"replication"`
+		Admin       AdminConfig       `yaml:"admin" json:"admin"`
 	}
 )
 
 const Name = "eventstore"
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "eventstore",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+			plugins.TypeOf[*kafka.Plugin](),
+			plugins.TypeOf[*nats.Plugin](),
+			plugins.TypeOf[*etcd.Plugin](),
+			plugins.TypeOf[xid.ID](),
+			plugins.TypeOf[*rest.Plugin](),
+			plugins.TypeOf[*restjwt.Plugin](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{
 		storages: map[string]eventsourcing.Storage{},
@@ -42,6 +93,23 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 		switch dp := d.(type) {
 		case *logrus.Entry:
 			p.logger = dp.WithField("component", "eventstore")
+		case *kafka.Plugin:
+			p.kafka = dp
+			p.dependsOn = append(p.dependsOn, dp.Name())
+		case *nats.Plugin:
+			p.nats = dp
+			p.dependsOn = append(p.dependsOn, dp.Name())
+		case *etcd.Plugin:
+			p.etcd = dp
+			p.dependsOn = append(p.dependsOn, dp.Name())
+		case xid.ID:
+			p.id = dp
+		case *rest.Plugin:
+			p.rest = dp
+			p.dependsOn = append(p.dependsOn, dp.Name())
+		case *restjwt.Plugin:
+			p.jwt = dp
+			p.dependsOn = append(p.dependsOn, dp.Name())
 		}
 	}
 	if p.logger == nil {
@@ -57,6 +125,70 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	}
 
 	p.es = eventsourcing.NewEventStore(p.logger.WithField("module", "event-store"))
+	if !p.id.IsNil() {
+		eventsourcing.SetService(p.id.String())
+	}
+	if p.rest != nil && p.rest.IsEnabled() {
+		var jwt *restjwt.Handler
+		if p.jwt != nil && p.jwt.IsEnabled() {
+			jwt = p.jwt.Handler()
+		}
+		p.rest.Router().Use(EchoMetadata(jwt))
+	}
+	p.es.SnapshotEvery(p.conf.SnapshotEvery)
+
+	if p.conf.Storages.Postgres.Enable {
+		pgConf := p.conf.Storages.Postgres
+		pgConf.Outbox = pgConf.Outbox || p.conf.Outbox.Enable
+		s, err := postgres.NewPostgresEventStorage(
+			l,
+			pgConf,
+			postgresPlugin.NewGolangMigrator(l, pgConf.MigrationsTable),
+		)
+		if err != nil {
+			l.WithError(err).Error("error init postgres storage")
+			p.bootErr = fmt.Errorf("postgres storage: %w", err)
+			p.conf.Enable = false
+			return p
+		}
+		p.BootStorage(s)
+	}
+
+	if p.conf.Storages.BoltDB.Enable {
+		s, err := boltdb.NewBoltDBEventStorage(l, p.conf.Storages.BoltDB)
+		if err != nil {
+			l.WithError(err).Error("error init boltdb storage")
+			p.bootErr = fmt.Errorf("boltdb storage: %w", err)
+			p.conf.Enable = false
+			return p
+		}
+		p.BootStorage(s)
+	}
+
+	if p.conf.Storages.Memory.Enable {
+		p.BootStorage(memory.NewMemoryEventStorage(l))
+	}
+
+	if p.conf.Replication.Enable {
+		r, err := p.replicated()
+		if err != nil {
+			l.WithError(err).Error("error init storage replication")
+			p.bootErr = err
+			p.conf.Enable = false
+			return p
+		}
+		p.BootStorage(r)
+	}
+
+	if p.conf.Admin.Enable {
+		if p.rest == nil || !p.rest.IsEnabled() {
+			l.Warn("rest is not enabled. eventstore admin not mounted")
+		} else if err := p.mountAdmin(); err != nil {
+			l.WithError(err).Error("error mounting eventstore admin")
+			p.bootErr = err
+			p.conf.Enable = false
+		}
+	}
 
 	return p
 }
@@ -66,6 +198,7 @@ func (p *Plugin) BootStorage(storages ...eventsourcing.Storage) {
 
 	for _, s := range storages {
 		l.WithField("boot-storage", s.Name()).Debug("booting eventsourcing storage plugin")
+		p.storages[s.Name()] = s
 		p.es.AddStorage(s)
 	}
 }
@@ -74,10 +207,125 @@ func (p *Plugin) EventStore() *eventsourcing.EventStore {
 	return p.es
 }
 
+// Projector returns the projector reading the storage configured in
+// Projections.Storage. It is created on first use.
+func (p *Plugin) Projector() (*Projector, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.projector != nil {
+		return p.projector, nil
+	}
+	if !p.conf.Enable {
+		return nil, ErrProjectorNotAvailable
+	}
+
+	candidates := []string{p.conf.Projections.Storage}
+	if p.conf.Projections.Storage == "" {
+		candidates = []string{"postgres", "boltdb", "memory"}
+	}
+	for _, name := range candidates {
+		if ss, isOk := p.storages[name].(eventsourcing.StreamStorage); isOk {
+			p.projector = NewProjector(p.logger, ss, p.conf.Projections)
+			if p.ctx != nil {
+				p.projector.Start(p.ctx)
+			}
+			return p.projector, nil
+		}
+	}
+
+	return nil, ErrNoStreamStorage
+}
+
+// RegisterProjection adds pr to the projector. Registered projections catch
+// up and follow the stream once the plugin is started.
+func (p *Plugin) RegisterProjection(pr Projection) error {
+	projector, err := p.Projector()
+	if err != nil {
+		return err
+	}
+	return projector.Register(pr)
+}
+
+// RebuildProjection resets the projection name and replays all events.
+func (p *Plugin) RebuildProjection(ctx context.Context, name string) error {
+	projector, err := p.Projector()
+	if err != nil {
+		return err
+	}
+	return projector.Rebuild(ctx, name)
+}
+
 func (p *Plugin) Start() error {
+	if !p.conf.Enable {
+		return nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	if p.projector != nil {
+		p.projector.Start(p.ctx)
+	}
+
+	if p.conf.Outbox.Enable {
+		relay, err := p.relay()
+		if err != nil {
+			return err
+		}
+		go relay.Run(p.ctx)
+	}
+
 	return nil
 }
 
+// relay builds the outbox relay from the config. If etcd is enabled only the
+// leader relays.
+func (p *Plugin) relay() (*Relay, error) {
+	var outbox eventsourcing.Outbox
+	for _, name := range []string{"postgres", "boltdb"} {
+		if o, isOk := p.storages[name].(eventsourcing.Outbox); isOk {
+			outbox = o
+			break
+		}
+	}
+	if outbox == nil {
+		return nil, ErrNoOutbox
+	}
+
+	var publisher OutboxPublisher
+	switch {
+	case p.conf.Outbox.Publisher == "kafka" && p.kafka != nil && p.kafka.IsEnabled():
+		publisher = NewKafkaPublisher(p.kafka, p.conf.Outbox.Topic)
+	case p.conf.Outbox.Publisher == "nats" && p.nats != nil && p.nats.IsEnabled():
+		publisher = NewNATSPublisher(p.nats.NC(), p.conf.Outbox.Topic)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNoOutboxPublisher, p.conf.Outbox.Publisher)
+	}
+
+	var leader LeaderChecker
+	if p.etcd != nil && p.etcd.IsEnabled() {
+		lead := p.etcd.Leader()
+		go func() {
+			if err := lead.Acquire(); err != nil {
+				p.logger.WithError(err).Warn("could not join leader election. outbox relay stays idle")
+			}
+		}()
+		leader = lead
+	}
+
+	return NewRelay(p.logger, outbox, publisher, leader, p.conf.Outbox), nil
+}
+
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
+func (p *Plugin) DependsOn() []string {
+	return p.dependsOn
+}
+
 func (p *Plugin) IsEnabled() bool {
 	return p.conf.Enable
 }
@@ -87,6 +335,12 @@ func (p *Plugin) Close() error {
 		return nil
 	}
 
+	p.mutex.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.mutex.Unlock()
+
 	for name, s := range p.storages {
 		if err := s.Close(); err != nil {
 			p.logger.WithField("component", "close eventsourcing storage").
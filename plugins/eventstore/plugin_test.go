@@ -0,0 +1,24 @@
+package eventstore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBootFailureDisablesPlugin(t *testing.T) {
+	conf := Config{Enable: true}
+	conf.Storages.BoltDB.Enable = true
+	conf.Storages.BoltDB.File = filepath.Join(t.TempDir(), "missing", "eventstore.db")
+
+	p := New().Boot(conf).(*Plugin)
+
+	if p.BootError() == nil {
+		t.Fatal("no boot error for an unopenable bolt file")
+	}
+	if p.IsEnabled() {
+		t.Fatal("plugin enabled after boot failure")
+	}
+	if err := p.Start(); err != nil {
+		t.Fatalf("start of a disabled plugin: %s", err)
+	}
+}
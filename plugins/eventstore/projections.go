@@ -0,0 +1,223 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// Projection builds a read model from the global event stream. Handle
+	// is called once per event in position order, at least once: events
+	// handled after the last persisted checkpoint are handled again after a
+	// restart.
+	Projection interface {
+		Name() string
+		Handle(e eventsourcing.RecordedEvent) error
+		// Reset drops the read model before a rebuild.
+		Reset() error
+	}
+
+	ProjectionConfig struct {
+		// Storage is the name of the storage to read the stream from. Empty
+		// uses postgres, boltdb or memory, whichever is enabled first.
+		Storage      string        `env:"EVENTSTORE_PROJECTIONS_STORAGE" yaml:"storage" json:"storage"`
+		PollInterval time.Duration `env:"EVENTSTORE_PROJECTIONS_POLL_INTERVAL" default:"1s" yaml:"pollInterval" json:"pollInterval"`
+		BatchSize    int           `env:"EVENTSTORE_PROJECTIONS_BATCH_SIZE" default:"100" yaml:"batchSize" json:"batchSize"`
+	}
+
+	// Projector feeds the global stream to the registered projections. Each
+	// projection catches up from its checkpoint and then polls for new
+	// events.
+	Projector struct {
+		logger      *logrus.Entry
+		s           eventsourcing.StreamStorage
+		conf        ProjectionConfig
+		mutex       sync.Mutex
+		ctx         context.Context
+		projections map[string]*projection
+	}
+
+	projection struct {
+		Projection
+		// mutex serializes batches and rebuilds of the projection
+		mutex sync.Mutex
+	}
+)
+
+var (
+	ErrNoStreamStorage       = errors.New("no storage with a global event stream")
+	ErrProjectionNotFound    = errors.New("projection not registered")
+	ErrDuplicateProjection   = errors.New("projection registered twice")
+	ErrProjectorNotAvailable = errors.New("projector not available")
+)
+
+func NewProjector(l *logrus.Entry, s eventsourcing.StreamStorage, conf ProjectionConfig) *Projector {
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 100
+	}
+	if conf.PollInterval <= 0 {
+		conf.PollInterval = time.Second
+	}
+
+	return &Projector{
+		logger:      l.WithField("module", "projector"),
+		s:           s,
+		conf:        conf,
+		projections: map[string]*projection{},
+	}
+}
+
+// Register adds pr. Projections registered after Start start immediately.
+func (p *Projector) Register(pr Projection) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, isOk := p.projections[pr.Name()]; isOk {
+		return fmt.Errorf("%w: %s", ErrDuplicateProjection, pr.Name())
+	}
+
+	st := &projection{Projection: pr}
+	p.projections[pr.Name()] = st
+	if p.ctx != nil {
+		go p.run(p.ctx, st)
+	}
+
+	return nil
+}
+
+// Start runs all projections until ctx is done.
+func (p *Projector) Start(ctx context.Context) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.ctx = ctx
+	for _, st := range p.projections {
+		go p.run(ctx, st)
+	}
+}
+
+// Names returns the names of all registered projections.
+func (p *Projector) Names() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	names := make([]string, 0, len(p.projections))
+	for name := range p.projections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Rebuild resets the projection and its checkpoint and replays the stream
+// from the beginning until it caught up.
+func (p *Projector) Rebuild(ctx context.Context, name string) error {
+	st, err := p.projection(name)
+	if err != nil {
+		return err
+	}
+
+	st.mutex.Lock()
+	if err := st.Reset(); err != nil {
+		st.mutex.Unlock()
+		return fmt.Errorf("reset %s: %w", name, err)
+	}
+	if err := p.s.SaveCheckpoint(name, 0); err != nil {
+		st.mutex.Unlock()
+		return fmt.Errorf("reset checkpoint of %s: %w", name, err)
+	}
+	st.mutex.Unlock()
+
+	p.logger.WithField("projection", name).Info("rebuilding projection")
+
+	return p.catchUp(ctx, st)
+}
+
+func (p *Projector) projection(name string) (*projection, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	st, isOk := p.projections[name]
+	if !isOk {
+		return nil, fmt.Errorf("%w: %s", ErrProjectionNotFound, name)
+	}
+	return st, nil
+}
+
+func (p *Projector) run(ctx context.Context, st *projection) {
+	l := p.logger.WithField("projection", st.Name())
+
+	for {
+		if err := p.catchUp(ctx, st); err != nil && !errors.Is(err, context.Canceled) {
+			l.WithError(err).Warn("projection stopped at error. retrying")
+		}
+
+		select {
+		case <-ctx.Done():
+			l.Debug("projection stopped")
+			return
+		case <-time.After(p.conf.PollInterval):
+		}
+	}
+}
+
+// catchUp handles batches until the projection reached the end of the
+// stream.
+func (p *Projector) catchUp(ctx context.Context, st *projection) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := p.handleBatch(st)
+		if err != nil {
+			return err
+		}
+		if n < p.conf.BatchSize {
+			return nil
+		}
+	}
+}
+
+// handleBatch hands the next batch to the projection and saves the position
+// of the last handled event.
+func (p *Projector) handleBatch(st *projection) (int, error) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	position, err := p.s.Checkpoint(st.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	events, err := p.s.ReadAll(position, p.conf.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var handleErr error
+	handled := position
+	for _, e := range events {
+		if handleErr = st.Handle(e); handleErr != nil {
+			handleErr = fmt.Errorf("handle position %d: %w", e.Position, handleErr)
+			break
+		}
+		handled = e.Position
+	}
+
+	if handled != position {
+		if err := p.s.SaveCheckpoint(st.Name(), handled); err != nil {
+			return 0, errors.Join(handleErr, err)
+		}
+	}
+
+	return len(events), handleErr
+}
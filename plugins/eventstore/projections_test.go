@@ -0,0 +1,254 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/eventstore/storage/memory"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	testEvent struct {
+		M eventsourcing.Meta `json:"meta"`
+	}
+
+	testAggregate struct {
+		id eventsourcing.AggregateID
+	}
+
+	// testProjection records the positions it handled and fails at
+	// failAt once.
+	testProjection struct {
+		mutex     sync.Mutex
+		name      string
+		positions []int64
+		failAt    int64
+		resets    int
+	}
+)
+
+var errProjection = errors.New("projection failed")
+
+func (e *testEvent) Meta() eventsourcing.Meta {
+	return e.M
+}
+
+func (a *testAggregate) AggregateID() eventsourcing.AggregateID {
+	return a.id
+}
+
+func (a *testAggregate) On(e eventsourcing.Event) error {
+	return nil
+}
+
+func (a *testAggregate) OnRaw(name string, version string, e []byte) error {
+	return nil
+}
+
+func (a *testAggregate) Events() []eventsourcing.Event {
+	return nil
+}
+
+func (p *testProjection) Name() string {
+	return p.name
+}
+
+func (p *testProjection) Handle(e eventsourcing.RecordedEvent) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if e.Position == p.failAt {
+		p.failAt = 0
+		return errProjection
+	}
+	p.positions = append(p.positions, e.Position)
+	return nil
+}
+
+func (p *testProjection) Reset() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.positions = nil
+	p.resets++
+	return nil
+}
+
+func (p *testProjection) String() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return fmt.Sprint(p.positions)
+}
+
+// newTestStorage returns a memory storage with n events of aggregate id.
+func newTestStorage(t *testing.T, id eventsourcing.AggregateID, n int) *memory.Storage {
+	t.Helper()
+
+	s := memory.NewMemoryEventStorage(logrus.WithField("test", t.Name()))
+	appendEvents(t, s, id, n)
+	return s
+}
+
+func appendEvents(t *testing.T, s eventsourcing.Storage, id eventsourcing.AggregateID, n int) {
+	t.Helper()
+
+	var events []eventsourcing.Event
+	for i := 0; i < n; i++ {
+		events = append(events, &testEvent{M: eventsourcing.NewMeta("created", "1")})
+	}
+	if _, err := s.Append(&testAggregate{id: id}, events, eventsourcing.AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProjectorCatchUp(t *testing.T) {
+	tests := map[string]struct {
+		checkpoint     int64
+		failAt         int64
+		want           string
+		wantErr        error
+		wantCheckpoint int64
+	}{
+		"from the start": {
+			want:           "[1 2 3 4 5]",
+			wantCheckpoint: 5,
+		},
+		"from checkpoint": {
+			checkpoint:     3,
+			want:           "[4 5]",
+			wantCheckpoint: 5,
+		},
+		"failing in the second batch": {
+			failAt:         4,
+			want:           "[1 2 3]",
+			wantErr:        errProjection,
+			wantCheckpoint: 3,
+		},
+		"failing at the first event": {
+			failAt:  1,
+			want:    "[]",
+			wantErr: errProjection,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestStorage(t, "a", 5)
+			if err := s.SaveCheckpoint("test", test.checkpoint); err != nil {
+				t.Fatal(err)
+			}
+			p := NewProjector(logrus.WithField("test", t.Name()), s, ProjectionConfig{BatchSize: 2})
+			pr := &testProjection{name: "test", failAt: test.failAt}
+			if err := p.Register(pr); err != nil {
+				t.Fatal(err)
+			}
+
+			st, err := p.projection("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.catchUp(context.Background(), st)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if pr.String() != test.want {
+				t.Fatalf("got positions %s, want %s", pr, test.want)
+			}
+			checkpoint, err := s.Checkpoint("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if checkpoint != test.wantCheckpoint {
+				t.Fatalf("got checkpoint %d, want %d", checkpoint, test.wantCheckpoint)
+			}
+		})
+	}
+}
+
+func TestProjectorResumesAfterError(t *testing.T) {
+	s := newTestStorage(t, "a", 3)
+	p := NewProjector(logrus.WithField("test", t.Name()), s, ProjectionConfig{BatchSize: 10})
+	pr := &testProjection{name: "test", failAt: 2}
+	if err := p.Register(pr); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := p.projection("test")
+
+	if err := p.catchUp(context.Background(), st); !errors.Is(err, errProjection) {
+		t.Fatalf("got error %v, want %v", err, errProjection)
+	}
+	if err := p.catchUp(context.Background(), st); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[1 2 3]"; pr.String() != want {
+		t.Fatalf("got positions %s, want %s", pr, want)
+	}
+}
+
+func TestProjectorRebuild(t *testing.T) {
+	s := newTestStorage(t, "a", 3)
+	if err := s.SaveCheckpoint("test", 3); err != nil {
+		t.Fatal(err)
+	}
+	p := NewProjector(logrus.WithField("test", t.Name()), s, ProjectionConfig{})
+	pr := &testProjection{name: "test", positions: []int64{1, 2, 3}}
+	if err := p.Register(pr); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Rebuild(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if pr.resets != 1 || pr.String() != "[1 2 3]" {
+		t.Fatalf("got %d resets and positions %s, want 1 and [1 2 3]", pr.resets, pr)
+	}
+
+	if err := p.Rebuild(context.Background(), "unknown"); !errors.Is(err, ErrProjectionNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrProjectionNotFound)
+	}
+}
+
+func TestProjectorRegister(t *testing.T) {
+	p := NewProjector(logrus.WithField("test", t.Name()), newTestStorage(t, "a", 1), ProjectionConfig{})
+	if err := p.Register(&testProjection{name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Register(&testProjection{name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Register(&testProjection{name: "a"}); !errors.Is(err, ErrDuplicateProjection) {
+		t.Fatalf("got error %v, want %v", err, ErrDuplicateProjection)
+	}
+	if got := fmt.Sprint(p.Names()); got != "[a b]" {
+		t.Fatalf("got names %s, want [a b]", got)
+	}
+}
+
+func TestProjectorPollsNewEvents(t *testing.T) {
+	s := newTestStorage(t, "a", 1)
+	p := NewProjector(logrus.WithField("test", t.Name()), s, ProjectionConfig{PollInterval: time.Millisecond})
+	pr := &testProjection{name: "test"}
+	if err := p.Register(pr); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.Start(ctx)
+	appendEvents(t, s, "b", 2)
+
+	deadline := time.Now().Add(time.Second)
+	for pr.String() != "[1 2 3]" {
+		if time.Now().After(deadline) {
+			t.Fatalf("got positions %s, want [1 2 3]", pr)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,73 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+)
+
+type (
+	ReplicationConfig struct {
+		Enable bool `env:"EVENTSTORE_REPLICATION_ENABLE" default:"false" yaml:"enable" json:"enable"`
+		// Name is the storage name to pass to Persist and Load.
+		Name        string   `env:"EVENTSTORE_REPLICATION_NAME" default:"replicated" yaml:"name" json:"name" validate:"required"`
+		Primary     string   `env:"EVENTSTORE_REPLICATION_PRIMARY" default:"boltdb" yaml:"primary" json:"primary" validate:"required"`
+		Secondaries []string `env:"EVENTSTORE_REPLICATION_SECONDARIES" envSeparator:"," default:"[\"postgres\"]" yaml:"secondaries" json:"secondaries"`
+		// Policy is mirror or all, see eventsourcing.WritePolicy.
+		Policy string `env:"EVENTSTORE_REPLICATION_POLICY" default:"mirror" yaml:"policy" json:"policy" validate:"oneof=mirror all"`
+	}
+)
+
+// replicated builds the replicated storage from the booted storages.
+func (p *Plugin) replicated() (*eventsourcing.Replicated, error) {
+	conf := p.conf.Replication
+
+	primary, isOk := p.storages[conf.Primary]
+	if !isOk {
+		return nil, fmt.Errorf("replication primary %s: %w", conf.Primary, eventsourcing.ErrStorageNotAvailable)
+	}
+
+	secondaries := make([]eventsourcing.Storage, 0, len(conf.Secondaries))
+	for _, name := range conf.Secondaries {
+		s, isOk := p.storages[name]
+		if !isOk {
+			return nil, fmt.Errorf("replication secondary %s: %w", name, eventsourcing.ErrStorageNotAvailable)
+		}
+		secondaries = append(secondaries, s)
+	}
+
+	return eventsourcing.NewReplicated(p.logger, conf.Name, eventsourcing.WritePolicy(conf.Policy), primary, secondaries...)
+}
+
+// Migrate copies all aggregates of storage from to storage to and verifies
+// them. With verifyOnly nothing is copied.
+func (p *Plugin) Migrate(ctx context.Context, from string, to string, verifyOnly bool) (eventsourcing.MigrationReport, error) {
+	source, err := p.queryStorage(from)
+	if err != nil {
+		return eventsourcing.MigrationReport{}, err
+	}
+	target, err := p.queryStorage(to)
+	if err != nil {
+		return eventsourcing.MigrationReport{}, err
+	}
+
+	m := eventsourcing.NewMigrator(p.logger, source, target)
+	if verifyOnly {
+		return m.Verify(ctx)
+	}
+	return m.Run(ctx)
+}
+
+func (p *Plugin) queryStorage(name string) (eventsourcing.QueryStorage, error) {
+	s, isOk := p.storages[name]
+	if !isOk {
+		return nil, fmt.Errorf("%w: %s", eventsourcing.ErrStorageNotAvailable, name)
+	}
+
+	qs, isOk := s.(eventsourcing.QueryStorage)
+	if !isOk {
+		return nil, fmt.Errorf("%w: %s", eventsourcing.ErrQueriesNotSupported, name)
+	}
+	return qs, nil
+}
@@ -1,8 +1,9 @@
 package boltdb
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"time"
 
@@ -26,40 +27,75 @@ type (
 	iEvent struct {
 		Meta eventsourcing.Meta `json:"meta"`
 	}
+
+	// streamRef points from the global stream to the event in its
+	// aggregate bucket.
+	streamRef struct {
+		AggregateID eventsourcing.AggregateID `json:"aggregate_id"`
+		Sequence    int64                     `json:"sequence"`
+	}
 )
 
-func NewBoltDBEventStorage(l *logrus.Entry, cfg Config) *Storage {
-	l.WithField("config", cfg).Trace("storage boltdb config")
+var (
+	streamBucket     = []byte("stream")
+	checkpointBucket = []byte("checkpoints")
+)
+
+// NewBoltDBEventStorage opens or creates the bolt file. Files of older
+// format versions are upgraded, see formatVersion.
+func NewBoltDBEventStorage(l *logrus.Entry, cfg Config) (*Storage, error) {
+	l.WithField("file", cfg.File).Trace("storage boltdb config")
 
 	db, err := bbolt.Open(cfg.File, 0600, &bbolt.Options{Timeout: 1 * time.Second})
 	if err != nil {
-		l.WithError(err).Fatal("error starting bolt db")
+		return nil, fmt.Errorf("open %s: %w", cfg.File, err)
+	}
+	if err := upgrade(l, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open %s: %w", cfg.File, err)
 	}
 
 	return &Storage{
-		db: db,
-		l:  l,
-	}
+		db:  db,
+		l:   l,
+		cfg: cfg,
+	}, nil
 }
 
 func (p *Storage) Name() string {
 	return "boltdb"
 }
 
-func (p *Storage) Upsert(a eventsourcing.Aggregate) error {
-	l := p.l.WithField("module", "upsert")
-	return p.db.Update(func(tx *bbolt.Tx) error {
-		b, err := tx.CreateBucketIfNotExists(bucketName(a))
+func (p *Storage) Append(a eventsourcing.Aggregate, events []eventsourcing.Event, expectedVersion int64) (int64, error) {
+	l := p.l.WithField("module", "append")
+
+	if a.AggregateID() == "" {
+		return 0, eventsourcing.ErrNoAggregateID
+	}
+	if len(events) == 0 {
+		return 0, eventsourcing.ErrNoEvents
+	}
+
+	var version int64
+	err := p.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketName(a.AggregateID()))
 		if err != nil {
 			l.WithError(err).Error("could not create bucket")
 			return fmt.Errorf("create bucket: %s", err)
 		}
 
-		events := a.Events()
-		l.Tracef("%#v", a)
+		version = int64(b.Sequence())
+		if expectedVersion != eventsourcing.AnyVersion && version != expectedVersion {
+			return eventsourcing.ConflictError(a.AggregateID(), expectedVersion, version)
+		}
 
-		if len(events) == 0 {
-			return errors.New("no events to persist")
+		stream, err := tx.CreateBucketIfNotExists(streamBucket)
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		positions, err := tx.CreateBucketIfNotExists(positionBucketName(a.AggregateID()))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
 		}
 
 		for _, e := range events {
@@ -68,29 +104,65 @@ func (p *Storage) Upsert(a eventsourcing.Aggregate) error {
 				l.WithError(err).WithField("meta", e.Meta()).Error("could not marshal event")
 				return err
 			}
-			id := eventID(a, e)
-			if err := b.Put(id, jEvent); err != nil {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := b.Put(sequenceKey(seq), jEvent); err != nil {
 				l.WithError(err).WithField("meta", e.Meta()).Error("could not persist event")
 				return err
 			}
-			l.WithField("meta", e.Meta()).WithField("id", string(id)).Trace("event written")
+			version = int64(seq)
+
+			ref, err := json.Marshal(streamRef{AggregateID: a.AggregateID(), Sequence: version})
+			if err != nil {
+				return err
+			}
+			position, err := stream.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := stream.Put(sequenceKey(position), ref); err != nil {
+				return err
+			}
+			if err := positions.Put(sequenceKey(seq), sequenceKey(position)); err != nil {
+				return err
+			}
+			l.WithField("meta", e.Meta()).WithField("sequence", seq).Trace("event written")
 		}
 
 		l.Debug("done persisting aggregate")
 
 		return nil
 	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
 }
 
-func (p *Storage) Load(a eventsourcing.Aggregate) error {
+func (p *Storage) Load(a eventsourcing.Aggregate) (int64, error) {
+	return p.LoadAfter(a, 0)
+}
+
+func (p *Storage) LoadAfter(a eventsourcing.Aggregate, after int64) (int64, error) {
 	l := p.l.WithField("module", "load")
 
-	return p.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(bucketName(a))
+	if a.AggregateID() == "" {
+		return 0, eventsourcing.ErrNoAggregateID
+	}
+
+	version := after
+	err := p.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketName(a.AggregateID()))
+		if b == nil {
+			return nil
+		}
 
 		c := b.Cursor()
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
+		for k, v := c.Seek(sequenceKey(uint64(after + 1))); k != nil; k, v = c.Next() {
 			var e iEvent
 			if err := json.Unmarshal(v, &e); err != nil {
 				l.WithError(err).WithField("id", string(k)).Error("could not unmarshal event")
@@ -102,18 +174,282 @@ func (p *Storage) Load(a eventsourcing.Aggregate) error {
 			}
 		}
 
+		version = int64(b.Sequence())
 		return nil
 	})
+
+	return version, err
+}
+
+func (p *Storage) SaveSnapshot(snapshot eventsourcing.Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return p.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(snapshotBucketName(snapshot.AggregateID))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		return b.Put(sequenceKey(uint64(snapshot.Version)), data)
+	})
+}
+
+func (p *Storage) LatestSnapshot(id eventsourcing.AggregateID) (eventsourcing.Snapshot, bool, error) {
+	var snapshot eventsourcing.Snapshot
+	isFound := false
+	err := p.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(snapshotBucketName(id))
+		if b == nil {
+			return nil
+		}
+
+		k, v := b.Cursor().Last()
+		if k == nil {
+			return nil
+		}
+
+		isFound = true
+		return json.Unmarshal(v, &snapshot)
+	})
+
+	return snapshot, isFound, err
+}
+
+func (p *Storage) ReadAll(after int64, limit int) ([]eventsourcing.RecordedEvent, error) {
+	events := []eventsourcing.RecordedEvent{}
+	err := p.db.View(func(tx *bbolt.Tx) error {
+		stream := tx.Bucket(streamBucket)
+		if stream == nil {
+			return nil
+		}
+
+		c := stream.Cursor()
+		for k, v := c.Seek(sequenceKey(uint64(after + 1))); k != nil && len(events) < limit; k, v = c.Next() {
+			e, err := recordedEvent(tx, k, v)
+			if err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+func (p *Storage) ReadStream(id eventsourcing.AggregateID, from int64, to int64) ([]eventsourcing.RecordedEvent, error) {
+	events := []eventsourcing.RecordedEvent{}
+	err := p.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketName(id))
+		if b == nil {
+			return nil
+		}
+
+		positions := tx.Bucket(positionBucketName(id))
+		if positions == nil {
+			return fmt.Errorf("aggregate %s has no position index", id)
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek(sequenceKey(uint64(from))); k != nil; k, v = c.Next() {
+			sequence := int64(binary.BigEndian.Uint64(k))
+			if to > 0 && sequence > to {
+				break
+			}
+
+			var e iEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			position := positions.Get(k)
+			if position == nil {
+				return fmt.Errorf("aggregate %s has no position for sequence %d", id, sequence)
+			}
+			r := eventsourcing.RecordedEvent{
+				Position:    int64(binary.BigEndian.Uint64(position)),
+				AggregateID: id,
+				Sequence:    sequence,
+				Data:        append([]byte{}, v...),
+			}
+			r.SetMeta(e.Meta)
+			events = append(events, r)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// EventByID scans the global stream, bolt has no index on event ids.
+func (p *Storage) EventByID(id eventsourcing.EventID) (eventsourcing.RecordedEvent, bool, error) {
+	var event eventsourcing.RecordedEvent
+	isFound := false
+	err := p.scanStream(eventsourcing.EventFilter{}, func(e eventsourcing.RecordedEvent) bool {
+		if e.ID == id {
+			event = e
+			isFound = true
+		}
+		return !isFound
+	})
+
+	return event, isFound, err
+}
+
+func (p *Storage) ReadEvents(f eventsourcing.EventFilter) ([]eventsourcing.RecordedEvent, error) {
+	events := []eventsourcing.RecordedEvent{}
+	err := p.scanStream(f, func(e eventsourcing.RecordedEvent) bool {
+		events = append(events, e)
+		return len(events) != f.Limit
+	})
+
+	return events, err
+}
+
+func (p *Storage) CountEvents(f eventsourcing.EventFilter) (int64, error) {
+	var n int64
+	err := p.scanStream(f, func(e eventsourcing.RecordedEvent) bool {
+		n++
+		return true
+	})
+
+	return n, err
+}
+
+func (p *Storage) AggregateIDs(after eventsourcing.AggregateID, limit int) ([]eventsourcing.AggregateID, error) {
+	ids := []eventsourcing.AggregateID{}
+	err := p.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Cursor()
+		prefix := bucketName("")
+		for k, _ := c.Seek(bucketName(after)); k != nil && bytes.HasPrefix(k, prefix) && len(ids) < limit; k, _ = c.Next() {
+			id := eventsourcing.AggregateID(bytes.TrimPrefix(k, prefix))
+			if id > after {
+				ids = append(ids, id)
+			}
+		}
+		return nil
+	})
+
+	return ids, err
+}
+
+// scanStream passes the events of the global stream matching f to fn until
+// fn returns false. The filter is applied in memory, so every scan reads the
+// stream after f.After.
+func (p *Storage) scanStream(f eventsourcing.EventFilter, fn func(e eventsourcing.RecordedEvent) bool) error {
+	return p.db.View(func(tx *bbolt.Tx) error {
+		stream := tx.Bucket(streamBucket)
+		if stream == nil {
+			return nil
+		}
+
+		c := stream.Cursor()
+		for k, v := c.Seek(sequenceKey(uint64(f.After + 1))); k != nil; k, v = c.Next() {
+			if f.AggregateID != "" {
+				var ref streamRef
+				if err := json.Unmarshal(v, &ref); err != nil {
+					return err
+				}
+				if ref.AggregateID != f.AggregateID {
+					continue
+				}
+			}
+
+			e, err := recordedEvent(tx, k, v)
+			if err != nil {
+				return err
+			}
+			if f.Matches(e) && !fn(e) {
+				return nil
+			}
+		}
+
+		return nil
+	})
+}
+
+func (p *Storage) Checkpoint(name string) (int64, error) {
+	var position int64
+	err := p.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(checkpointBucket)
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(name)); v != nil {
+			position = int64(binary.BigEndian.Uint64(v))
+		}
+		return nil
+	})
+
+	return position, err
+}
+
+func (p *Storage) SaveCheckpoint(name string, position int64) error {
+	return p.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(checkpointBucket)
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		return b.Put([]byte(name), sequenceKey(uint64(position)))
+	})
 }
 
 func (p *Storage) Close() error {
 	return p.db.Close()
 }
 
-func bucketName(a eventsourcing.Aggregate) []byte {
-	return []byte(fmt.Sprintf("aggregate-%s", a.AggregateID()))
+// recordedEvent resolves the stream reference v at position k.
+func recordedEvent(tx *bbolt.Tx, k []byte, v []byte) (eventsourcing.RecordedEvent, error) {
+	var ref streamRef
+	if err := json.Unmarshal(v, &ref); err != nil {
+		return eventsourcing.RecordedEvent{}, err
+	}
+
+	b := tx.Bucket(bucketName(ref.AggregateID))
+	if b == nil {
+		return eventsourcing.RecordedEvent{}, fmt.Errorf("stream references missing aggregate %s", ref.AggregateID)
+	}
+	data := b.Get(sequenceKey(uint64(ref.Sequence)))
+
+	var e iEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return eventsourcing.RecordedEvent{}, err
+	}
+
+	r := eventsourcing.RecordedEvent{
+		Position:    int64(binary.BigEndian.Uint64(k)),
+		AggregateID: ref.AggregateID,
+		Sequence:    ref.Sequence,
+		Data:        append([]byte{}, data...),
+	}
+	r.SetMeta(e.Meta)
+	return r, nil
+}
+
+func bucketName(id eventsourcing.AggregateID) []byte {
+	return []byte(fmt.Sprintf("aggregate-%s", id))
+}
+
+// positionBucketName is the index from the sequences of the events of
+// aggregate id to their positions in the global stream.
+func positionBucketName(id eventsourcing.AggregateID) []byte {
+	return []byte(fmt.Sprintf("position-%s", id))
+}
+
+func snapshotBucketName(id eventsourcing.AggregateID) []byte {
+	return []byte(fmt.Sprintf("snapshot-%s", id))
 }
 
-func eventID(a eventsourcing.Aggregate, e eventsourcing.Event) []byte {
-	return []byte(fmt.Sprintf("%s-%s", a.AggregateID(), e.Meta().ID))
+// sequenceKey encodes seq big endian so that the cursor returns events in
+// stream order.
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
 }
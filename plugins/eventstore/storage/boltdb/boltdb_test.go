@@ -0,0 +1,22 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/eventstore/storage/storagetest"
+	"github.com/sirupsen/logrus"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) eventsourcing.Storage {
+		s, err := NewBoltDBEventStorage(logrus.WithField("test", t.Name()), Config{
+			File: filepath.Join(t.TempDir(), "eventstore.db"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,210 @@
+package boltdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/bbolt"
+)
+
+// formatVersion is the layout of the bolt file. Version 1 keyed the events of
+// an aggregate by "<aggregate id>-<event id>" and had no global stream.
+// Version 2 keys them by their sequence and references them from the stream
+// bucket. Version 3 adds an index from sequences to stream positions per
+// aggregate.
+const formatVersion = 3
+
+type legacyEvent struct {
+	aggregateID eventsourcing.AggregateID
+	sequence    int64
+	createdAt   time.Time
+	key         []byte
+	data        []byte
+}
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported boltdb file format")
+
+	metaBucket = []byte("meta")
+	formatKey  = []byte("format")
+)
+
+// upgrade migrates files of older format versions and records the current
+// one. Files without version are version 1 or were created empty.
+func upgrade(l *logrus.Entry, db *bbolt.DB) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+
+		version := uint64(1)
+		if v := meta.Get(formatKey); v != nil {
+			version = binary.BigEndian.Uint64(v)
+		}
+		switch {
+		case version == formatVersion:
+			return nil
+		case version > formatVersion:
+			return fmt.Errorf("%w: version %d, supported up to %d", ErrUnsupportedFormat, version, formatVersion)
+		}
+
+		if version < 2 {
+			if err := upgradeV1(l, tx); err != nil {
+				return fmt.Errorf("upgrade from version 1: %w", err)
+			}
+		}
+		if err := upgradeV2(l, tx); err != nil {
+			return fmt.Errorf("upgrade from version 2: %w", err)
+		}
+		return meta.Put(formatKey, sequenceKey(formatVersion))
+	})
+}
+
+// upgradeV1 rewrites aggregate buckets of version 1 to sequence keys and
+// appends their events to the stream. Version 1 did not keep the order of
+// events, it is restored from their creation time.
+func upgradeV1(l *logrus.Entry, tx *bbolt.Tx) error {
+	var names [][]byte
+	err := tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+		if !bytes.HasPrefix(name, bucketName("")) {
+			return nil
+		}
+		if k, _ := b.Cursor().First(); k != nil && len(k) != 8 {
+			names = append(names, append([]byte{}, name...))
+		}
+		return nil
+	})
+	if err != nil || len(names) == 0 {
+		return err
+	}
+	if tx.Bucket(streamBucket) != nil {
+		return fmt.Errorf("%w: version 1 events in a file with a stream", ErrUnsupportedFormat)
+	}
+
+	var all []legacyEvent
+	for _, name := range names {
+		id := eventsourcing.AggregateID(bytes.TrimPrefix(name, bucketName("")))
+		events, err := rewriteBucket(tx, name, id)
+		if err != nil {
+			return fmt.Errorf("aggregate %s: %w", id, err)
+		}
+		all = append(all, events...)
+	}
+
+	stream, err := tx.CreateBucket(streamBucket)
+	if err != nil {
+		return fmt.Errorf("create bucket: %s", err)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if !all[i].createdAt.Equal(all[j].createdAt) {
+			return all[i].createdAt.Before(all[j].createdAt)
+		}
+		if all[i].aggregateID != all[j].aggregateID {
+			return all[i].aggregateID < all[j].aggregateID
+		}
+		return all[i].sequence < all[j].sequence
+	})
+	for _, e := range all {
+		ref, err := json.Marshal(streamRef{AggregateID: e.aggregateID, Sequence: e.sequence})
+		if err != nil {
+			return err
+		}
+		position, err := stream.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := stream.Put(sequenceKey(position), ref); err != nil {
+			return err
+		}
+	}
+
+	l.WithField("aggregates", len(names)).WithField("events", len(all)).Info("boltdb file upgraded to format version 2")
+	return nil
+}
+
+// rewriteBucket replaces the bucket name of aggregate id with one keyed by
+// sequence.
+func rewriteBucket(tx *bbolt.Tx, name []byte, id eventsourcing.AggregateID) ([]legacyEvent, error) {
+	var events []legacyEvent
+	err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+		if len(k) == 8 {
+			return fmt.Errorf("%w: version 1 and 2 keys in one bucket", ErrUnsupportedFormat)
+		}
+		var e iEvent
+		if err := json.Unmarshal(v, &e); err != nil {
+			return fmt.Errorf("event %s: %w", k, err)
+		}
+		events = append(events, legacyEvent{
+			aggregateID: id,
+			createdAt:   e.Meta.CreatedAt,
+			key:         append([]byte{}, k...),
+			data:        append([]byte{}, v...),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].createdAt.Equal(events[j].createdAt) {
+			return events[i].createdAt.Before(events[j].createdAt)
+		}
+		return bytes.Compare(events[i].key, events[j].key) < 0
+	})
+
+	if err := tx.DeleteBucket(name); err != nil {
+		return nil, err
+	}
+	b, err := tx.CreateBucket(name)
+	if err != nil {
+		return nil, fmt.Errorf("create bucket: %s", err)
+	}
+	for i := range events {
+		seq, err := b.NextSequence()
+		if err != nil {
+			return nil, err
+		}
+		if err := b.Put(sequenceKey(seq), events[i].data); err != nil {
+			return nil, err
+		}
+		events[i].sequence = int64(seq)
+	}
+	return events, nil
+}
+
+// upgradeV2 builds the position index of every aggregate from the stream.
+func upgradeV2(l *logrus.Entry, tx *bbolt.Tx) error {
+	stream := tx.Bucket(streamBucket)
+	if stream == nil {
+		return nil
+	}
+
+	var n int
+	err := stream.ForEach(func(k, v []byte) error {
+		var ref streamRef
+		if err := json.Unmarshal(v, &ref); err != nil {
+			return fmt.Errorf("position %d: %w", binary.BigEndian.Uint64(k), err)
+		}
+		positions, err := tx.CreateBucketIfNotExists(positionBucketName(ref.AggregateID))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		n++
+		return positions.Put(sequenceKey(uint64(ref.Sequence)), append([]byte{}, k...))
+	})
+	if err != nil {
+		return err
+	}
+
+	l.WithField("events", n).Info("boltdb file upgraded to format version 3")
+	return nil
+}
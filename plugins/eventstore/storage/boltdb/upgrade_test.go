@@ -0,0 +1,207 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/sirupsen/logrus"
+	"go.etcd.io/bbolt"
+)
+
+// writeV1 writes events the way version 1 did, keyed by aggregate and event
+// id.
+func writeV1(t *testing.T, file string, events map[eventsourcing.AggregateID][]eventsourcing.Meta) {
+	t.Helper()
+
+	db, err := bbolt.Open(file, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for id, metas := range events {
+			b, err := tx.CreateBucket(bucketName(id))
+			if err != nil {
+				return err
+			}
+			for _, m := range metas {
+				data, err := json.Marshal(iEvent{Meta: m})
+				if err != nil {
+					return err
+				}
+				if err := b.Put([]byte(fmt.Sprintf("%s-%s", id, m.ID)), data); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpgradeV1(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "v1.db")
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	meta := func(id string, minute int) eventsourcing.Meta {
+		return eventsourcing.Meta{ID: eventsourcing.EventID(id), Name: "event", Version: "1", CreatedAt: start.Add(time.Duration(minute) * time.Minute)}
+	}
+	// event ids sort against the creation order
+	writeV1(t, file, map[eventsourcing.AggregateID][]eventsourcing.Meta{
+		"a": {meta("z", 0), meta("y", 2), meta("x", 4)},
+		"b": {meta("w", 1), meta("v", 3)},
+	})
+
+	s, err := NewBoltDBEventStorage(logrus.WithField("test", t.Name()), Config{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	events, err := s.ReadStream("a", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, fmt.Sprintf("%s:%d@%d", e.ID, e.Sequence, e.Position))
+	}
+	if want := "[z:1@1 y:2@3 x:3@5]"; fmt.Sprint(got) != want {
+		t.Fatalf("got stream a %v, want %s", got, want)
+	}
+
+	all, err := s.ReadAll(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, e := range all {
+		got = append(got, string(e.ID))
+	}
+	if want := "[z w y v x]"; fmt.Sprint(got) != want {
+		t.Fatalf("got global stream %v, want %s", got, want)
+	}
+}
+
+func TestUpgradeV2(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "v2.db")
+	db, err := bbolt.Open(file, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a: z, b: y, a: x in the global stream
+	refs := []streamRef{{AggregateID: "a", Sequence: 1}, {AggregateID: "b", Sequence: 1}, {AggregateID: "a", Sequence: 2}}
+	ids := []eventsourcing.EventID{"z", "y", "x"}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucket(metaBucket)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(formatKey, sequenceKey(2)); err != nil {
+			return err
+		}
+		stream, err := tx.CreateBucket(streamBucket)
+		if err != nil {
+			return err
+		}
+		for i, ref := range refs {
+			b, err := tx.CreateBucketIfNotExists(bucketName(ref.AggregateID))
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(iEvent{Meta: eventsourcing.Meta{ID: ids[i], Name: "event", Version: "1"}})
+			if err != nil {
+				return err
+			}
+			if err := b.Put(sequenceKey(uint64(ref.Sequence)), data); err != nil {
+				return err
+			}
+			if err := b.SetSequence(uint64(ref.Sequence)); err != nil {
+				return err
+			}
+			v, err := json.Marshal(ref)
+			if err != nil {
+				return err
+			}
+			if err := stream.Put(sequenceKey(uint64(i+1)), v); err != nil {
+				return err
+			}
+		}
+		return stream.SetSequence(uint64(len(refs)))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewBoltDBEventStorage(logrus.WithField("test", t.Name()), Config{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	events, err := s.ReadStream("a", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, fmt.Sprintf("%s:%d@%d", e.ID, e.Sequence, e.Position))
+	}
+	if want := "[z:1@1 x:2@3]"; fmt.Sprint(got) != want {
+		t.Fatalf("got stream a %v, want %s", got, want)
+	}
+}
+
+func TestUpgradeIsRecorded(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "v1.db")
+	writeV1(t, file, map[eventsourcing.AggregateID][]eventsourcing.Meta{
+		"a": {{ID: "e", Name: "event", Version: "1"}},
+	})
+
+	for i := 0; i < 2; i++ {
+		s, err := NewBoltDBEventStorage(logrus.WithField("test", t.Name()), Config{File: file})
+		if err != nil {
+			t.Fatalf("open %d: %s", i, err)
+		}
+		events, err := s.ReadAll(0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("open %d: got %d events in stream, want 1", i, len(events))
+		}
+		s.Close()
+	}
+}
+
+func TestNewerFormatIsRefused(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "v3.db")
+	db, err := bbolt.Open(file, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucket(metaBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put(formatKey, sequenceKey(formatVersion+1))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewBoltDBEventStorage(logrus.WithField("test", t.Name()), Config{File: file})
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("got error %v, want %v", err, ErrUnsupportedFormat)
+	}
+}
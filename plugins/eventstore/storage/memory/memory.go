@@ -0,0 +1,254 @@
+package memory
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// Storage keeps all streams in memory. It behaves like the persistent
+	// storages and is meant for tests and local development.
+	Storage struct {
+		l           *logrus.Entry
+		mutex       sync.RWMutex
+		streams     map[eventsourcing.AggregateID][]eventsourcing.RecordedEvent
+		all         []eventsourcing.RecordedEvent
+		snapshots   map[eventsourcing.AggregateID][]eventsourcing.Snapshot
+		checkpoints map[string]int64
+	}
+
+	Config struct {
+		Enable bool `env:"MEMORY_STORAGE_ENABLE" yaml:"enable"`
+	}
+)
+
+func NewMemoryEventStorage(l *logrus.Entry) *Storage {
+	return &Storage{
+		l:           l,
+		streams:     map[eventsourcing.AggregateID][]eventsourcing.RecordedEvent{},
+		snapshots:   map[eventsourcing.AggregateID][]eventsourcing.Snapshot{},
+		checkpoints: map[string]int64{},
+	}
+}
+
+func (p *Storage) Name() string {
+	return "memory"
+}
+
+func (p *Storage) Append(a eventsourcing.Aggregate, events []eventsourcing.Event, expectedVersion int64) (int64, error) {
+	if a.AggregateID() == "" {
+		return 0, eventsourcing.ErrNoAggregateID
+	}
+	if len(events) == 0 {
+		return 0, eventsourcing.ErrNoEvents
+	}
+
+	// marshal before locking so that a failing event leaves the stream
+	// untouched
+	recorded := make([]eventsourcing.RecordedEvent, 0, len(events))
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			p.l.WithError(err).WithField("meta", e.Meta()).Error("could not marshal event")
+			return 0, err
+		}
+		r := eventsourcing.RecordedEvent{AggregateID: a.AggregateID(), Data: data}
+		r.SetMeta(e.Meta())
+		recorded = append(recorded, r)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stream := p.streams[a.AggregateID()]
+	version := int64(len(stream))
+	if expectedVersion != eventsourcing.AnyVersion && version != expectedVersion {
+		return 0, eventsourcing.ConflictError(a.AggregateID(), expectedVersion, version)
+	}
+
+	for _, e := range recorded {
+		version++
+		e.Sequence = version
+		e.Position = int64(len(p.all)) + 1
+		stream = append(stream, e)
+		p.all = append(p.all, e)
+	}
+	p.streams[a.AggregateID()] = stream
+
+	return version, nil
+}
+
+func (p *Storage) Load(a eventsourcing.Aggregate) (int64, error) {
+	return p.LoadAfter(a, 0)
+}
+
+func (p *Storage) LoadAfter(a eventsourcing.Aggregate, after int64) (int64, error) {
+	if a.AggregateID() == "" {
+		return 0, eventsourcing.ErrNoAggregateID
+	}
+
+	// copy so that OnRaw may use the storage
+	p.mutex.RLock()
+	stream := append([]eventsourcing.RecordedEvent{}, p.streams[a.AggregateID()]...)
+	p.mutex.RUnlock()
+
+	version := after
+	for _, e := range stream {
+		if e.Sequence <= after {
+			continue
+		}
+		if err := a.OnRaw(e.Name, e.Version, e.Data); err != nil {
+			p.l.WithError(err).WithField("sequence", e.Sequence).Error("could not load event")
+			return 0, err
+		}
+	}
+	if n := int64(len(stream)); n > version {
+		version = n
+	}
+
+	return version, nil
+}
+
+func (p *Storage) SaveSnapshot(snapshot eventsourcing.Snapshot) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	snapshots := p.snapshots[snapshot.AggregateID]
+	for _, s := range snapshots {
+		if s.Version == snapshot.Version {
+			return nil
+		}
+	}
+
+	snapshot.Data = append([]byte{}, snapshot.Data...)
+	snapshots = append(snapshots, snapshot)
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Version < snapshots[j].Version })
+	p.snapshots[snapshot.AggregateID] = snapshots
+
+	return nil
+}
+
+func (p *Storage) LatestSnapshot(id eventsourcing.AggregateID) (eventsourcing.Snapshot, bool, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	snapshots := p.snapshots[id]
+	if len(snapshots) == 0 {
+		return eventsourcing.Snapshot{}, false, nil
+	}
+	return snapshots[len(snapshots)-1], true, nil
+}
+
+func (p *Storage) ReadAll(after int64, limit int) ([]eventsourcing.RecordedEvent, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	events := []eventsourcing.RecordedEvent{}
+	for i := after; i < int64(len(p.all)) && len(events) < limit; i++ {
+		if i < 0 {
+			continue
+		}
+		events = append(events, p.all[i])
+	}
+
+	return events, nil
+}
+
+func (p *Storage) ReadStream(id eventsourcing.AggregateID, from int64, to int64) ([]eventsourcing.RecordedEvent, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	events := []eventsourcing.RecordedEvent{}
+	for _, e := range p.streams[id] {
+		if e.Sequence >= from && (to <= 0 || e.Sequence <= to) {
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
+
+func (p *Storage) EventByID(id eventsourcing.EventID) (eventsourcing.RecordedEvent, bool, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for _, e := range p.all {
+		if e.ID == id {
+			return e, true, nil
+		}
+	}
+
+	return eventsourcing.RecordedEvent{}, false, nil
+}
+
+func (p *Storage) ReadEvents(f eventsourcing.EventFilter) ([]eventsourcing.RecordedEvent, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	events := []eventsourcing.RecordedEvent{}
+	for _, e := range p.all {
+		if e.Position > f.After && f.Matches(e) {
+			events = append(events, e)
+			if len(events) == f.Limit {
+				break
+			}
+		}
+	}
+
+	return events, nil
+}
+
+func (p *Storage) AggregateIDs(after eventsourcing.AggregateID, limit int) ([]eventsourcing.AggregateID, error) {
+	p.mutex.RLock()
+	ids := make([]eventsourcing.AggregateID, 0, len(p.streams))
+	for id := range p.streams {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	p.mutex.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	return ids, nil
+}
+
+func (p *Storage) CountEvents(f eventsourcing.EventFilter) (int64, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var n int64
+	for _, e := range p.all {
+		if e.Position > f.After && f.Matches(e) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (p *Storage) Checkpoint(name string) (int64, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.checkpoints[name], nil
+}
+
+func (p *Storage) SaveCheckpoint(name string, position int64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.checkpoints[name] = position
+	return nil
+}
+
+func (p *Storage) Close() error {
+	return nil
+}
@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+	"github.com/siklol/zinc/plugins/eventstore/storage/storagetest"
+	"github.com/sirupsen/logrus"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) eventsourcing.Storage {
+		return NewMemoryEventStorage(logrus.WithField("test", t.Name()))
+	})
+}
@@ -0,0 +1,4 @@
+drop index if exists events_aggregate_sequence;
+
+alter table events
+    drop column if exists sequence;
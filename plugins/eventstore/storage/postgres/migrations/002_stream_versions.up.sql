@@ -0,0 +1,14 @@
+alter table events
+    add column sequence bigint;
+
+update events e
+set sequence = s.sequence
+from (select id, row_number() over (partition by aggregate_id order by created_at, id) as sequence
+      from events) s
+where e.id = s.id;
+
+alter table events
+    alter column sequence set not null;
+
+create unique index events_aggregate_sequence
+    on events (aggregate_id, sequence);
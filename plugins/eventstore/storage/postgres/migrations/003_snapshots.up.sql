@@ -0,0 +1,11 @@
+create table snapshots
+(
+    aggregate_id text not null,
+    version bigint not null,
+    created_at timestamp not null,
+    data bytea not null
+);
+
+alter table snapshots
+    add constraint snapshots_pk
+        primary key (aggregate_id, version);
@@ -0,0 +1,6 @@
+drop table if exists projection_checkpoints;
+
+drop index if exists events_position;
+
+alter table events
+    drop column if exists position;
@@ -0,0 +1,32 @@
+create sequence events_position_seq;
+
+alter table events
+    add column position bigint;
+
+update events e
+set position = s.position
+from (select id, row_number() over (order by created_at, id) as position
+      from events) s
+where e.id = s.id;
+
+select setval('events_position_seq', coalesce((select max(position) from events), 0) + 1, false);
+
+alter table events
+    alter column position set default nextval('events_position_seq'),
+    alter column position set not null;
+
+alter sequence events_position_seq owned by events.position;
+
+create unique index events_position
+    on events (position);
+
+create table projection_checkpoints
+(
+    name text not null,
+    position bigint not null,
+    updated_at timestamp not null
+);
+
+alter table projection_checkpoints
+    add constraint projection_checkpoints_pk
+        primary key (name);
@@ -0,0 +1,24 @@
+create table outbox
+(
+    id bigserial not null,
+    event_id text not null,
+    aggregate_id text not null,
+    sequence bigint not null,
+    position bigint not null,
+    name text not null,
+    version text not null,
+    data jsonb not null,
+    created_at timestamp,
+    attempts int not null default 0,
+    next_attempt_at timestamp not null default now(),
+    last_error text,
+    sent_at timestamp
+);
+
+alter table outbox
+    add constraint outbox_pk
+        primary key (id);
+
+create index outbox_pending
+    on outbox (next_attempt_at, id)
+    where sent_at is null;
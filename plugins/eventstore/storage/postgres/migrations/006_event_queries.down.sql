@@ -0,0 +1,3 @@
+drop index if exists events_created_at;
+
+drop index if exists events_name_position;
@@ -0,0 +1,5 @@
+create index events_name_position
+    on events (name, position);
+
+create index events_created_at
+    on events (created_at);
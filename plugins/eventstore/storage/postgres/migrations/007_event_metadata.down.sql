@@ -0,0 +1,15 @@
+alter table outbox
+    drop column if exists headers,
+    drop column if exists service,
+    drop column if exists actor,
+    drop column if exists causation_id,
+    drop column if exists correlation_id;
+
+drop index if exists events_correlation;
+
+alter table events
+    drop column if exists headers,
+    drop column if exists service,
+    drop column if exists actor,
+    drop column if exists causation_id,
+    drop column if exists correlation_id;
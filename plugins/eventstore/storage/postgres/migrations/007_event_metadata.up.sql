@@ -0,0 +1,23 @@
+alter table events
+    add column correlation_id text,
+    add column causation_id text,
+    add column actor text,
+    add column service text,
+    add column headers jsonb;
+
+update events
+set correlation_id = data -> 'meta' ->> 'correlation_id',
+    causation_id = data -> 'meta' ->> 'causation_id',
+    actor = data -> 'meta' ->> 'actor',
+    service = data -> 'meta' ->> 'service',
+    headers = data -> 'meta' -> 'headers';
+
+create index events_correlation
+    on events (correlation_id, position);
+
+alter table outbox
+    add column correlation_id text,
+    add column causation_id text,
+    add column actor text,
+    add column service text,
+    add column headers jsonb;
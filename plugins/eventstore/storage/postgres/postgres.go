@@ -4,8 +4,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
 	"github.com/sirupsen/logrus"
 )
@@ -20,10 +24,12 @@ type (
 
 	Config struct {
 		Enable          bool   `env:"POSTGRES_STORAGE_ENABLE" yaml:"enable"`
-		Uri             string `env:"POSTGRES_STORAGE_URI" default:"" yaml:"uri"`
+		Uri             string `env:"POSTGRES_STORAGE_URI" default:"" yaml:"uri" secret:"true"`
 		DatabaseName    string `env:"POSTGRES_STORAGE_DATABASE_NAME" default:"" yaml:"databaseName"`
 		MigrationsPath  string `env:"POSTGRES_STORAGE_MIGRATIONS_PATH" default:"/migrations" yaml:"migrationsPath"`
 		MigrationsTable string `env:"POSTGRES_STORAGE_MIGRATIONS_TABLE" default:"" yaml:"migrationsTable"`
+		// Outbox writes every appended event to the outbox table as well.
+		Outbox bool `env:"POSTGRES_STORAGE_OUTBOX" default:"false" yaml:"outbox"`
 	}
 
 	Migrator interface {
@@ -31,22 +37,34 @@ type (
 	}
 )
 
+const (
+	sequenceIndex   = "events_aggregate_sequence"
+	recordedColumns = "id, " + eventColumns
+	eventColumns    = "position, aggregate_id, sequence, name, version, created_at, data, correlation_id, causation_id, actor, service, headers"
+	uniqueViolation = "23505"
+	// advisory lock keys of Append
+	positionLock  = 7291375
+	aggregateLock = 7291376
+)
+
 var (
-	ErrNoAggregateID = errors.New("aggregate id missing. cannot load aggregate")
+	ErrNoAggregateID = eventsourcing.ErrNoAggregateID
 )
 
-func NewPostgresEventStorage(l *logrus.Entry, cfg Config, m Migrator) *Storage {
-	l.WithField("config", cfg).Trace("storage postgres config")
+// NewPostgresEventStorage opens the database and runs the migrations.
+func NewPostgresEventStorage(l *logrus.Entry, cfg Config, m Migrator) (*Storage, error) {
+	// the uri holds the resolved password and is never logged
+	l.WithFields(logrus.Fields{"database": cfg.DatabaseName, "migrations": cfg.MigrationsPath, "outbox": cfg.Outbox}).
+		Trace("storage postgres config")
 
 	db, err := sqlx.Open("postgres", cfg.Uri)
 	if err != nil {
-		l.WithError(err).Fatal("Database could not be opened")
+		return nil, fmt.Errorf("open database: %w", err)
 	}
 
-	l.Trace(cfg.MigrationsPath)
-
 	if err := m.PerformMigrations(db.DB, "file://"+cfg.MigrationsPath, cfg.DatabaseName); err != nil {
-		l.WithError(err).Fatal("error running migrations")
+		db.Close()
+		return nil, fmt.Errorf("run migrations: %w", err)
 	}
 
 	return &Storage{
@@ -54,81 +72,293 @@ func NewPostgresEventStorage(l *logrus.Entry, cfg Config, m Migrator) *Storage {
 		cfg: cfg,
 		db:  db,
 		m:   m,
-	}
+	}, nil
 }
 
-func (p *Storage) Upsert(a eventsourcing.Aggregate) error {
-	events := a.Events()
+func (p *Storage) Append(a eventsourcing.Aggregate, events []eventsourcing.Event, expectedVersion int64) (int64, error) {
+	if a.AggregateID() == "" {
+		return 0, ErrNoAggregateID
+	}
+	if len(events) == 0 {
+		return 0, eventsourcing.ErrNoEvents
+	}
+
+	// marshal before the transaction so that no lock is held meanwhile
+	rows := make([]map[string]interface{}, 0, len(events))
+	for _, e := range events {
+		m := e.Meta()
+		data, err := json.Marshal(e)
+		if err != nil {
+			return 0, err
+		}
+		headers, err := marshalHeaders(m.Headers)
+		if err != nil {
+			return 0, err
+		}
+		rows = append(rows, map[string]interface{}{
+			"id":            m.ID,
+			"aggregateId":   a.AggregateID(),
+			"name":          m.Name,
+			"version":       m.Version,
+			"createdAt":     m.CreatedAt,
+			"data":          data,
+			"correlationId": nullString(m.CorrelationID),
+			"causationId":   nullString(m.CausationID),
+			"actor":         nullString(m.Actor),
+			"service":       nullString(m.Service),
+			"headers":       headers,
+		})
+	}
 
 	tx, err := p.db.Beginx()
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	// writers of the same aggregate wait for each other, so AnyVersion
+	// appends do not collide on the sequence index
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1, hashtext($2))", aggregateLock, a.AggregateID()); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	var version int64
+	if err := tx.Get(&version, "SELECT COALESCE(MAX(sequence), 0) FROM events WHERE aggregate_id = $1", a.AggregateID()); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if expectedVersion != eventsourcing.AnyVersion && version != expectedVersion {
+		tx.Rollback()
+		return 0, eventsourcing.ConflictError(a.AggregateID(), expectedVersion, version)
+	}
+	previous := version
+
+	// positions are allocated from here to the commit one append at a time,
+	// so that they become visible in order and stream readers never skip an
+	// event committed late
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", positionLock); err != nil {
+		tx.Rollback()
+		return 0, err
 	}
 
 	query := `
-		INSERT INTO events (id, aggregate_id, name, version, created_at, data) 
-    	VALUES (:id, :aggregateId, :name, :version, :createdAt, :data)
-    	ON CONFLICT (id) DO UPDATE 
-		  SET id = :id, 
-		      aggregate_id = :aggregateId,
-			  name = :name,
-		      version = :version,
-		      created_at = :createdAt,
-		      data = :data;
+		INSERT INTO events (id, aggregate_id, sequence, name, version, created_at, data, correlation_id, causation_id, actor, service, headers) 
+    	VALUES (:id, :aggregateId, :sequence, :name, :version, :createdAt, :data, :correlationId, :causationId, :actor, :service, :headers);
     	`
-	for _, e := range events {
-		m := e.Meta()
-		data, err := json.Marshal(e)
-
-		if err != nil {
+	for _, row := range rows {
+		version++
+		row["sequence"] = version
+		if _, err := tx.NamedExec(query, row); err != nil {
 			tx.Rollback()
-			return err
+			if isUniqueViolation(err, sequenceIndex) {
+				return 0, eventsourcing.ConflictError(a.AggregateID(), expectedVersion, version)
+			}
+			return 0, err
 		}
+	}
 
-		_, err = tx.NamedExec(query, map[string]interface{}{
-			"id":          m.ID,
-			"aggregateId": a.AggregateID(),
-			"name":        m.Name,
-			"version":     m.Version,
-			"createdAt":   m.CreatedAt,
-			"data":        data,
-		})
+	if p.cfg.Outbox {
+		_, err := tx.Exec(`
+			INSERT INTO outbox (event_id, aggregate_id, sequence, position, name, version, data, created_at, correlation_id, causation_id, actor, service, headers)
+			SELECT id, aggregate_id, sequence, position, name, version, data, created_at, correlation_id, causation_id, actor, service, headers
+			FROM events WHERE aggregate_id = $1 AND sequence > $2 ORDER BY sequence ASC;
+			`, a.AggregateID(), previous)
 		if err != nil {
 			tx.Rollback()
-			return err
+			return 0, err
 		}
 	}
 
-	return tx.Commit()
+	return version, tx.Commit()
+}
+
+func (p *Storage) Load(a eventsourcing.Aggregate) (int64, error) {
+	return p.LoadAfter(a, 0)
 }
 
-func (p *Storage) Load(a eventsourcing.Aggregate) error {
+func (p *Storage) LoadAfter(a eventsourcing.Aggregate, after int64) (int64, error) {
 	if a.AggregateID() == "" {
-		return ErrNoAggregateID
+		return 0, ErrNoAggregateID
 	}
 
-	rows, err := p.db.NamedQuery("SELECT data, name, version FROM events WHERE aggregate_id = :id ORDER BY created_at ASC", map[string]interface{}{
-		"id": a.AggregateID(),
+	rows, err := p.db.NamedQuery("SELECT data, name, version, sequence FROM events WHERE aggregate_id = :id AND sequence > :after ORDER BY sequence ASC", map[string]interface{}{
+		"id":    a.AggregateID(),
+		"after": after,
 	})
 	if err != nil {
 		p.l.WithError(err).Error("could not execute aggregate loading query")
-		return err
+		return 0, err
 	}
+	defer rows.Close()
 
+	sequence := after
 	for rows.Next() {
 		var data []byte
 		var name string
 		var version string
-		if err = rows.Scan(&data, &name, &version); err != nil {
+		if err = rows.Scan(&data, &name, &version, &sequence); err != nil {
 			p.l.WithError(err).Error("could not execute aggregate loading query")
-			return err
+			return 0, err
 		}
 		if err := a.OnRaw(name, version, data); err != nil {
 			p.l.WithError(err).Error("could not rebuild aggregate")
+			return 0, err
 		}
 	}
 
-	return nil
+	return sequence, rows.Err()
+}
+
+func (p *Storage) SaveSnapshot(snapshot eventsourcing.Snapshot) error {
+	_, err := p.db.NamedExec(`
+		INSERT INTO snapshots (aggregate_id, version, created_at, data)
+		VALUES (:aggregateId, :version, :createdAt, :data)
+		ON CONFLICT (aggregate_id, version) DO NOTHING;
+		`, map[string]interface{}{
+		"aggregateId": snapshot.AggregateID,
+		"version":     snapshot.Version,
+		"createdAt":   snapshot.CreatedAt,
+		"data":        snapshot.Data,
+	})
+	return err
+}
+
+func (p *Storage) LatestSnapshot(id eventsourcing.AggregateID) (eventsourcing.Snapshot, bool, error) {
+	snapshot := eventsourcing.Snapshot{AggregateID: id}
+	err := p.db.QueryRowx("SELECT version, created_at, data FROM snapshots WHERE aggregate_id = $1 ORDER BY version DESC LIMIT 1", id).
+		Scan(&snapshot.Version, &snapshot.CreatedAt, &snapshot.Data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return eventsourcing.Snapshot{}, false, nil
+	}
+	if err != nil {
+		return eventsourcing.Snapshot{}, false, err
+	}
+
+	return snapshot, true, nil
+}
+
+func (p *Storage) ReadAll(after int64, limit int) ([]eventsourcing.RecordedEvent, error) {
+	return p.queryEvents("SELECT "+recordedColumns+" FROM events WHERE position > $1 ORDER BY position ASC LIMIT $2", after, limit)
+}
+
+func (p *Storage) ReadStream(id eventsourcing.AggregateID, from int64, to int64) ([]eventsourcing.RecordedEvent, error) {
+	if to <= 0 {
+		return p.queryEvents("SELECT "+recordedColumns+" FROM events WHERE aggregate_id = $1 AND sequence >= $2 ORDER BY sequence ASC", id, from)
+	}
+	return p.queryEvents("SELECT "+recordedColumns+" FROM events WHERE aggregate_id = $1 AND sequence BETWEEN $2 AND $3 ORDER BY sequence ASC", id, from, to)
+}
+
+func (p *Storage) EventByID(id eventsourcing.EventID) (eventsourcing.RecordedEvent, bool, error) {
+	events, err := p.queryEvents("SELECT "+recordedColumns+" FROM events WHERE id = $1", id)
+	if err != nil || len(events) == 0 {
+		return eventsourcing.RecordedEvent{}, false, err
+	}
+	return events[0], true, nil
+}
+
+func (p *Storage) ReadEvents(f eventsourcing.EventFilter) ([]eventsourcing.RecordedEvent, error) {
+	where, args := filterClause(f)
+	query := "SELECT " + recordedColumns + " FROM events WHERE " + where + " ORDER BY position ASC"
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return p.queryEvents(query, args...)
+}
+
+func (p *Storage) CountEvents(f eventsourcing.EventFilter) (int64, error) {
+	where, args := filterClause(f)
+
+	var n int64
+	err := p.db.Get(&n, "SELECT COUNT(*) FROM events WHERE "+where, args...)
+	return n, err
+}
+
+func (p *Storage) AggregateIDs(after eventsourcing.AggregateID, limit int) ([]eventsourcing.AggregateID, error) {
+	ids := []eventsourcing.AggregateID{}
+	err := p.db.Select(&ids, "SELECT DISTINCT aggregate_id FROM events WHERE aggregate_id > $1 ORDER BY aggregate_id ASC LIMIT $2", after, limit)
+	return ids, err
+}
+
+// queryEvents runs a query selecting recordedColumns.
+func (p *Storage) queryEvents(query string, args ...interface{}) ([]eventsourcing.RecordedEvent, error) {
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []eventsourcing.RecordedEvent{}
+	for rows.Next() {
+		e, err := scanRecorded(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (p *Storage) Checkpoint(name string) (int64, error) {
+	var position int64
+	err := p.db.Get(&position, "SELECT position FROM projection_checkpoints WHERE name = $1", name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return position, err
+}
+
+func (p *Storage) SaveCheckpoint(name string, position int64) error {
+	_, err := p.db.Exec(`
+		INSERT INTO projection_checkpoints (name, position, updated_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (name) DO UPDATE SET position = $2, updated_at = now();
+		`, name, position)
+	return err
+}
+
+func (p *Storage) PendingOutbox(limit int) ([]eventsourcing.OutboxEntry, error) {
+	rows, err := p.db.Query(`
+		SELECT o.id, o.attempts, o.event_id, `+qualified("o", eventColumns)+`
+		FROM outbox o
+		WHERE o.sent_at IS NULL AND o.next_attempt_at <= now()
+		  AND NOT EXISTS (
+		      SELECT 1 FROM outbox w
+		      WHERE w.aggregate_id = o.aggregate_id AND w.sent_at IS NULL AND w.id < o.id AND w.next_attempt_at > now()
+		  )
+		ORDER BY o.id ASC
+		LIMIT $1;
+		`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []eventsourcing.OutboxEntry{}
+	for rows.Next() {
+		var o eventsourcing.OutboxEntry
+		var err error
+		o.Event, err = scanRecorded(rows.Scan, &o.ID, &o.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		o.EventID = o.Event.ID
+		entries = append(entries, o)
+	}
+
+	return entries, rows.Err()
+}
+
+func (p *Storage) MarkSent(id int64) error {
+	_, err := p.db.Exec("UPDATE outbox SET sent_at = now(), last_error = NULL WHERE id = $1", id)
+	return err
+}
+
+func (p *Storage) MarkFailed(id int64, nextAttempt time.Time, cause error) error {
+	_, err := p.db.Exec("UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3 WHERE id = $1", id, nextAttempt, cause.Error())
+	return err
 }
 
 func (p *Storage) Name() string {
@@ -136,5 +366,92 @@ func (p *Storage) Name() string {
 }
 
 func (p *Storage) Close() error {
-	return nil
+	return p.db.Close()
+}
+
+// scanRecorded scans a row of recordedColumns preceded by the columns of
+// leading. The id may be named differently, e.g. event_id in the outbox.
+func scanRecorded(scan func(dest ...interface{}) error, leading ...interface{}) (eventsourcing.RecordedEvent, error) {
+	var e eventsourcing.RecordedEvent
+	var createdAt sql.NullTime
+	var correlationID, causationID, actor, service sql.NullString
+	var headers []byte
+
+	dest := append(leading, &e.ID, &e.Position, &e.AggregateID, &e.Sequence, &e.Name, &e.Version, &createdAt, &e.Data, &correlationID, &causationID, &actor, &service, &headers)
+	if err := scan(dest...); err != nil {
+		return e, err
+	}
+
+	e.CreatedAt = createdAt.Time
+	e.CorrelationID = correlationID.String
+	e.CausationID = causationID.String
+	e.Actor = actor.String
+	e.Service = service.String
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &e.Headers); err != nil {
+			return e, err
+		}
+	}
+
+	return e, nil
+}
+
+// qualified prefixes every column of columns with table.
+func qualified(table string, columns string) string {
+	cs := strings.Split(columns, ", ")
+	for i := range cs {
+		cs[i] = table + "." + cs[i]
+	}
+	return strings.Join(cs, ", ")
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// marshalHeaders returns nil for empty headers so that the column stays
+// NULL.
+func marshalHeaders(headers map[string]string) ([]byte, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(headers)
+}
+
+// filterClause returns the where clause of f and its arguments.
+func filterClause(f eventsourcing.EventFilter) (string, []interface{}) {
+	conditions := []string{"position > $1"}
+	args := []interface{}{f.After}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.AggregateID != "" {
+		add("aggregate_id = $%d", f.AggregateID)
+	}
+	if f.Name != "" {
+		add("name = $%d", f.Name)
+	}
+	if f.CorrelationID != "" {
+		add("correlation_id = $%d", f.CorrelationID)
+	}
+	if f.Version != "" {
+		add("version = $%d", f.Version)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("created_at < $%d", f.Until)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// isUniqueViolation reports whether err was caused by a concurrent insert into
+// the unique index.
+func isUniqueViolation(err error, index string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == index
 }
@@ -0,0 +1,608 @@
+// Package storagetest contains the conformance suite every
+// eventsourcing.Storage has to pass. Call Run from the tests of a storage:
+//
+//	func TestStorage(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) eventsourcing.Storage {
+//			return memory.NewMemoryEventStorage(logrus.WithField("test", t.Name()))
+//		})
+//	}
+//
+// Aggregate ids are random so that persistent storages can share a database
+// between runs. Optional interfaces such as eventsourcing.SnapshotStorage and
+// eventsourcing.StreamStorage are tested if the storage implements them.
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/siklol/zinc/plugins/eventstore/eventsourcing"
+)
+
+type (
+	// Factory returns a new storage. Close is called at the end of the test.
+	Factory func(t *testing.T) eventsourcing.Storage
+
+	testEvent struct {
+		M     eventsourcing.Meta `json:"meta"`
+		Value int                `json:"value"`
+	}
+
+	testAggregate struct {
+		id     eventsourcing.AggregateID
+		values []int
+		names  []string
+		metas  []eventsourcing.Meta
+	}
+)
+
+// Run runs the conformance suite against the storages built by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := map[string]func(t *testing.T, s eventsourcing.Storage){
+		"LoadMissingAggregate":  testLoadMissingAggregate,
+		"AppendAndLoad":         testAppendAndLoad,
+		"ConcurrencyConflict":   testConcurrencyConflict,
+		"AnyVersion":            testAnyVersion,
+		"InvalidAppend":         testInvalidAppend,
+		"IsolatedStreams":       testIsolatedStreams,
+		"Snapshots":             testSnapshots,
+		"GlobalStream":          testGlobalStream,
+		"Checkpoints":           testCheckpoints,
+		"LoadAfterMissingEvent": testLoadAfterMissingEvent,
+		"ReadStream":            testReadStream,
+		"ReadEvents":            testReadEvents,
+		"AggregateIDs":          testAggregateIDs,
+		"EventByID":             testEventByID,
+		"Metadata":              testMetadata,
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			s := newStorage(t)
+			t.Cleanup(func() {
+				if err := s.Close(); err != nil {
+					t.Errorf("close: %s", err)
+				}
+			})
+			test(t, s)
+		})
+	}
+}
+
+func testLoadMissingAggregate(t *testing.T, s eventsourcing.Storage) {
+	a := newAggregate()
+
+	version, err := s.Load(a)
+	if err != nil {
+		t.Fatalf("load missing aggregate: %s", err)
+	}
+	if version != 0 || len(a.values) != 0 {
+		t.Fatalf("missing aggregate: got version %d and %d events, want 0 and 0", version, len(a.values))
+	}
+}
+
+func testAppendAndLoad(t *testing.T, s eventsourcing.Storage) {
+	a := newAggregate()
+
+	version := mustAppend(t, s, a, 0, 1, 2, 3)
+	if version != 3 {
+		t.Fatalf("append: got version %d, want 3", version)
+	}
+	version = mustAppend(t, s, a, 3, 4)
+	if version != 4 {
+		t.Fatalf("second append: got version %d, want 4", version)
+	}
+
+	loaded := &testAggregate{id: a.id}
+	version, err := s.Load(loaded)
+	if err != nil {
+		t.Fatalf("load: %s", err)
+	}
+	if version != 4 {
+		t.Fatalf("load: got version %d, want 4", version)
+	}
+	assertValues(t, loaded.values, 1, 2, 3, 4)
+	for _, name := range loaded.names {
+		if name != "test@1.0" {
+			t.Fatalf("load: got event type %s, want test@1.0", name)
+		}
+	}
+}
+
+func testConcurrencyConflict(t *testing.T, s eventsourcing.Storage) {
+	a := newAggregate()
+	mustAppend(t, s, a, 0, 1, 2)
+
+	for _, expected := range []int64{0, 1, 3} {
+		_, err := s.Append(a, events(9), expected)
+		if !errors.Is(err, eventsourcing.ErrConcurrencyConflict) {
+			t.Fatalf("append at stale version %d: got %v, want ErrConcurrencyConflict", expected, err)
+		}
+	}
+
+	loaded := &testAggregate{id: a.id}
+	if _, err := s.Load(loaded); err != nil {
+		t.Fatalf("load: %s", err)
+	}
+	assertValues(t, loaded.values, 1, 2)
+}
+
+func testAnyVersion(t *testing.T, s eventsourcing.Storage) {
+	a := newAggregate()
+	mustAppend(t, s, a, 0, 1)
+
+	version, err := s.Append(a, events(2, 3), eventsourcing.AnyVersion)
+	if err != nil {
+		t.Fatalf("append with AnyVersion: %s", err)
+	}
+	if version != 3 {
+		t.Fatalf("append with AnyVersion: got version %d, want 3", version)
+	}
+}
+
+func testInvalidAppend(t *testing.T, s eventsourcing.Storage) {
+	if _, err := s.Append(newAggregate(), nil, 0); !errors.Is(err, eventsourcing.ErrNoEvents) {
+		t.Fatalf("append without events: got %v, want ErrNoEvents", err)
+	}
+
+	noID := &testAggregate{}
+	if _, err := s.Append(noID, events(1), 0); !errors.Is(err, eventsourcing.ErrNoAggregateID) {
+		t.Fatalf("append without id: got %v, want ErrNoAggregateID", err)
+	}
+	if _, err := s.Load(noID); !errors.Is(err, eventsourcing.ErrNoAggregateID) {
+		t.Fatalf("load without id: got %v, want ErrNoAggregateID", err)
+	}
+}
+
+func testIsolatedStreams(t *testing.T, s eventsourcing.Storage) {
+	a := newAggregate()
+	b := newAggregate()
+
+	mustAppend(t, s, a, 0, 1, 2)
+	if version := mustAppend(t, s, b, 0, 10); version != 1 {
+		t.Fatalf("append to second aggregate: got version %d, want 1", version)
+	}
+
+	loaded := &testAggregate{id: b.id}
+	if _, err := s.Load(loaded); err != nil {
+		t.Fatalf("load: %s", err)
+	}
+	assertValues(t, loaded.values, 10)
+}
+
+func testSnapshots(t *testing.T, s eventsourcing.Storage) {
+	ss, isOk := s.(eventsourcing.SnapshotStorage)
+	if !isOk {
+		t.Skip("storage does not support snapshots")
+	}
+
+	a := newAggregate()
+	if _, isFound, err := ss.LatestSnapshot(a.id); err != nil || isFound {
+		t.Fatalf("latest snapshot of new aggregate: got %t, %v, want false, nil", isFound, err)
+	}
+
+	mustAppend(t, s, a, 0, 1, 2, 3, 4)
+	for _, v := range []int64{2, 4} {
+		err := ss.SaveSnapshot(eventsourcing.Snapshot{AggregateID: a.id, Version: v, CreatedAt: time.Now(), Data: []byte(fmt.Sprint(v))})
+		if err != nil {
+			t.Fatalf("save snapshot: %s", err)
+		}
+	}
+
+	snapshot, isFound, err := ss.LatestSnapshot(a.id)
+	if err != nil || !isFound {
+		t.Fatalf("latest snapshot: got %t, %v, want true, nil", isFound, err)
+	}
+	if snapshot.Version != 4 || string(snapshot.Data) != "4" {
+		t.Fatalf("latest snapshot: got version %d with %q, want 4 with \"4\"", snapshot.Version, snapshot.Data)
+	}
+
+	loaded := &testAggregate{id: a.id}
+	version, err := ss.LoadAfter(loaded, 2)
+	if err != nil {
+		t.Fatalf("load after snapshot: %s", err)
+	}
+	if version != 4 {
+		t.Fatalf("load after snapshot: got version %d, want 4", version)
+	}
+	assertValues(t, loaded.values, 3, 4)
+}
+
+func testLoadAfterMissingEvent(t *testing.T, s eventsourcing.Storage) {
+	ss, isOk := s.(eventsourcing.SnapshotStorage)
+	if !isOk {
+		t.Skip("storage does not support snapshots")
+	}
+
+	a := newAggregate()
+	mustAppend(t, s, a, 0, 1, 2)
+
+	loaded := &testAggregate{id: a.id}
+	version, err := ss.LoadAfter(loaded, 2)
+	if err != nil {
+		t.Fatalf("load after last event: %s", err)
+	}
+	if version != 2 || len(loaded.values) != 0 {
+		t.Fatalf("load after last event: got version %d and %d events, want 2 and 0", version, len(loaded.values))
+	}
+}
+
+func testGlobalStream(t *testing.T, s eventsourcing.Storage) {
+	ss, isOk := s.(eventsourcing.StreamStorage)
+	if !isOk {
+		t.Skip("storage does not keep a global stream")
+	}
+
+	head := streamHead(t, ss)
+
+	a := newAggregate()
+	b := newAggregate()
+	mustAppend(t, s, a, 0, 1)
+	mustAppend(t, s, b, 0, 2)
+	mustAppend(t, s, a, 1, 3)
+
+	recorded, err := ss.ReadAll(head, 10)
+	if err != nil {
+		t.Fatalf("read all: %s", err)
+	}
+	if len(recorded) != 3 {
+		t.Fatalf("read all: got %d events, want 3", len(recorded))
+	}
+
+	want := []struct {
+		id       eventsourcing.AggregateID
+		sequence int64
+		value    int
+	}{{a.id, 1, 1}, {b.id, 1, 2}, {a.id, 2, 3}}
+	last := head
+	for i, e := range recorded {
+		if e.Position <= last {
+			t.Fatalf("read all: position %d after %d is not increasing", e.Position, last)
+		}
+		last = e.Position
+
+		var te testEvent
+		if err := json.Unmarshal(e.Data, &te); err != nil {
+			t.Fatalf("read all: unmarshal: %s", err)
+		}
+		if e.AggregateID != want[i].id || e.Sequence != want[i].sequence || te.Value != want[i].value {
+			t.Fatalf("read all: event %d is %s/%d/%d, want %s/%d/%d", i, e.AggregateID, e.Sequence, te.Value, want[i].id, want[i].sequence, want[i].value)
+		}
+		if e.Name != "test" || e.Version != "1.0" {
+			t.Fatalf("read all: got event type %s@%s, want test@1.0", e.Name, e.Version)
+		}
+	}
+
+	limited, err := ss.ReadAll(recorded[0].Position, 1)
+	if err != nil {
+		t.Fatalf("read all with limit: %s", err)
+	}
+	if len(limited) != 1 || limited[0].Position != recorded[1].Position {
+		t.Fatalf("read all with limit: got %d events, want the event at %d", len(limited), recorded[1].Position)
+	}
+}
+
+func testCheckpoints(t *testing.T, s eventsourcing.Storage) {
+	ss, isOk := s.(eventsourcing.StreamStorage)
+	if !isOk {
+		t.Skip("storage does not keep a global stream")
+	}
+
+	name := "storagetest-" + uuid.New().String()
+	if position, err := ss.Checkpoint(name); err != nil || position != 0 {
+		t.Fatalf("new checkpoint: got %d, %v, want 0, nil", position, err)
+	}
+
+	for _, position := range []int64{5, 3} {
+		if err := ss.SaveCheckpoint(name, position); err != nil {
+			t.Fatalf("save checkpoint: %s", err)
+		}
+		if got, err := ss.Checkpoint(name); err != nil || got != position {
+			t.Fatalf("checkpoint: got %d, %v, want %d, nil", got, err, position)
+		}
+	}
+}
+
+func testReadStream(t *testing.T, s eventsourcing.Storage) {
+	qs, isOk := s.(eventsourcing.QueryStorage)
+	if !isOk {
+		t.Skip("storage does not support queries")
+	}
+
+	a := newAggregate()
+	mustAppend(t, s, a, 0, 1, 2, 3, 4)
+
+	for _, tc := range []struct {
+		from int64
+		to   int64
+		want []int
+	}{{1, 0, []int{1, 2, 3, 4}}, {2, 3, []int{2, 3}}, {4, 10, []int{4}}, {5, 0, nil}} {
+		recorded, err := qs.ReadStream(a.id, tc.from, tc.to)
+		if err != nil {
+			t.Fatalf("read stream %d-%d: %s", tc.from, tc.to, err)
+		}
+		assertValues(t, recordedValues(t, recorded), tc.want...)
+		for i, e := range recorded {
+			if e.Sequence != tc.from+int64(i) || e.AggregateID != a.id || e.Position <= 0 {
+				t.Fatalf("read stream %d-%d: event %d has sequence %d and position %d", tc.from, tc.to, i, e.Sequence, e.Position)
+			}
+		}
+	}
+
+	recorded, err := qs.ReadStream(newAggregate().id, 1, 0)
+	if err != nil || len(recorded) != 0 {
+		t.Fatalf("read missing stream: got %d events, %v, want 0, nil", len(recorded), err)
+	}
+}
+
+func testReadEvents(t *testing.T, s eventsourcing.Storage) {
+	qs, isOk := s.(eventsourcing.QueryStorage)
+	if !isOk {
+		t.Skip("storage does not support queries")
+	}
+
+	name := "storagetest-" + uuid.New().String()
+	start := time.Now().Add(-time.Minute)
+	a := newAggregate()
+	b := newAggregate()
+	for _, e := range []struct {
+		a       *testAggregate
+		version int64
+		name    string
+		at      time.Time
+		value   int
+	}{{a, 0, name, start, 1}, {b, 0, "other", start, 2}, {a, 1, name, start.Add(time.Second), 3}, {b, 1, name, start.Add(2 * time.Second), 4}} {
+		event := &testEvent{M: eventsourcing.NewMeta(e.name, "1.0"), Value: e.value}
+		event.M.CreatedAt = e.at
+		if _, err := s.Append(e.a, []eventsourcing.Event{event}, e.version); err != nil {
+			t.Fatalf("append: %s", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		filter eventsourcing.EventFilter
+		want   []int
+	}{
+		{eventsourcing.EventFilter{Name: name}, []int{1, 3, 4}},
+		{eventsourcing.EventFilter{Name: name, Limit: 2}, []int{1, 3}},
+		{eventsourcing.EventFilter{Name: name, AggregateID: a.id}, []int{1, 3}},
+		{eventsourcing.EventFilter{Name: name, Version: "2.0"}, nil},
+		{eventsourcing.EventFilter{Name: name, Since: start.Add(time.Second)}, []int{3, 4}},
+		{eventsourcing.EventFilter{Name: name, Until: start.Add(2 * time.Second)}, []int{1, 3}},
+		{eventsourcing.EventFilter{AggregateID: b.id}, []int{2, 4}},
+	} {
+		recorded, err := qs.ReadEvents(tc.filter)
+		if err != nil {
+			t.Fatalf("read events %+v: %s", tc.filter, err)
+		}
+		assertValues(t, recordedValues(t, recorded), tc.want...)
+
+		n, err := qs.CountEvents(tc.filter)
+		if err != nil {
+			t.Fatalf("count events %+v: %s", tc.filter, err)
+		}
+		want := int64(len(tc.want))
+		if tc.filter.Limit > 0 {
+			want = 3
+		}
+		if n != want {
+			t.Fatalf("count events %+v: got %d, want %d", tc.filter, n, want)
+		}
+	}
+
+	first, err := qs.ReadEvents(eventsourcing.EventFilter{Name: name, Limit: 1})
+	if err != nil || len(first) != 1 {
+		t.Fatalf("read first page: got %d events, %v", len(first), err)
+	}
+	next, err := qs.ReadEvents(eventsourcing.EventFilter{Name: name, After: first[0].Position})
+	if err != nil {
+		t.Fatalf("read next page: %s", err)
+	}
+	assertValues(t, recordedValues(t, next), 3, 4)
+}
+
+func testAggregateIDs(t *testing.T, s eventsourcing.Storage) {
+	qs, isOk := s.(eventsourcing.QueryStorage)
+	if !isOk {
+		t.Skip("storage does not support queries")
+	}
+
+	// the ids share a random prefix so that they are listed next to each
+	// other in persistent storages
+	prefix := "storagetest-" + uuid.New().String()
+	var want []eventsourcing.AggregateID
+	for _, suffix := range []string{"-c", "-a", "-b"} {
+		a := &testAggregate{id: eventsourcing.AggregateID(prefix + suffix)}
+		mustAppend(t, s, a, 0, 1)
+	}
+	for _, suffix := range []string{"-a", "-b", "-c"} {
+		want = append(want, eventsourcing.AggregateID(prefix+suffix))
+	}
+
+	ids, err := qs.AggregateIDs(eventsourcing.AggregateID(prefix), 2)
+	if err != nil {
+		t.Fatalf("aggregate ids: %s", err)
+	}
+	if fmt.Sprint(ids) != fmt.Sprint(want[:2]) {
+		t.Fatalf("aggregate ids: got %v, want %v", ids, want[:2])
+	}
+
+	ids, err = qs.AggregateIDs(ids[1], 1)
+	if err != nil {
+		t.Fatalf("aggregate ids after %s: %s", want[1], err)
+	}
+	if len(ids) != 1 || ids[0] != want[2] {
+		t.Fatalf("aggregate ids after %s: got %v, want %v", want[1], ids, want[2:])
+	}
+}
+
+func testEventByID(t *testing.T, s eventsourcing.Storage) {
+	qs, isOk := s.(eventsourcing.QueryStorage)
+	if !isOk {
+		t.Skip("storage does not support queries")
+	}
+
+	a := newAggregate()
+	evs := events(1, 2)
+	if _, err := s.Append(a, evs, 0); err != nil {
+		t.Fatalf("append: %s", err)
+	}
+
+	id := evs[1].Meta().ID
+	e, isFound, err := qs.EventByID(id)
+	if err != nil || !isFound {
+		t.Fatalf("event by id: got %t, %v, want true, nil", isFound, err)
+	}
+	if e.ID != id || e.AggregateID != a.id || e.Sequence != 2 || e.Position <= 0 {
+		t.Fatalf("event by id: got %s of %s at %d/%d, want %s of %s at sequence 2", e.ID, e.AggregateID, e.Sequence, e.Position, id, a.id)
+	}
+
+	if _, isFound, err := qs.EventByID(eventsourcing.EventID(uuid.New().String())); err != nil || isFound {
+		t.Fatalf("missing event by id: got %t, %v, want false, nil", isFound, err)
+	}
+}
+
+func testMetadata(t *testing.T, s eventsourcing.Storage) {
+	ctx := eventsourcing.WithMetadata(context.Background(), eventsourcing.Metadata{
+		CorrelationID: "storagetest-correlation",
+		CausationID:   "storagetest-causation",
+		Actor:         "storagetest-actor",
+		Headers:       map[string]string{"tenant": "storagetest"},
+	})
+	e := &testEvent{M: eventsourcing.NewMetaFromContext(ctx, "test", "1.0"), Value: 1}
+	e.M.Service = "storagetest-service"
+	want := fmt.Sprint(e.M.CorrelationID, e.M.CausationID, e.M.Actor, e.M.Service, e.M.Headers)
+
+	a := newAggregate()
+	if _, err := s.Append(a, []eventsourcing.Event{e, &testEvent{M: eventsourcing.NewMeta("test", "1.0"), Value: 2}}, 0); err != nil {
+		t.Fatalf("append: %s", err)
+	}
+
+	loaded := &testAggregate{id: a.id}
+	if _, err := s.Load(loaded); err != nil {
+		t.Fatalf("load: %s", err)
+	}
+	m := loaded.metas[0]
+	if got := fmt.Sprint(m.CorrelationID, m.CausationID, m.Actor, m.Service, m.Headers); got != want {
+		t.Fatalf("load: got metadata %s, want %s", got, want)
+	}
+	if m := loaded.metas[1]; m.CorrelationID != "" || m.Actor != "" || len(m.Headers) != 0 {
+		t.Fatalf("load: got metadata %+v for an event without", m)
+	}
+
+	qs, isOk := s.(eventsourcing.QueryStorage)
+	if !isOk {
+		return
+	}
+	r, isFound, err := qs.EventByID(e.M.ID)
+	if err != nil || !isFound {
+		t.Fatalf("event by id: got %t, %v, want true, nil", isFound, err)
+	}
+	if got := fmt.Sprint(r.CorrelationID, r.CausationID, r.Actor, r.Service, r.Headers); got != want {
+		t.Fatalf("event by id: got metadata %s, want %s", got, want)
+	}
+	recorded, err := qs.ReadEvents(eventsourcing.EventFilter{CorrelationID: e.M.CorrelationID, AggregateID: a.id})
+	if err != nil || len(recorded) != 1 || recorded[0].ID != e.M.ID {
+		t.Fatalf("read events by correlation: got %d events, %v, want %s", len(recorded), err, e.M.ID)
+	}
+}
+
+func newAggregate() *testAggregate {
+	return &testAggregate{id: eventsourcing.AggregateID("storagetest-" + uuid.New().String())}
+}
+
+func events(values ...int) []eventsourcing.Event {
+	events := make([]eventsourcing.Event, 0, len(values))
+	for _, v := range values {
+		events = append(events, &testEvent{M: eventsourcing.NewMeta("test", "1.0"), Value: v})
+	}
+	return events
+}
+
+func mustAppend(t *testing.T, s eventsourcing.Storage, a *testAggregate, expectedVersion int64, values ...int) int64 {
+	t.Helper()
+
+	version, err := s.Append(a, events(values...), expectedVersion)
+	if err != nil {
+		t.Fatalf("append %v at version %d: %s", values, expectedVersion, err)
+	}
+	return version
+}
+
+func streamHead(t *testing.T, s eventsourcing.StreamStorage) int64 {
+	t.Helper()
+
+	var head int64
+	for {
+		recorded, err := s.ReadAll(head, 1000)
+		if err != nil {
+			t.Fatalf("read all: %s", err)
+		}
+		if len(recorded) == 0 {
+			return head
+		}
+		head = recorded[len(recorded)-1].Position
+	}
+}
+
+func recordedValues(t *testing.T, recorded []eventsourcing.RecordedEvent) []int {
+	t.Helper()
+
+	var values []int
+	for _, e := range recorded {
+		var te testEvent
+		if err := json.Unmarshal(e.Data, &te); err != nil {
+			t.Fatalf("unmarshal recorded event: %s", err)
+		}
+		values = append(values, te.Value)
+	}
+	return values
+}
+
+func assertValues(t *testing.T, got []int, want ...int) {
+	t.Helper()
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("replayed values %v, want %v", got, want)
+	}
+}
+
+func (e *testEvent) Meta() eventsourcing.Meta {
+	return e.M
+}
+
+func (a *testAggregate) AggregateID() eventsourcing.AggregateID {
+	return a.id
+}
+
+func (a *testAggregate) On(e eventsourcing.Event) error {
+	te, isOk := e.(*testEvent)
+	if !isOk {
+		return fmt.Errorf("unexpected event %T", e)
+	}
+	a.values = append(a.values, te.Value)
+	a.names = append(a.names, te.M.Name+"@"+te.M.Version)
+	a.metas = append(a.metas, te.M)
+	return nil
+}
+
+func (a *testAggregate) OnRaw(name string, version string, data []byte) error {
+	var e testEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	if e.M.Name != name || e.M.Version != version {
+		return fmt.Errorf("event type %s@%s does not match meta %s@%s", name, version, e.M.Name, e.M.Version)
+	}
+	return a.On(&e)
+}
+
+func (a *testAggregate) Events() []eventsourcing.Event {
+	return nil
+}
@@ -2,7 +2,9 @@ package githelper
 
 import (
 	"errors"
+	"fmt"
 	"os/exec"
+	"reflect"
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
@@ -20,12 +22,17 @@ const (
 	Patch
 )
 
+var (
+	ErrUnknownGitVersion = errors.New("git version unknown")
+)
+
 type (
 	Semver int
 	Plugin struct {
 		Logger     *log.Entry
 		conf       Config
 		gitVersion string
+		bootErr    error
 	}
 
 	Config struct {
@@ -33,6 +40,18 @@ type (
 	}
 )
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "gitHelper",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -65,16 +84,19 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	l.Debug("checking for git version...")
 
 	if _, err := exec.LookPath("git"); err != nil {
-		log.WithError(err).Fatal("git not installed")
+		l.WithError(err).Error("git not installed")
+		return p.bootFailed(fmt.Errorf("git not installed: %w", err))
 	}
 	out, err := exec.Command("git", "version").Output()
 	if err != nil {
-		log.WithError(err).Fatal("git version failed")
+		l.WithError(err).Error("git version failed")
+		return p.bootFailed(fmt.Errorf("git version: %w", err))
 	}
 	parts := strings.Split(strings.Trim(string(out), "\n"), " ")
 
 	if len(parts) < 3 {
-		log.WithField("output", parts).Fatal("git version unknown. failing")
+		l.WithField("output", parts).Error("git version unknown. failing")
+		return p.bootFailed(ErrUnknownGitVersion)
 	}
 
 	p.gitVersion = parts[2]
@@ -83,6 +105,16 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	return p
 }
 
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
+func (p *Plugin) bootFailed(err error) plugins.Plugin {
+	p.bootErr = err
+	p.conf.Enable = false
+	return p
+}
+
 func (p *Plugin) IsEnabled() bool {
 	return p.conf.Enable
 }
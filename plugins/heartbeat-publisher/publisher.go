@@ -1,6 +1,8 @@
 package heartbeat_publisher
 
 import (
+	"errors"
+	"reflect"
 	"time"
 
 	nats "github.com/nats-io/nats.go"
@@ -14,13 +16,19 @@ import (
 
 const Name = "heartbeat-publisher"
 
+var (
+	ErrNoNatsConnection = errors.New("nats connection not available")
+)
+
 type (
 	Plugin struct {
-		logger *log.Entry
-		hbh    *heartbeat.Handler
-		id     xid.ID
-		conf   Config
-		nc     *nats.Conn
+		logger    *log.Entry
+		hbh       *heartbeat.Handler
+		id        xid.ID
+		conf      Config
+		nc        *nats.Conn
+		dependsOn []string
+		bootErr   error
 	}
 
 	Config struct {
@@ -32,6 +40,20 @@ type (
 	}
 )
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "heartbeatPublisher",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[xid.ID](),
+			plugins.TypeOf[*logrus.Entry](),
+			plugins.TypeOf[*natsPlugin.Plugin](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -62,10 +84,10 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 		case *logrus.Entry:
 			p.logger = dp.WithField("component", "heartbeat-publisher-plugin")
 		case *natsPlugin.Plugin:
-			if !dp.IsEnabled() {
-				return p
+			p.dependsOn = append(p.dependsOn, dp.Name())
+			if dp.IsEnabled() {
+				p.nc = dp.NC()
 			}
-			p.nc = dp.NC()
 		}
 	}
 	l := p.logger
@@ -76,7 +98,10 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	}
 
 	if p.nc == nil {
-		l.Fatal("nats connection not available. failing")
+		l.Error("nats connection not available. failing")
+		p.bootErr = ErrNoNatsConnection
+		p.conf.Enable = false
+		return p
 	}
 
 	l.Debug("starting up node Heartbeat")
@@ -95,6 +120,14 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	return p
 }
 
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
+func (p *Plugin) DependsOn() []string {
+	return p.dependsOn
+}
+
 func (p *Plugin) IsEnabled() bool {
 	return p.conf.Enable
 }
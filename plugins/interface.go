@@ -1,5 +1,7 @@
 package plugins
 
+import "context"
+
 type (
 	Plugin interface {
 		Boot(conf interface{}, dependencies ...interface{}) Plugin
@@ -8,4 +10,51 @@ type (
 		Close() error
 		IsEnabled() bool
 	}
+
+	// Dependent is implemented by plugins that need other plugins to be started
+	// before them and closed after them. DependsOn returns the plugin names.
+	Dependent interface {
+		DependsOn() []string
+	}
+
+	// ContextStarter is preferred by core over Start. The context carries the
+	// startup deadline.
+	ContextStarter interface {
+		StartContext(ctx context.Context) error
+	}
+
+	// ContextCloser is preferred by core over Close. The context carries the
+	// shutdown deadline of the plugin.
+	ContextCloser interface {
+		CloseContext(ctx context.Context) error
+	}
+
+	// BootReporter is implemented by plugins that disable themselves instead of
+	// exiting when Boot fails. BootError returns the reason or nil.
+	BootReporter interface {
+		BootError() error
+	}
+
+	// Reconfigurable is implemented by plugins that can apply a changed config
+	// section without a restart. newConf has the same type as the config
+	// passed to Boot.
+	Reconfigurable interface {
+		Reload(newConf interface{}) error
+	}
+
+	// CLIProvider is implemented by plugins that add subcommands to the
+	// cli. Commands of the application take precedence.
+	CLIProvider interface {
+		CLICommands() map[string]CLICommand
+	}
+
+	// CLICommand runs a subcommand with the positional arguments following
+	// its name.
+	CLICommand func(args []string) error
+
+	// HealthChecker is implemented by plugins that can verify the state of the
+	// resources they hold. A nil error means the plugin is ready to serve.
+	HealthChecker interface {
+		Health(ctx context.Context) error
+	}
 )
@@ -0,0 +1,41 @@
+package kafka
+
+import (
+	"github.com/hamba/avro"
+)
+
+// AvroCodec encodes payloads as avro binary of a fixed schema. Messages carry
+// no schema, so every schema needs its own content type, e.g.
+// "application/avro; schema=order.v1", to be decoded by Subscribe:
+//
+//	c, err := kafka.NewAvroCodec("application/avro; schema=order.v1", orderSchema)
+//	...
+//	kafka.RegisterCodec(c)
+//	kafka.Publish(ctx, p, "orders", id, order, kafka.WithCodec(c))
+//
+// Schema registries and their wire format are not supported.
+type AvroCodec struct {
+	contentType string
+	schema      avro.Schema
+}
+
+// NewAvroCodec parses schema, the avro schema in json.
+func NewAvroCodec(contentType string, schema string) (*AvroCodec, error) {
+	s, err := avro.Parse(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &AvroCodec{contentType: contentType, schema: s}, nil
+}
+
+func (c *AvroCodec) ContentType() string {
+	return c.contentType
+}
+
+func (c *AvroCodec) Marshal(v interface{}) ([]byte, error) {
+	return avro.Marshal(c.schema, v)
+}
+
+func (c *AvroCodec) Unmarshal(data []byte, v interface{}) error {
+	return avro.Unmarshal(c.schema, data, v)
+}
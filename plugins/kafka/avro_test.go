@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"testing"
+)
+
+type avroOrder struct {
+	ID     string `avro:"id"`
+	Amount int64  `avro:"amount"`
+}
+
+const avroOrderSchema = `{
+	"type": "record",
+	"name": "order",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "amount", "type": "long"}
+	]
+}`
+
+func TestAvroCodecRoundTrip(t *testing.T) {
+	c, err := NewAvroCodec("application/avro; schema=order.test", avroOrderSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterCodec(c)
+
+	m, err := NewEnvelopeMessage("o-1", NewMeta("order", "1"), avroOrder{ID: "o-1", Amount: 42}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := DecodeEnvelope[avroOrder](m, JSONCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Payload != (avroOrder{ID: "o-1", Amount: 42}) {
+		t.Fatalf("got payload %+v", e.Payload)
+	}
+	if e.Meta.Name != "order" || e.Meta.Version != "1" {
+		t.Fatalf("got meta %+v", e.Meta)
+	}
+}
+
+func TestAvroCodecInvalidSchema(t *testing.T) {
+	if _, err := NewAvroCodec("application/avro", `{"type": "record"}`); err == nil {
+		t.Fatal("invalid schema accepted")
+	}
+}
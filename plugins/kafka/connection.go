@@ -0,0 +1,234 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+type (
+	// ProducerConfig applies to all writers of the plugin. kafka-go has no
+	// idempotent producer, so retried writes can be duplicated even with
+	// RequiredAcks all.
+	ProducerConfig struct {
+		// RequiredAcks is none, one (the leader) or all (all in-sync
+		// replicas).
+		RequiredAcks string `env:"KAFKA_PRODUCER_REQUIRED_ACKS" default:"all" yaml:"requiredAcks" validate:"oneof=none one all"`
+		Compression  string `env:"KAFKA_PRODUCER_COMPRESSION" default:"none" yaml:"compression" validate:"oneof=none gzip snappy lz4 zstd"`
+		// A batch is written once it has BatchSize messages, BatchBytes
+		// bytes or is BatchTimeout old.
+		BatchSize    int           `env:"KAFKA_PRODUCER_BATCH_SIZE" default:"100" yaml:"batchSize"`
+		BatchBytes   int64         `env:"KAFKA_PRODUCER_BATCH_BYTES" default:"1048576" yaml:"batchBytes"`
+		BatchTimeout time.Duration `env:"KAFKA_PRODUCER_BATCH_TIMEOUT" default:"1s" yaml:"batchTimeout"`
+		MaxAttempts  int           `env:"KAFKA_PRODUCER_MAX_ATTEMPTS" default:"10" yaml:"maxAttempts"`
+		WriteTimeout time.Duration `env:"KAFKA_PRODUCER_WRITE_TIMEOUT" default:"10s" yaml:"writeTimeout"`
+		// Balancer picks the partition of a message. hash and murmur2 keep
+		// messages with the same key in one partition, murmur2 the same as
+		// the java client. least-bytes and round-robin ignore the key.
+		Balancer string `env:"KAFKA_PRODUCER_BALANCER" default:"least-bytes" yaml:"balancer" validate:"oneof=least-bytes round-robin hash murmur2"`
+	}
+
+	TLSConfig struct {
+		Enable bool `env:"KAFKA_TLS_ENABLE" default:"false" yaml:"enable"`
+		// CAFile is added to the system roots if set. CertFile and KeyFile
+		// enable client certificates. KeyFile is hidden in config dumps as
+		// it locates the private key.
+		CAFile             string `env:"KAFKA_TLS_CA_FILE" yaml:"caFile"`
+		CertFile           string `env:"KAFKA_TLS_CERT_FILE" yaml:"certFile"`
+		KeyFile            string `env:"KAFKA_TLS_KEY_FILE" yaml:"keyFile" secret:"true"`
+		ServerName         string `env:"KAFKA_TLS_SERVER_NAME" yaml:"serverName"`
+		InsecureSkipVerify bool   `env:"KAFKA_TLS_INSECURE_SKIP_VERIFY" default:"false" yaml:"insecureSkipVerify"`
+	}
+
+	SASLConfig struct {
+		Mechanism string `env:"KAFKA_SASL_MECHANISM" default:"none" yaml:"mechanism" validate:"oneof=none plain scram-sha-256 scram-sha-512"`
+		Username  string `env:"KAFKA_SASL_USERNAME" yaml:"username"`
+		Password  string `env:"KAFKA_SASL_PASSWORD" yaml:"password" secret:"true"`
+	}
+
+	// producer is the resolved ProducerConfig.
+	producer struct {
+		conf        ProducerConfig
+		acks        kafka.RequiredAcks
+		compression kafka.Compression
+		balancer    func() kafka.Balancer
+	}
+)
+
+var (
+	ErrInvalidProducerConfig = errors.New("invalid kafka producer config")
+	ErrInvalidTLSConfig      = errors.New("invalid kafka tls config")
+	ErrInvalidSASLConfig     = errors.New("invalid kafka sasl config")
+)
+
+// Brokers splits the comma separated broker list of conf.
+func Brokers(conf Config) []string {
+	var brokers []string
+	for _, b := range strings.Split(conf.Brokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
+}
+
+// NewDialer returns a dialer for readers and connections with the TLS and
+// SASL settings of conf.
+func NewDialer(conf Config) (*kafka.Dialer, error) {
+	tlsConf, mechanism, err := security(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Dialer{
+		Timeout:       kafka.DefaultDialer.Timeout,
+		DualStack:     kafka.DefaultDialer.DualStack,
+		TLS:           tlsConf,
+		SASLMechanism: mechanism,
+	}, nil
+}
+
+// NewTransport returns a transport for writers and clients with the TLS and
+// SASL settings of conf.
+func NewTransport(conf Config) (*kafka.Transport, error) {
+	tlsConf, mechanism, err := security(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Transport{
+		TLS:  tlsConf,
+		SASL: mechanism,
+	}, nil
+}
+
+func security(conf Config) (*tls.Config, sasl.Mechanism, error) {
+	tlsConf, err := newTLSConfig(conf.TLS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidTLSConfig, err)
+	}
+	mechanism, err := newSASLMechanism(conf.SASL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidSASLConfig, err)
+	}
+	return tlsConf, mechanism, nil
+}
+
+func newTLSConfig(conf TLSConfig) (*tls.Config, error) {
+	if !conf.Enable {
+		return nil, nil
+	}
+
+	tlsConf := &tls.Config{
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if conf.CAFile != "" {
+		ca, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates in %s", conf.CAFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConf, nil
+}
+
+func newSASLMechanism(conf SASLConfig) (sasl.Mechanism, error) {
+	switch conf.Mechanism {
+	case "", "none":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: conf.Username, Password: conf.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, conf.Username, conf.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, conf.Username, conf.Password)
+	}
+	return nil, fmt.Errorf("unknown mechanism %s", conf.Mechanism)
+}
+
+func newProducer(conf ProducerConfig) (*producer, error) {
+	p := &producer{conf: conf}
+
+	switch conf.RequiredAcks {
+	case "none":
+		p.acks = kafka.RequireNone
+	case "one":
+		p.acks = kafka.RequireOne
+	case "", "all":
+		p.acks = kafka.RequireAll
+	default:
+		return nil, fmt.Errorf("%w: required acks %s", ErrInvalidProducerConfig, conf.RequiredAcks)
+	}
+
+	switch conf.Compression {
+	case "", "none":
+	default:
+		if err := p.compression.UnmarshalText([]byte(conf.Compression)); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidProducerConfig, err)
+		}
+	}
+
+	switch conf.Balancer {
+	case "", "least-bytes":
+		p.balancer = func() kafka.Balancer { return &kafka.LeastBytes{} }
+	case "round-robin":
+		p.balancer = func() kafka.Balancer { return &kafka.RoundRobin{} }
+	case "hash":
+		p.balancer = func() kafka.Balancer { return &kafka.Hash{} }
+	case "murmur2":
+		p.balancer = func() kafka.Balancer { return kafka.Murmur2Balancer{} }
+	default:
+		return nil, fmt.Errorf("%w: balancer %s", ErrInvalidProducerConfig, conf.Balancer)
+	}
+
+	return p, nil
+}
+
+// writer returns a writer of topic. Async writers drop errors.
+func (pr *producer) writer(brokers []string, transport *kafka.Transport, topic string, async bool) *kafka.Writer {
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     pr.balancer(),
+		RequiredAcks: pr.acks,
+		Compression:  pr.compression,
+		BatchSize:    pr.conf.BatchSize,
+		BatchBytes:   pr.conf.BatchBytes,
+		BatchTimeout: pr.conf.BatchTimeout,
+		MaxAttempts:  pr.conf.MaxAttempts,
+		WriteTimeout: pr.conf.WriteTimeout,
+		Async:        async,
+	}
+	if transport != nil {
+		w.Transport = transport
+	}
+	return w
+}
@@ -0,0 +1,277 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// writeCert writes a self-signed certificate and its key to dir.
+func writeCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewProducer(t *testing.T) {
+	tests := map[string]struct {
+		conf        ProducerConfig
+		acks        kafka.RequiredAcks
+		compression kafka.Compression
+		balancer    string
+		wantErr     error
+	}{
+		"defaults": {
+			acks:     kafka.RequireAll,
+			balancer: "*kafka.LeastBytes",
+		},
+		"leader acks with gzip": {
+			conf:        ProducerConfig{RequiredAcks: "one", Compression: "gzip", Balancer: "round-robin"},
+			acks:        kafka.RequireOne,
+			compression: kafka.Gzip,
+			balancer:    "*kafka.RoundRobin",
+		},
+		"no acks with zstd": {
+			conf:        ProducerConfig{RequiredAcks: "none", Compression: "zstd", Balancer: "hash"},
+			acks:        kafka.RequireNone,
+			compression: kafka.Zstd,
+			balancer:    "*kafka.Hash",
+		},
+		"murmur2": {
+			conf:     ProducerConfig{RequiredAcks: "all", Compression: "none", Balancer: "murmur2"},
+			acks:     kafka.RequireAll,
+			balancer: "kafka.Murmur2Balancer",
+		},
+		"unknown acks": {
+			conf:    ProducerConfig{RequiredAcks: "two"},
+			wantErr: ErrInvalidProducerConfig,
+		},
+		"unknown compression": {
+			conf:    ProducerConfig{Compression: "brotli"},
+			wantErr: ErrInvalidProducerConfig,
+		},
+		"unknown balancer": {
+			conf:    ProducerConfig{Balancer: "random"},
+			wantErr: ErrInvalidProducerConfig,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := newProducer(test.conf)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("got error %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := p.writer([]string{"localhost:9092"}, nil, "orders", false)
+			if w.RequiredAcks != test.acks || w.Compression != test.compression {
+				t.Fatalf("got acks %s compression %s, want %s %s", w.RequiredAcks, w.Compression, test.acks, test.compression)
+			}
+			if got := fmt.Sprintf("%T", w.Balancer); got != test.balancer {
+				t.Fatalf("got balancer %s, want %s", got, test.balancer)
+			}
+		})
+	}
+}
+
+func TestProducerWriter(t *testing.T) {
+	p, err := newProducer(ProducerConfig{BatchSize: 5, BatchTimeout: time.Millisecond, MaxAttempts: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &kafka.Transport{}
+
+	w := p.writer([]string{"a:9092", "b:9092"}, transport, "orders", true)
+	if w.Topic != "orders" || !w.Async || w.Transport != transport || w.Addr.String() != "a:9092,b:9092" {
+		t.Fatalf("got writer of %s at %s, async %t", w.Topic, w.Addr, w.Async)
+	}
+	if w.BatchSize != 5 || w.BatchTimeout != time.Millisecond || w.MaxAttempts != 3 {
+		t.Fatalf("got batch size %d timeout %s attempts %d", w.BatchSize, w.BatchTimeout, w.MaxAttempts)
+	}
+
+	// writers must not share a balancer
+	if other := p.writer(nil, nil, "orders", false); other.Balancer == w.Balancer {
+		t.Fatal("writers share a balancer")
+	}
+}
+
+func TestNewSASLMechanism(t *testing.T) {
+	tests := map[string]struct {
+		conf    SASLConfig
+		want    string
+		wantErr bool
+	}{
+		"empty":         {},
+		"none":          {conf: SASLConfig{Mechanism: "none", Username: "u"}},
+		"plain":         {conf: SASLConfig{Mechanism: "plain", Username: "u", Password: "p"}, want: "PLAIN"},
+		"scram-sha-256": {conf: SASLConfig{Mechanism: "scram-sha-256", Username: "u", Password: "p"}, want: "SCRAM-SHA-256"},
+		"scram-sha-512": {conf: SASLConfig{Mechanism: "scram-sha-512", Username: "u", Password: "p"}, want: "SCRAM-SHA-512"},
+		"unknown":       {conf: SASLConfig{Mechanism: "gssapi"}, wantErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := newSASLMechanism(test.conf)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %t", err, test.wantErr)
+			}
+			got := ""
+			if m != nil {
+				got = m.Name()
+			}
+			if got != test.want {
+				t.Fatalf("got mechanism %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+	noCerts := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(noCerts, []byte("no certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		conf     TLSConfig
+		disabled bool
+		wantErr  bool
+		check    func(t *testing.T, conf TLSConfig)
+	}{
+		"disabled": {
+			conf:     TLSConfig{CAFile: "missing.pem"},
+			disabled: true,
+		},
+		"system roots": {
+			conf: TLSConfig{Enable: true, ServerName: "kafka", InsecureSkipVerify: true},
+		},
+		"ca file": {
+			conf: TLSConfig{Enable: true, CAFile: certFile},
+		},
+		"client certificate": {
+			conf: TLSConfig{Enable: true, CertFile: certFile, KeyFile: keyFile},
+		},
+		"missing ca file": {
+			conf:    TLSConfig{Enable: true, CAFile: filepath.Join(dir, "missing.pem")},
+			wantErr: true,
+		},
+		"ca file without certificates": {
+			conf:    TLSConfig{Enable: true, CAFile: noCerts},
+			wantErr: true,
+		},
+		"certificate without key": {
+			conf:    TLSConfig{Enable: true, CertFile: certFile},
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			conf, err := newTLSConfig(test.conf)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %t", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if test.disabled {
+				if conf != nil {
+					t.Fatal("got tls config for disabled tls")
+				}
+				return
+			}
+
+			if conf.ServerName != test.conf.ServerName || conf.InsecureSkipVerify != test.conf.InsecureSkipVerify {
+				t.Fatalf("got server name %q, insecure %t", conf.ServerName, conf.InsecureSkipVerify)
+			}
+			if (conf.RootCAs != nil) != (test.conf.CAFile != "") {
+				t.Fatalf("got root cas %v for ca file %q", conf.RootCAs, test.conf.CAFile)
+			}
+			if len(conf.Certificates) > 0 != (test.conf.CertFile != "") {
+				t.Fatalf("got %d client certificates", len(conf.Certificates))
+			}
+		})
+	}
+}
+
+func TestSecurityErrors(t *testing.T) {
+	tests := map[string]struct {
+		conf Config
+		want error
+	}{
+		"tls": {
+			conf: Config{TLS: TLSConfig{Enable: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+			want: ErrInvalidTLSConfig,
+		},
+		"sasl": {
+			conf: Config{SASL: SASLConfig{Mechanism: "gssapi"}},
+			want: ErrInvalidSASLConfig,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewDialer(test.conf); !errors.Is(err, test.want) {
+				t.Fatalf("dialer: got error %v, want %v", err, test.want)
+			}
+			if _, err := NewTransport(test.conf); !errors.Is(err, test.want) {
+				t.Fatalf("transport: got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestBrokers(t *testing.T) {
+	got := Brokers(Config{Brokers: " a:9092, ,b:9092 ,"})
+	if want := "[a:9092 b:9092]"; fmt.Sprint(got) != want {
+		t.Fatalf("got brokers %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,426 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// Handler processes a consumed message. The offset is committed only
+	// after Handler returned nil, so messages are delivered at least once.
+	Handler func(ctx context.Context, m Message) error
+
+	ConsumerConfig struct {
+		// Offsets are committed after CommitBatchSize handled messages or
+		// after CommitInterval, whichever comes first.
+		CommitBatchSize int           `env:"KAFKA_CONSUMER_COMMIT_BATCH_SIZE" default:"100" yaml:"commitBatchSize"`
+		CommitInterval  time.Duration `env:"KAFKA_CONSUMER_COMMIT_INTERVAL" default:"1s" yaml:"commitInterval"`
+		// MaxRetries is the number of retries of a failing message. 0
+		// retries until the handler succeeds or the context is done.
+		MaxRetries int           `env:"KAFKA_CONSUMER_MAX_RETRIES" default:"0" yaml:"maxRetries"`
+		MinBackoff time.Duration `env:"KAFKA_CONSUMER_MIN_BACKOFF" default:"100ms" yaml:"minBackoff"`
+		MaxBackoff time.Duration `env:"KAFKA_CONSUMER_MAX_BACKOFF" default:"30s" yaml:"maxBackoff"`
+		// RetryTopics are the delays of the retry topics. A message that
+		// failed its in-process retries is moved to <topic>.retry.<delay>
+		// of the next delay, e.g. orders.retry.1m and orders.retry.10m, and
+		// handled again after the delay. Needs MaxRetries > 0.
+		RetryTopics []time.Duration `env:"KAFKA_CONSUMER_RETRY_TOPICS" envSeparator:"," yaml:"retryTopics"`
+		// DeadLetter moves messages that failed all retries to
+		// <topic><DeadLetterSuffix> instead of stopping the consumer. Needs
+		// MaxRetries > 0.
+		DeadLetter       bool   `env:"KAFKA_CONSUMER_DEAD_LETTER" default:"false" yaml:"deadLetter"`
+		DeadLetterSuffix string `env:"KAFKA_CONSUMER_DEAD_LETTER_SUFFIX" default:".dlq" yaml:"deadLetterSuffix"`
+	}
+
+	// RebalanceHooks are called when the partitions of a consumer change.
+	RebalanceHooks struct {
+		// OnAssigned is called before the partitions of a new generation are
+		// read. Changing the offset of a partition seeks it, e.g. to replay
+		// it. An error stops the consumer.
+		OnAssigned func(ctx context.Context, a *Assignment) error
+		// OnRevoked is called after the offsets of the generation were
+		// committed and before the partitions are handed over. The offsets
+		// are the committed ones.
+		OnRevoked func(ctx context.Context, a Assignment)
+	}
+
+	// Assignment are the partitions of topic a consumer owns during a
+	// generation of its group.
+	Assignment struct {
+		Topic      string
+		Group      string
+		Generation int32
+		Partitions []PartitionOffset
+	}
+
+	// PartitionOffset is a partition and the offset of the next message to
+	// read. It can be kafka.FirstOffset or kafka.LastOffset if the group has
+	// not committed an offset.
+	PartitionOffset struct {
+		Partition int
+		Offset    int64
+	}
+
+	// committer batches the offsets of handled messages.
+	committer struct {
+		l         *logrus.Entry
+		gen       *kafka.Generation
+		topic     string
+		conf      ConsumerConfig
+		mutex     sync.Mutex
+		count     int
+		pending   map[int]int64
+		committed map[int]int64
+	}
+)
+
+var (
+	ErrNotEnabled    = errors.New("kafka is not enabled")
+	ErrHandlerFailed = errors.New("handler failed")
+)
+
+// Consume reads topic as consumerGroupID and passes every message to h until
+// ctx is done. Offsets are committed after h succeeded, see ConsumerConfig.
+// A failing message is retried with backoff and blocks the consumer. If the
+// retries are exhausted it is moved to the next retry topic or the
+// dead-letter topic. Without those Consume returns ErrHandlerFailed without
+// committing the message, so it is consumed again after a restart.
+//
+// Connection errors are retried with the backoff of ReconnectConfig, only
+// errors IsFatal reports stop the consumer.
+func (p *Plugin) Consume(ctx context.Context, topic string, consumerGroupID string, h Handler) error {
+	return p.ConsumeWithHooks(ctx, topic, consumerGroupID, p.conf.Consumer, RebalanceHooks{}, h)
+}
+
+// ConsumeWithConfig is Consume with a consumer config other than the one of
+// the plugin.
+func (p *Plugin) ConsumeWithConfig(ctx context.Context, topic string, consumerGroupID string, conf ConsumerConfig, h Handler) error {
+	return p.ConsumeWithHooks(ctx, topic, consumerGroupID, conf, RebalanceHooks{}, h)
+}
+
+// ConsumeWithHooks is ConsumeWithConfig calling hooks when partitions are
+// assigned or revoked. The hooks are called for the retry topics as well.
+func (p *Plugin) ConsumeWithHooks(ctx context.Context, topic string, consumerGroupID string, conf ConsumerConfig, hooks RebalanceHooks, h Handler) error {
+	l := p.logger.WithField("component", "kafka-consumer").WithField("topic", topic).WithField("group", consumerGroupID)
+
+	if !p.conf.Enable {
+		return ErrNotEnabled
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the retry topics are consumed next to the topic, the first error
+	// stops all of them
+	errC := make(chan error, len(conf.RetryTopics)+1)
+	for stage := 0; stage <= len(conf.RetryTopics); stage++ {
+		go func(stage int) {
+			err := p.consumeStage(ctx, l, topic, consumerGroupID, conf, hooks, stage, h)
+			cancel()
+			errC <- err
+		}(stage)
+	}
+
+	var errs []error
+	for stage := 0; stage <= len(conf.RetryTopics); stage++ {
+		if err := <-errC; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// consumeStage consumes topic if stage is 0 or its retry topic stage
+// otherwise. Every generation of the group is consumed by consumeGeneration.
+func (p *Plugin) consumeStage(ctx context.Context, l *logrus.Entry, topic string, consumerGroupID string, conf ConsumerConfig, hooks RebalanceHooks, stage int, h Handler) error {
+	stageTopic := topic
+	if stage > 0 {
+		stageTopic = RetryTopic(topic, conf.RetryTopics[stage-1])
+		l = l.WithField("retry-topic", stageTopic)
+	}
+
+	cg, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:                    consumerGroupID,
+		Brokers:               p.brokers(),
+		Dialer:                p.dialer,
+		Topics:                []string{stageTopic},
+		StartOffset:           kafka.FirstOffset,
+		WatchPartitionChanges: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer cg.Close()
+
+	l.Debug("starting consumer")
+
+	for {
+		// the group backs off itself after failing to join
+		gen, err := cg.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, kafka.ErrGroupClosed) {
+				l.Trace("consumer stopped")
+				return nil
+			}
+			if IsFatal(err) {
+				l.WithError(err).Error("could not join consumer group")
+				return err
+			}
+			l.WithError(err).Warn("could not join consumer group. retrying")
+			continue
+		}
+
+		if err := p.consumeGeneration(ctx, l, gen, topic, stageTopic, conf, hooks, stage, h); err != nil {
+			return err
+		}
+	}
+}
+
+// consumeGeneration reads the partitions assigned in gen and handles their
+// messages one at a time until ctx is done or the generation ends. Offsets
+// are committed before the generation is left.
+func (p *Plugin) consumeGeneration(ctx context.Context, l *logrus.Entry, gen *kafka.Generation, topic string, stageTopic string, conf ConsumerConfig, hooks RebalanceHooks, stage int, h Handler) error {
+	a := &Assignment{Topic: stageTopic, Group: gen.GroupID, Generation: gen.ID}
+	for _, pa := range gen.Assignments[stageTopic] {
+		a.Partitions = append(a.Partitions, PartitionOffset{Partition: pa.ID, Offset: pa.Offset})
+	}
+	l = l.WithField("generation", gen.ID)
+	l.WithField("partitions", a.Partitions).Debug("partitions assigned")
+
+	if hooks.OnAssigned != nil {
+		if err := hooks.OnAssigned(ctx, a); err != nil {
+			return err
+		}
+	}
+
+	// ctx of the generation, cancelled when kafka ends it
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := &committer{l: l, gen: gen, topic: stageTopic, conf: conf, pending: map[int]int64{}, committed: map[int]int64{}}
+	commitsDone := make(chan struct{})
+	flushed := make(chan struct{})
+	defer func() {
+		cancel()
+		<-commitsDone
+		if err := c.commit(); err != nil {
+			l.WithError(err).Error("could not commit offsets")
+		}
+		if hooks.OnRevoked != nil {
+			hooks.OnRevoked(context.Background(), c.revoked(*a))
+		}
+		l.Debug("partitions revoked")
+		close(flushed)
+	}()
+
+	// the group waits for this before joining the next generation, so
+	// offsets are committed while the partitions are still owned
+	gen.Start(func(genCtx context.Context) {
+		select {
+		case <-genCtx.Done():
+		case <-ctx.Done():
+		}
+		cancel()
+		<-flushed
+	})
+	go func() {
+		defer close(commitsDone)
+		c.run(ctx)
+	}()
+
+	messageC := make(chan kafka.Message)
+	errC := make(chan error, len(a.Partitions))
+	for _, pa := range a.Partitions {
+		pa := pa
+		gen.Start(func(context.Context) {
+			p.readPartition(ctx, l, stageTopic, gen.GroupID, pa.Partition, pa.Offset, messageC, errC)
+		})
+	}
+
+	var delay time.Duration
+	if stage > 0 {
+		delay = conf.RetryTopics[stage-1]
+	}
+
+	for {
+		var m kafka.Message
+		select {
+		case <-ctx.Done():
+			// a fatal reader error ends the generation as well
+			select {
+			case err := <-errC:
+				return err
+			default:
+				return nil
+			}
+		case err := <-errC:
+			return err
+		case m = <-messageC:
+		}
+
+		// retry topics are ordered by time, so waiting for the first
+		// message of a partition delays all later ones as well
+		if wait := time.Until(m.Time.Add(delay)); delay > 0 && wait > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+		}
+
+		attempts, err := p.handle(ctx, l, conf, h, m)
+		if err != nil {
+			// the message is not committed and handled again by the next
+			// owner of the partition
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := p.forward(ctx, l, topic, conf, stage, m, attempts, err); err != nil {
+				return err
+			}
+		}
+
+		if err := c.add(m); err != nil {
+			l.WithError(err).Error("could not commit offsets")
+		}
+	}
+}
+
+// handle calls h until it succeeds or the retries are exhausted and returns
+// the number of calls.
+func (p *Plugin) handle(ctx context.Context, l *logrus.Entry, conf ConsumerConfig, h Handler, km kafka.Message) (int, error) {
+	m := message(km)
+	l = l.WithFields(logrus.Fields{"partition": km.Partition, "offset": km.Offset})
+
+	b := newBackoff(conf.MinBackoff, conf.MaxBackoff)
+	for attempt := 1; ; attempt++ {
+		err := h(ctx, m)
+		if err == nil {
+			p.metrics.AddCounter("kafka_"+km.Topic+"_success", 1.0, fmt.Sprintf("kafka [%s] successfull message count", km.Topic))
+			return attempt, nil
+		}
+
+		p.metrics.AddCounter("kafka_"+km.Topic+"_failure", 1.0, fmt.Sprintf("kafka [%s] failure message count", km.Topic))
+		if conf.MaxRetries > 0 && attempt > conf.MaxRetries {
+			l.WithError(err).Error("giving up on message")
+			return attempt, err
+		}
+
+		l.WithError(err).WithField("attempt", attempt).WithField("backoff", b.current()).Warn("handler failed. retrying")
+		if !b.wait(ctx) {
+			return attempt, ctx.Err()
+		}
+	}
+}
+
+// add marks m handled and commits if the batch is full.
+func (c *committer) add(m kafka.Message) error {
+	c.mutex.Lock()
+	c.pending[m.Partition] = m.Offset + 1
+	c.count++
+	isFull := c.count >= c.conf.CommitBatchSize
+	c.mutex.Unlock()
+
+	if !isFull {
+		return nil
+	}
+	return c.commit()
+}
+
+// run commits every CommitInterval until ctx is done.
+func (c *committer) run(ctx context.Context) {
+	if c.conf.CommitInterval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(c.conf.CommitInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := c.commit(); err != nil && ctx.Err() == nil {
+				c.l.WithError(err).Error("could not commit offsets")
+			}
+		}
+	}
+}
+
+// commit commits the pending offsets. They stay pending if the commit fails.
+func (c *committer) commit() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.pending) == 0 {
+		return nil
+	}
+	if err := c.gen.CommitOffsets(map[string]map[int]int64{c.topic: c.pending}); err != nil {
+		return err
+	}
+
+	c.l.WithField("count", c.count).Trace("offsets committed")
+	for partition, offset := range c.pending {
+		c.committed[partition] = offset
+	}
+	c.pending = map[int]int64{}
+	c.count = 0
+	return nil
+}
+
+// revoked returns a with the committed offsets.
+func (c *committer) revoked(a Assignment) Assignment {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	partitions := make([]PartitionOffset, 0, len(a.Partitions))
+	for _, pa := range a.Partitions {
+		if offset, isOk := c.committed[pa.Partition]; isOk {
+			pa.Offset = offset
+		}
+		partitions = append(partitions, pa)
+	}
+	a.Partitions = partitions
+	return a
+}
+
+func message(m kafka.Message) Message {
+	headers := make([]Header, 0, len(m.Headers))
+	for _, h := range m.Headers {
+		headers = append(headers, Header{Key: h.Key, Value: h.Value})
+	}
+
+	return Message{
+		Topic:         m.Topic,
+		Partition:     m.Partition,
+		Offset:        m.Offset,
+		HighWaterMark: m.HighWaterMark,
+		Key:           m.Key,
+		Value:         m.Value,
+		Headers:       headers,
+		Time:          m.Time,
+	}
+}
+
+// kafkaMessage converts m for writing. Topic, partition and offsets are set
+// by the writer.
+func kafkaMessage(m Message) kafka.Message {
+	headers := make([]kafka.Header, 0, len(m.Headers))
+	for _, h := range m.Headers {
+		headers = append(headers, kafka.Header{Key: h.Key, Value: h.Value})
+	}
+
+	return kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+		Time:    m.Time,
+	}
+}
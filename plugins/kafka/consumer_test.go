@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHandleRetries(t *testing.T) {
+	p := &Plugin{metrics: &nullMetricsWriter{}}
+	l := logrus.WithField("test", t.Name())
+	conf := ConsumerConfig{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	errHandler := errors.New("handler failed")
+
+	failing := func(n int) Handler {
+		return func(ctx context.Context, m Message) error {
+			if n--; n >= 0 {
+				return errHandler
+			}
+			return nil
+		}
+	}
+
+	attempts, err := p.handle(context.Background(), l, conf, failing(2), kafka.Message{})
+	if err != nil || attempts != 3 {
+		t.Fatalf("got %d attempts, error %v", attempts, err)
+	}
+
+	conf.MaxRetries = 1
+	attempts, err = p.handle(context.Background(), l, conf, failing(5), kafka.Message{})
+	if !errors.Is(err, errHandler) || attempts != 2 {
+		t.Fatalf("got %d attempts, error %v with max retries", attempts, err)
+	}
+}
+
+func TestCommitterRevoked(t *testing.T) {
+	c := &committer{
+		conf:      ConsumerConfig{CommitBatchSize: 10},
+		pending:   map[int]int64{},
+		committed: map[int]int64{0: 5},
+	}
+
+	// below the batch size nothing is committed
+	if err := c.add(kafka.Message{Partition: 1, Offset: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if c.pending[1] != 8 {
+		t.Fatalf("got pending %v", c.pending)
+	}
+
+	a := c.revoked(Assignment{Topic: "orders", Partitions: []PartitionOffset{{0, 1}, {1, kafka.FirstOffset}}})
+	if want := fmt.Sprint([]PartitionOffset{{0, 5}, {1, kafka.FirstOffset}}); fmt.Sprint(a.Partitions) != want {
+		t.Fatalf("got partitions %v, want %s", a.Partitions, want)
+	}
+}
+
+func TestMessageConversion(t *testing.T) {
+	km := kafkaMessage(Message{
+		Key:     []byte("k"),
+		Value:   []byte("v"),
+		Headers: []Header{{Key: "a", Value: []byte("1")}},
+	})
+
+	m := message(km)
+	if string(m.Key) != "k" || string(m.Value) != "v" || m.Header("a") != "1" {
+		t.Fatalf("got %+v", m)
+	}
+}
@@ -0,0 +1,210 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/siklol/zinc/plugins"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers of messages moved to retry and dead-letter topics.
+const (
+	HeaderOriginTopic     = "x-origin-topic"
+	HeaderOriginPartition = "x-origin-partition"
+	HeaderOriginOffset    = "x-origin-offset"
+	HeaderAttempts        = "x-attempts"
+	HeaderError           = "x-error"
+)
+
+// DefaultReplayIdle ends a dead-letter replay if no message arrived for this
+// long.
+const DefaultReplayIdle = 10 * time.Second
+
+var (
+	ErrNoOriginTopic = errors.New("message has no origin topic")
+)
+
+// RetryTopic returns the name of the retry topic of topic with delay, e.g.
+// orders.retry.10m.
+func RetryTopic(topic string, delay time.Duration) string {
+	return topic + ".retry." + shortDuration(delay)
+}
+
+// forward moves a message whose handler failed to the next retry topic or the
+// dead-letter topic. Without either it returns ErrHandlerFailed.
+func (p *Plugin) forward(ctx context.Context, l *logrus.Entry, topic string, conf ConsumerConfig, stage int, m kafka.Message, attempts int, cause error) error {
+	var next string
+	switch {
+	case stage < len(conf.RetryTopics):
+		next = RetryTopic(topic, conf.RetryTopics[stage])
+	case conf.DeadLetter:
+		next = topic + conf.DeadLetterSuffix
+	default:
+		return fmt.Errorf("%w: %s/%d/%d: %w", ErrHandlerFailed, m.Topic, m.Partition, m.Offset, cause)
+	}
+
+	// the origin is the message as it was first consumed
+	headers := map[string]string{
+		HeaderOriginTopic:     m.Topic,
+		HeaderOriginPartition: strconv.Itoa(m.Partition),
+		HeaderOriginOffset:    strconv.FormatInt(m.Offset, 10),
+	}
+	if stage > 0 {
+		for _, k := range []string{HeaderOriginTopic, HeaderOriginPartition, HeaderOriginOffset} {
+			if v := header(m.Headers, k); v != "" {
+				headers[k] = v
+			}
+		}
+		if previous, err := strconv.Atoi(header(m.Headers, HeaderAttempts)); err == nil {
+			attempts += previous
+		}
+	}
+	headers[HeaderAttempts] = strconv.Itoa(attempts)
+	headers[HeaderError] = cause.Error()
+
+	out := kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: withHeaders(m.Headers, headers),
+	}
+	if err := p.writer(next).WriteMessages(ctx, out); err != nil {
+		l.WithError(err).WithField("to", next).Error("could not forward failed message")
+		return err
+	}
+
+	l.WithFields(logrus.Fields{"to": next, "attempts": attempts, "offset": m.Offset}).Warn("failed message forwarded")
+	return nil
+}
+
+// ReplayDeadLetters writes the messages of a dead-letter topic back to their
+// origin topics without the retry headers. The replay consumes topic as
+// group <topic>-replay, so every message is replayed once. It returns after
+// no message arrived for idle.
+func (p *Plugin) ReplayDeadLetters(ctx context.Context, topic string, idle time.Duration) (int, error) {
+	l := p.logger.WithField("component", "kafka-dlq-replay").WithField("topic", topic)
+
+	if !p.conf.Enable {
+		return 0, ErrNotEnabled
+	}
+	if idle <= 0 {
+		idle = DefaultReplayIdle
+	}
+
+	kr := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     p.brokers(),
+		Dialer:      p.dialer,
+		GroupID:     topic + "-replay",
+		Topic:       topic,
+		StartOffset: kafka.FirstOffset,
+	})
+	defer kr.Close()
+
+	replayed := 0
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, idle)
+		m, err := kr.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if (errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil) || errors.Is(err, io.EOF) {
+				l.WithField("replayed", replayed).Info("dead letters replayed")
+				return replayed, nil
+			}
+			return replayed, err
+		}
+
+		origin := header(m.Headers, HeaderOriginTopic)
+		if origin == "" {
+			return replayed, fmt.Errorf("%w: offset %d", ErrNoOriginTopic, m.Offset)
+		}
+
+		out := kafka.Message{
+			Key:     m.Key,
+			Value:   m.Value,
+			Headers: withoutHeaders(m.Headers, HeaderOriginTopic, HeaderOriginPartition, HeaderOriginOffset, HeaderAttempts, HeaderError),
+		}
+		if err := p.writer(origin).WriteMessages(ctx, out); err != nil {
+			return replayed, err
+		}
+		if err := kr.CommitMessages(ctx, m); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+}
+
+// CLICommands adds
+//
+//	kafka-replay-dlq <dead-letter topic>
+func (p *Plugin) CLICommands() map[string]plugins.CLICommand {
+	return map[string]plugins.CLICommand{
+		"kafka-replay-dlq": func(args []string) error {
+			if len(args) == 0 {
+				return errors.New("missing argument: dead-letter topic")
+			}
+			_, err := p.ReplayDeadLetters(context.Background(), args[0], DefaultReplayIdle)
+			return err
+		},
+	}
+}
+
+func header(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// withHeaders returns headers with the values of set replaced or added.
+func withHeaders(headers []kafka.Header, set map[string]string) []kafka.Header {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := withoutHeaders(headers, keys...)
+	for _, k := range keys {
+		out = append(out, kafka.Header{Key: k, Value: []byte(set[k])})
+	}
+	return out
+}
+
+func withoutHeaders(headers []kafka.Header, keys ...string) []kafka.Header {
+	out := make([]kafka.Header, 0, len(headers))
+	for _, h := range headers {
+		isDropped := false
+		for _, k := range keys {
+			if h.Key == k {
+				isDropped = true
+				break
+			}
+		}
+		if !isDropped {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// shortDuration formats d in its largest whole unit, e.g. 10m instead of
+// 10m0s.
+func shortDuration(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d >= time.Minute && d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	case d >= time.Second && d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	}
+	return d.String()
+}
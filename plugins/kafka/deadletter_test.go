@@ -0,0 +1,61 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRetryTopic(t *testing.T) {
+	for delay, want := range map[time.Duration]string{
+		10 * time.Second:        "orders.retry.10s",
+		10 * time.Minute:        "orders.retry.10m",
+		2 * time.Hour:           "orders.retry.2h",
+		90 * time.Second:        "orders.retry.90s",
+		1500 * time.Millisecond: "orders.retry.1.5s",
+	} {
+		if got := RetryTopic("orders", delay); got != want {
+			t.Errorf("got %s for %s, want %s", got, delay, want)
+		}
+	}
+}
+
+func TestForwardWithoutTarget(t *testing.T) {
+	p := &Plugin{}
+	errCause := errors.New("cause")
+
+	err := p.forward(context.Background(), logrus.WithField("test", t.Name()), "orders", ConsumerConfig{}, 0, kafka.Message{Topic: "orders"}, 3, errCause)
+	if !errors.Is(err, ErrHandlerFailed) || !errors.Is(err, errCause) {
+		t.Fatalf("got error %v", err)
+	}
+}
+
+func TestWithHeaders(t *testing.T) {
+	headers := []kafka.Header{
+		{Key: "keep", Value: []byte("1")},
+		{Key: HeaderAttempts, Value: []byte("1")},
+	}
+
+	got := withHeaders(headers, map[string]string{HeaderError: "failed", HeaderAttempts: "2"})
+	if want := "[keep=1 x-attempts=2 x-error=failed]"; headerString(got) != want {
+		t.Fatalf("got %s, want %s", headerString(got), want)
+	}
+
+	got = withoutHeaders(got, HeaderAttempts, HeaderError)
+	if want := "[keep=1]"; headerString(got) != want {
+		t.Fatalf("got %s, want %s", headerString(got), want)
+	}
+}
+
+func headerString(headers []kafka.Header) string {
+	var s []string
+	for _, h := range headers {
+		s = append(s, h.Key+"="+string(h.Value))
+	}
+	return fmt.Sprint(s)
+}
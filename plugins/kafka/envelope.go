@@ -0,0 +1,296 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+type (
+	// Codec encodes envelope payloads. ContentType is written to the
+	// content-type header and selects the codec when decoding, so it must be
+	// unique among the registered codecs.
+	//
+	// Json and protobuf are built in. Avro needs a schema, see AvroCodec.
+	// Other formats are added by registering a Codec with RegisterCodec.
+	Codec interface {
+		ContentType() string
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+	}
+
+	JSONCodec struct{}
+
+	// ProtobufCodec needs payloads implementing proto.Message.
+	ProtobufCodec struct{}
+
+	// Envelope is a decoded message with its meta data.
+	Envelope[T any] struct {
+		Meta    Meta
+		Payload T
+		Message Message
+	}
+
+	EnvelopeHandler[T any] func(ctx context.Context, e Envelope[T]) error
+
+	EnvelopeOption func(o *envelopeOptions)
+
+	envelopeOptions struct {
+		meta    *Meta
+		codec   Codec
+		headers []Header
+		conf    *ConsumerConfig
+		hooks   RebalanceHooks
+	}
+)
+
+// Headers written by Publish.
+const (
+	HeaderContentType   = "content-type"
+	HeaderMetaID        = "meta-id"
+	HeaderMetaName      = "meta-name"
+	HeaderMetaVersion   = "meta-version"
+	HeaderMetaCreatedAt = "meta-created-at"
+)
+
+var (
+	ErrUnknownContentType = errors.New("unknown content type")
+	ErrNotProtoMessage    = errors.New("payload is not a proto.Message")
+
+	codecs = map[string]Codec{}
+	codecM sync.RWMutex
+)
+
+func init() {
+	RegisterCodec(JSONCodec{})
+	RegisterCodec(ProtobufCodec{})
+}
+
+// RegisterCodec adds c to the codecs Subscribe can decode. A codec with the
+// same content type is replaced.
+func RegisterCodec(c Codec) {
+	codecM.Lock()
+	defer codecM.Unlock()
+
+	codecs[c.ContentType()] = c
+}
+
+// CodecFor returns the codec registered for contentType.
+func CodecFor(contentType string) (Codec, error) {
+	codecM.RLock()
+	defer codecM.RUnlock()
+
+	c, isOk := codecs[contentType]
+	if !isOk {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownContentType, contentType)
+	}
+	return c, nil
+}
+
+// WithMeta publishes m instead of a new meta named after the payload type.
+func WithMeta(m Meta) EnvelopeOption {
+	return func(o *envelopeOptions) {
+		o.meta = &m
+	}
+}
+
+// WithCodec encodes payloads with c instead of json. Subscribe uses c for
+// messages without content-type header.
+func WithCodec(c Codec) EnvelopeOption {
+	return func(o *envelopeOptions) {
+		o.codec = c
+	}
+}
+
+// WithHeaders adds headers to the published message.
+func WithHeaders(headers ...Header) EnvelopeOption {
+	return func(o *envelopeOptions) {
+		o.headers = append(o.headers, headers...)
+	}
+}
+
+// WithConsumerConfig makes Subscribe use conf instead of the consumer config
+// of the plugin.
+func WithConsumerConfig(conf ConsumerConfig) EnvelopeOption {
+	return func(o *envelopeOptions) {
+		o.conf = &conf
+	}
+}
+
+// WithRebalanceHooks makes Subscribe call hooks, see ConsumeWithHooks.
+func WithRebalanceHooks(hooks RebalanceHooks) EnvelopeOption {
+	return func(o *envelopeOptions) {
+		o.hooks = hooks
+	}
+}
+
+// Publish encodes payload and writes it to topic with its meta data in the
+// headers.
+func Publish[T any](ctx context.Context, p *Plugin, topic string, key string, payload T, opts ...EnvelopeOption) error {
+	o := newEnvelopeOptions(opts)
+
+	meta := NewMeta(typeName(payload), "")
+	if o.meta != nil {
+		meta = *o.meta
+	}
+
+	m, err := NewEnvelopeMessage(key, meta, payload, o.codec)
+	if err != nil {
+		return err
+	}
+	m.Headers = append(m.Headers, o.headers...)
+
+	return p.WriteMessage(ctx, topic, m)
+}
+
+// Subscribe consumes topic like Consume and decodes every message into an
+// Envelope before passing it to h. Messages that cannot be decoded fail like
+// messages h returns an error for.
+func Subscribe[T any](ctx context.Context, p *Plugin, topic string, consumerGroupID string, h EnvelopeHandler[T], opts ...EnvelopeOption) error {
+	o := newEnvelopeOptions(opts)
+
+	conf := p.conf.Consumer
+	if o.conf != nil {
+		conf = *o.conf
+	}
+
+	return p.ConsumeWithHooks(ctx, topic, consumerGroupID, conf, o.hooks, func(ctx context.Context, m Message) error {
+		e, err := DecodeEnvelope[T](m, o.codec)
+		if err != nil {
+			return err
+		}
+		return h(ctx, e)
+	})
+}
+
+// NewEnvelopeMessage encodes payload with c and stores meta in the headers.
+func NewEnvelopeMessage(key string, meta Meta, payload interface{}, c Codec) (Message, error) {
+	value, err := c.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = time.Now()
+	}
+
+	return Message{
+		Key:   []byte(key),
+		Value: value,
+		Time:  meta.CreatedAt,
+		Headers: []Header{
+			{Key: HeaderContentType, Value: []byte(c.ContentType())},
+			{Key: HeaderMetaID, Value: []byte(meta.ID)},
+			{Key: HeaderMetaName, Value: []byte(meta.Name)},
+			{Key: HeaderMetaVersion, Value: []byte(meta.Version)},
+			{Key: HeaderMetaCreatedAt, Value: []byte(meta.CreatedAt.UTC().Format(time.RFC3339Nano))},
+		},
+	}, nil
+}
+
+// DecodeEnvelope decodes m with the codec of its content-type header or
+// fallback if it has none.
+func DecodeEnvelope[T any](m Message, fallback Codec) (Envelope[T], error) {
+	e := Envelope[T]{Message: m, Meta: MetaFromHeaders(m)}
+
+	c := fallback
+	if contentType := m.Header(HeaderContentType); contentType != "" {
+		var err error
+		if c, err = CodecFor(contentType); err != nil {
+			return e, err
+		}
+	}
+
+	if err := c.Unmarshal(m.Value, &e.Payload); err != nil {
+		return e, fmt.Errorf("decoding %s: %w", c.ContentType(), err)
+	}
+	return e, nil
+}
+
+// MetaFromHeaders reads the meta data Publish wrote. Messages without
+// meta-created-at use the message time.
+func MetaFromHeaders(m Message) Meta {
+	meta := Meta{
+		ID:        m.Header(HeaderMetaID),
+		Name:      m.Header(HeaderMetaName),
+		Version:   m.Header(HeaderMetaVersion),
+		CreatedAt: m.Time,
+	}
+	if t, err := time.Parse(time.RFC3339Nano, m.Header(HeaderMetaCreatedAt)); err == nil {
+		meta.CreatedAt = t
+	}
+	return meta
+}
+
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (ProtobufCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	pm, isOk := v.(proto.Message)
+	if !isOk {
+		return nil, fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Marshal(pm)
+}
+
+// Unmarshal accepts a proto.Message or a pointer to a nil proto.Message
+// pointer, as Subscribe passes for payload types like *pb.Order.
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	if pm, isOk := v.(proto.Message); isOk {
+		return proto.Unmarshal(data, pm)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Pointer {
+		return fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	elem := reflect.New(rv.Elem().Type().Elem())
+	pm, isOk := elem.Interface().(proto.Message)
+	if !isOk {
+		return fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	if err := proto.Unmarshal(data, pm); err != nil {
+		return err
+	}
+	rv.Elem().Set(elem)
+	return nil
+}
+
+func newEnvelopeOptions(opts []EnvelopeOption) envelopeOptions {
+	o := envelopeOptions{codec: JSONCodec{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// typeName names the meta of payloads published without WithMeta.
+func typeName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	return t.Name()
+}
@@ -2,9 +2,10 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"strings"
+	"reflect"
 	"sync"
 
 	"github.com/segmentio/kafka-go"
@@ -21,11 +22,21 @@ type (
 		rMutex  *sync.RWMutex
 		wMutex  *sync.RWMutex
 		metrics MetricsWriter
+
+		dialer    *kafka.Dialer
+		transport *kafka.Transport
+		producer  *producer
+		bootErr   error
 	}
 
 	Config struct {
-		Enable  bool   `env:"KAFKA_ENABLE" default:"false" yaml:"enable"`
-		Brokers string `env:"KAFKA_BROKERS" default:"localhost:9092" yaml:"brokers"`
+		Enable    bool            `env:"KAFKA_ENABLE" default:"false" yaml:"enable"`
+		Brokers   string          `env:"KAFKA_BROKERS" default:"localhost:9092" yaml:"brokers" validate:"required"`
+		Consumer  ConsumerConfig  `yaml:"consumer"`
+		Reconnect ReconnectConfig `yaml:"reconnect"`
+		Producer  ProducerConfig  `yaml:"producer"`
+		TLS       TLSConfig       `yaml:"tls"`
+		SASL      SASLConfig      `yaml:"sasl"`
 	}
 
 	MetricsWriter interface {
@@ -38,6 +49,19 @@ type (
 
 const Name = "kafka"
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "kafka",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+			plugins.TypeOf[MetricsWriter](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{
 		krs:    map[string]*kafka.Reader{},
@@ -73,17 +97,69 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	if p.logger == nil {
 		p.logger = logrus.WithField("component", "kafka-reader")
 	}
+	if p.metrics == nil {
+		p.metrics = &nullMetricsWriter{}
+	}
 
 	if !p.conf.Enable {
 		p.logger.Debug("kafka is not enabled. nothing to init...")
 		return p
 	}
 
+	if err := p.connect(); err != nil {
+		p.logger.WithError(err).Error("kafka could not be configured. failing")
+		p.bootErr = err
+		p.conf.Enable = false
+		return p
+	}
+
 	p.logger.Debug("finished init kafka...")
 
 	return p
 }
 
+// connect prepares the dialer, transport and writer settings of the config.
+func (p *Plugin) connect() error {
+	var err error
+	if p.dialer, err = NewDialer(p.conf); err != nil {
+		return err
+	}
+	if p.transport, err = NewTransport(p.conf); err != nil {
+		return err
+	}
+	p.producer, err = newProducer(p.conf.Producer)
+	return err
+}
+
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
+func (p *Plugin) DependsOn() []string {
+	if mp, isOk := p.metrics.(plugins.Plugin); isOk {
+		return []string{mp.Name()}
+	}
+	return nil
+}
+
+// Health succeeds as soon as one of the configured brokers accepts a connection.
+func (p *Plugin) Health(ctx context.Context) error {
+	if !p.conf.Enable {
+		return nil
+	}
+
+	var err error
+	for _, broker := range p.brokers() {
+		var conn *kafka.Conn
+		if conn, err = p.dialer.DialContext(ctx, "tcp", broker); err == nil {
+			return conn.Close()
+		}
+		p.logger.WithError(err).WithField("broker", broker).Debug("kafka broker not reachable")
+	}
+
+	return err
+}
+
 func (p *Plugin) EnableMetrics(metrics MetricsWriter) {
 	p.metrics = metrics
 }
@@ -98,8 +174,15 @@ func (p *Plugin) ReadFromTopic(topic string, consumerGroupID string, messageC ch
 	p.ReadFromTopicWithContext(ctx, topic, consumerGroupID, false, messageC)
 }
 
+// ReadFromTopicWithContext commits every message as soon as it was sent to
+// messageC, so messages not yet processed are lost on a crash. Use Consume for
+// at-least-once delivery.
+//
+// Every consumer group has its own reader. After fetch errors the reader is
+// recreated with the backoff of ReconnectConfig, it only stops on errors
+// IsFatal reports.
 func (p *Plugin) ReadFromTopicWithContext(ctx context.Context, topic string, consumerGroupID string, useLastOffset bool, messageC chan<- Message) {
-	l := p.logger.WithField("component", "kafka-reader-messages")
+	l := p.logger.WithField("component", "kafka-reader-messages").WithField("topic", topic).WithField("group", consumerGroupID)
 
 	if !p.conf.Enable {
 		l.Warn("kafka is not enabled. nothing to read from...")
@@ -111,64 +194,79 @@ func (p *Plugin) ReadFromTopicWithContext(ctx context.Context, topic string, con
 		startOffset = kafka.LastOffset
 	}
 
-	kr := p.getReader(topic)
+	key := readerKey(topic, consumerGroupID)
+	kr := p.getReader(key)
 	if kr == nil {
-		kr = kafka.NewReader(kafka.ReaderConfig{
-			Brokers:     strings.Split(p.conf.Brokers, ","),
-			GroupID:     consumerGroupID,
-			Topic:       topic,
-			StartOffset: startOffset,
-		})
-		p.addToReaderMap(topic, kr)
+		kr = p.newGroupReader(key, topic, consumerGroupID, startOffset)
 	}
 
 	l.Debug("starting reading kafka messages routine")
 
+	b := newBackoff(p.conf.Reconnect.MinBackoff, p.conf.Reconnect.MaxBackoff)
 	for {
-		select {
-		case <-ctx.Done():
-			l.Trace("finished reading kafka messages...")
-
-			return
-		default:
-			m, err := kr.FetchMessage(ctx)
-			if err != nil && err == io.EOF {
+		m, err := kr.FetchMessage(ctx)
+		if err != nil {
+			switch {
+			case ctx.Err() != nil:
+				l.Trace("finished reading kafka messages...")
+				return
+			case errors.Is(err, io.EOF):
 				l.WithError(err).Warn("reader has been closed")
 				return
-			} else if err != nil {
+			case IsFatal(err):
 				l.WithError(err).Error("error fetching kafka message")
-				break // TODO restart reading?
-			}
-			l.WithFields(logrus.Fields{
-				"Topic":     m.Topic,
-				"Partition": m.Partition,
-				"Offset":    m.Offset,
-				"Key":       string(m.Key),
-				"Value":     string(m.Value),
-			}).Trace("message received") // TODO trace?
-
-			messageC <- Message{
-				Topic:         m.Topic,
-				Partition:     m.Partition,
-				Offset:        m.Offset,
-				HighWaterMark: m.HighWaterMark,
-				Key:           m.Key,
-				Value:         m.Value,
-				Time:          m.Time,
+				return
 			}
 
-			if err := kr.CommitMessages(ctx, m); err != nil {
-				l.WithField("message", m).WithError(err).Error("could not commit messge!")
-				p.metrics.AddCounter("kafka_"+topic+"_failure", 1.0, fmt.Sprintf("kafka [%s] failure message count", topic))
-				continue
+			l.WithError(err).WithField("backoff", b.current()).Warn("error fetching kafka message. reconnecting")
+			if !b.wait(ctx) {
+				return
 			}
+			kr.Close()
+			kr = p.newGroupReader(key, topic, consumerGroupID, startOffset)
+			continue
+		}
+		b.reset()
+
+		l.WithFields(logrus.Fields{
+			"Topic":     m.Topic,
+			"Partition": m.Partition,
+			"Offset":    m.Offset,
+			"Key":       string(m.Key),
+			"Value":     string(m.Value),
+		}).Trace("message received") // TODO trace?
 
-			p.metrics.AddCounter("kafka_"+topic+"_success", 1.0, fmt.Sprintf("kafka [%s] successfull message count", topic))
-			l.Trace("message commited")
+		select {
+		case <-ctx.Done():
+			return
+		case messageC <- message(m):
 		}
+
+		if err := kr.CommitMessages(ctx, m); err != nil {
+			l.WithField("message", m).WithError(err).Error("could not commit messge!")
+			p.metrics.AddCounter("kafka_"+topic+"_failure", 1.0, fmt.Sprintf("kafka [%s] failure message count", topic))
+			continue
+		}
+
+		p.metrics.AddCounter("kafka_"+topic+"_success", 1.0, fmt.Sprintf("kafka [%s] successfull message count", topic))
+		l.Trace("message commited")
 	}
 }
 
+// newGroupReader creates the reader of consumerGroupID on topic and stores it
+// as key. The start offset only applies if the group has no offsets yet.
+func (p *Plugin) newGroupReader(key string, topic string, consumerGroupID string, startOffset int64) *kafka.Reader {
+	kr := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     p.brokers(),
+		Dialer:      p.dialer,
+		GroupID:     consumerGroupID,
+		Topic:       topic,
+		StartOffset: startOffset,
+	})
+	p.addToReaderMap(key, kr)
+	return kr
+}
+
 func (p *Plugin) WriteToTopic(topic string, key string, value string) error {
 	l := p.logger.WithField("component", "kafka-writer-messages")
 
@@ -177,17 +275,7 @@ func (p *Plugin) WriteToTopic(topic string, key string, value string) error {
 		return nil
 	}
 
-	kw := p.getWriter(topic)
-	if kw == nil {
-		kw = &kafka.Writer{
-			Addr:     kafka.TCP(p.conf.Brokers),
-			Topic:    topic,
-			Balancer: &kafka.LeastBytes{},
-		}
-		p.addToWriterMap(topic, kw)
-	}
-
-	err := kw.WriteMessages(context.Background(),
+	err := p.writer(topic).WriteMessages(context.Background(),
 		kafka.Message{
 			Key:   []byte(key),
 			Value: []byte(value),
@@ -202,6 +290,43 @@ func (p *Plugin) WriteToTopic(topic string, key string, value string) error {
 	return nil
 }
 
+// WriteMessage writes m with its key, headers and time to topic.
+func (p *Plugin) WriteMessage(ctx context.Context, topic string, m Message) error {
+	return p.WriteMessages(ctx, topic, m)
+}
+
+// WriteMessages writes all messages to topic in one batch.
+func (p *Plugin) WriteMessages(ctx context.Context, topic string, messages ...Message) error {
+	l := p.logger.WithField("component", "kafka-writer-messages")
+
+	if !p.conf.Enable {
+		return ErrNotEnabled
+	}
+
+	kms := make([]kafka.Message, 0, len(messages))
+	for _, m := range messages {
+		kms = append(kms, kafkaMessage(m))
+	}
+
+	if err := p.writer(topic).WriteMessages(ctx, kms...); err != nil {
+		l.WithError(err).Error("failed to write message")
+		return err
+	}
+
+	l.Trace("finished writing kafka message")
+	return nil
+}
+
+// writer returns the cached synchronous writer of topic.
+func (p *Plugin) writer(topic string) *kafka.Writer {
+	kw := p.getWriter(topic)
+	if kw == nil {
+		kw = p.producer.writer(p.brokers(), p.transport, topic, false)
+		p.addToWriterMap(topic, kw)
+	}
+	return kw
+}
+
 func (p *Plugin) WriteToTopicAsync(topic string, key string, value string) {
 	l := p.logger.WithField("component", "kafka-writer-messages")
 
@@ -211,12 +336,7 @@ func (p *Plugin) WriteToTopicAsync(topic string, key string, value string) {
 
 	kw := p.getWriter(topic)
 	if kw == nil {
-		kw = &kafka.Writer{
-			Addr:     kafka.TCP(p.conf.Brokers),
-			Topic:    topic,
-			Balancer: &kafka.LeastBytes{},
-			Async:    true,
-		}
+		kw = p.producer.writer(p.brokers(), p.transport, topic, true)
 		p.addToWriterMap(topic, kw)
 	}
 
@@ -228,12 +348,28 @@ func (p *Plugin) Close() error {
 		return nil
 	}
 
+	// closing blocks until pending messages are handled, so the maps are
+	// copied and closed without holding the locks
+	p.rMutex.RLock()
+	krs := make(map[string]*kafka.Reader, len(p.krs))
 	for topic, kr := range p.krs {
+		krs[topic] = kr
+	}
+	p.rMutex.RUnlock()
+
+	p.wMutex.RLock()
+	kws := make(map[string]*kafka.Writer, len(p.kws))
+	for topic, kw := range p.kws {
+		kws[topic] = kw
+	}
+	p.wMutex.RUnlock()
+
+	for topic, kr := range krs {
 		p.logger.WithField("topic", topic).Debug("closing kafka reader")
 		kr.Close()
 	}
 
-	for topic, kw := range p.kws {
+	for topic, kw := range kws {
 		p.logger.WithField("topic", topic).Debug("closing kafka writer")
 		kw.Close()
 	}
@@ -250,6 +386,13 @@ func (p *Plugin) addToReaderMap(topic string, reader *kafka.Reader) {
 	p.krs[topic] = reader
 }
 
+func (p *Plugin) removeFromReaderMap(key string) {
+	defer p.rMutex.Unlock()
+	p.rMutex.Lock()
+
+	delete(p.krs, key)
+}
+
 func (p *Plugin) getReader(topic string) *kafka.Reader {
 	defer p.rMutex.RUnlock()
 	p.rMutex.RLock()
@@ -0,0 +1,157 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	ReconnectConfig struct {
+		// Readers failing to fetch wait between MinBackoff and MaxBackoff,
+		// doubling on every failure, before they reconnect.
+		MinBackoff time.Duration `env:"KAFKA_RECONNECT_MIN_BACKOFF" default:"500ms" yaml:"minBackoff"`
+		MaxBackoff time.Duration `env:"KAFKA_RECONNECT_MAX_BACKOFF" default:"30s" yaml:"maxBackoff"`
+	}
+
+	// backoff doubles the wait from min to max until it is reset.
+	backoff struct {
+		min  time.Duration
+		max  time.Duration
+		next time.Duration
+	}
+)
+
+// fatalErrors are not solved by reconnecting, readers stop on them.
+var fatalErrors = []error{
+	kafka.InvalidTopic,
+	kafka.InvalidGroupId,
+	kafka.TopicAuthorizationFailed,
+	kafka.GroupAuthorizationFailed,
+	kafka.ClusterAuthorizationFailed,
+	kafka.SASLAuthenticationFailed,
+	kafka.UnsupportedSASLMechanism,
+	kafka.IllegalSASLState,
+	kafka.UnsupportedVersion,
+	kafka.InvalidConfiguration,
+}
+
+// IsFatal reports whether err of a reader needs a change of configuration or
+// permissions. All other errors, e.g. unreachable brokers, leader elections
+// or topics not created yet, are retried with backoff.
+func IsFatal(err error) bool {
+	for _, fatal := range fatalErrors {
+		if errors.Is(err, fatal) {
+			return true
+		}
+	}
+	return false
+}
+
+func newBackoff(min time.Duration, max time.Duration) *backoff {
+	return &backoff{min: min, max: max, next: min}
+}
+
+// wait sleeps for the current backoff and doubles it. It returns false if ctx
+// is done first.
+func (b *backoff) wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(b.next):
+	}
+
+	b.next *= 2
+	if b.next <= 0 {
+		b.next = b.min
+	}
+	if b.max > 0 && b.next > b.max {
+		b.next = b.max
+	}
+	return true
+}
+
+func (b *backoff) current() time.Duration {
+	return b.next
+}
+
+func (b *backoff) reset() {
+	b.next = b.min
+}
+
+// readPartition fetches partition of topic from offset and sends the messages
+// to messageC until ctx is done. The reader is recreated at the next offset
+// after errors. Fatal errors are sent to errC.
+func (p *Plugin) readPartition(ctx context.Context, l *logrus.Entry, topic string, group string, partition int, offset int64, messageC chan<- kafka.Message, errC chan<- error) {
+	l = l.WithField("partition", partition)
+	key := readerKey(topic, group) + "/" + strconv.Itoa(partition)
+	defer p.removeFromReaderMap(key)
+
+	b := newBackoff(p.conf.Reconnect.MinBackoff, p.conf.Reconnect.MaxBackoff)
+	for {
+		kr := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   p.brokers(),
+			Dialer:    p.dialer,
+			Topic:     topic,
+			Partition: partition,
+		})
+		p.addToReaderMap(key, kr)
+
+		next, err := p.fetchPartition(ctx, kr, offset, messageC)
+		kr.Close()
+		if next > offset {
+			offset = next
+			b.reset()
+		}
+
+		switch {
+		case err == nil || ctx.Err() != nil || errors.Is(err, io.EOF):
+			return
+		case IsFatal(err):
+			l.WithError(err).Error("could not read partition")
+			errC <- err
+			return
+		}
+
+		l.WithError(err).WithField("backoff", b.current()).Warn("error fetching kafka message. reconnecting")
+		if !b.wait(ctx) {
+			return
+		}
+	}
+}
+
+// fetchPartition sends the messages of kr starting at offset to messageC and
+// returns the offset following the last message sent.
+func (p *Plugin) fetchPartition(ctx context.Context, kr *kafka.Reader, offset int64, messageC chan<- kafka.Message) (int64, error) {
+	if err := kr.SetOffset(offset); err != nil {
+		return offset, err
+	}
+
+	for {
+		m, err := kr.FetchMessage(ctx)
+		if err != nil {
+			return offset, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return offset, nil
+		case messageC <- m:
+			offset = m.Offset + 1
+		}
+	}
+}
+
+// readerKey identifies the reader of a consumer group on topic.
+func readerKey(topic string, consumerGroupID string) string {
+	return topic + "/" + consumerGroupID
+}
+
+func (p *Plugin) brokers() []string {
+	return Brokers(p.conf)
+}
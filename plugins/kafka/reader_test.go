@@ -0,0 +1,171 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestIsFatal(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"wrapped authorization":  {err: fmt.Errorf("fetch: %w", kafka.TopicAuthorizationFailed), want: true},
+		"sasl authentication":    {err: kafka.SASLAuthenticationFailed, want: true},
+		"invalid topic":          {err: kafka.InvalidTopic, want: true},
+		"unexpected eof":         {err: io.ErrUnexpectedEOF},
+		"leader not available":   {err: kafka.LeaderNotAvailable},
+		"unknown topic":          {err: kafka.UnknownTopicOrPartition},
+		"unreachable broker":     {err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}},
+		"context deadline":       {err: context.DeadlineExceeded},
+		"wrapped leader failure": {err: fmt.Errorf("fetch: %w", kafka.NotLeaderForPartition)},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsFatal(test.err); got != test.want {
+				t.Fatalf("got fatal %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(time.Millisecond, 3*time.Millisecond)
+
+	var got []time.Duration
+	for i := 0; i < 4; i++ {
+		if !b.wait(context.Background()) {
+			t.Fatal("wait returned false")
+		}
+		got = append(got, b.current())
+	}
+	if want := "[2ms 3ms 3ms 3ms]"; fmt.Sprint(got) != want {
+		t.Fatalf("got backoffs %v, want %s", got, want)
+	}
+
+	b.reset()
+	if b.current() != time.Millisecond {
+		t.Fatalf("got %s after reset", b.current())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if newBackoff(time.Hour, time.Hour).wait(ctx) {
+		t.Fatal("wait returned true for a done context")
+	}
+}
+
+// closedPort returns an address nothing listens on.
+func closedPort(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func newTestPlugin(t *testing.T, brokers string) *Plugin {
+	t.Helper()
+
+	conf := Config{Enable: true, Brokers: brokers}
+	conf.Reconnect = ReconnectConfig{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	p := New().Boot(conf, logrus.WithField("test", t.Name())).(*Plugin)
+	if err := p.BootError(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestReadPartitionReconnects(t *testing.T) {
+	p := newTestPlugin(t, closedPort(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	errC := make(chan error, 1)
+	go func() {
+		p.readPartition(ctx, logrus.WithField("test", t.Name()), "orders", "group", 0, 0, make(chan kafka.Message), errC)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reader did not stop with its context")
+	}
+	select {
+	case err := <-errC:
+		t.Fatalf("unreachable broker reported as fatal: %s", err)
+	default:
+	}
+	if p.getReader(readerKey("orders", "group")+"/0") != nil {
+		t.Fatal("stopped reader still registered")
+	}
+}
+
+func TestSeekNotEnabled(t *testing.T) {
+	p := New().Boot(Config{}).(*Plugin)
+	ctx := context.Background()
+
+	if _, err := p.Partitions(ctx, "orders"); !errors.Is(err, ErrNotEnabled) {
+		t.Fatalf("partitions: got error %v, want %v", err, ErrNotEnabled)
+	}
+	if _, err := p.OffsetsAt(ctx, "orders", time.Now()); !errors.Is(err, ErrNotEnabled) {
+		t.Fatalf("offsets at: got error %v, want %v", err, ErrNotEnabled)
+	}
+	if err := p.Seek(ctx, "orders", "group", map[int]int64{0: 1}); !errors.Is(err, ErrNotEnabled) {
+		t.Fatalf("seek: got error %v, want %v", err, ErrNotEnabled)
+	}
+	if err := p.SeekToTime(ctx, "orders", "group", time.Now()); !errors.Is(err, ErrNotEnabled) {
+		t.Fatalf("seek to time: got error %v, want %v", err, ErrNotEnabled)
+	}
+}
+
+func TestSeekUnreachableBroker(t *testing.T) {
+	p := newTestPlugin(t, closedPort(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := p.OffsetsAt(ctx, "orders", time.Now()); err == nil {
+		t.Fatal("offsets at: no error for an unreachable broker")
+	}
+	if err := p.Seek(ctx, "orders", "group", map[int]int64{0: 1}); err == nil {
+		t.Fatal("seek: no error for an unreachable broker")
+	}
+}
+
+func TestCloseWhileReadersChange(t *testing.T) {
+	p := newTestPlugin(t, closedPort(t))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("orders/%d", i)
+			p.addToReaderMap(key, kafka.NewReader(kafka.ReaderConfig{Brokers: p.brokers(), Topic: "orders", Partition: i}))
+			p.removeFromReaderMap(key)
+		}(i)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+}
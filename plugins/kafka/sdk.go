@@ -21,7 +21,16 @@ type (
 		HighWaterMark int64
 		Key           []byte
 		Value         []byte
-		Time          time.Time
+		Headers       []Header
+		// Time is the timestamp of the message. Writers use the current
+		// time if it is zero.
+		Time time.Time
+	}
+
+	// Header is a kafka record header. Keys may repeat.
+	Header struct {
+		Key   string
+		Value []byte
 	}
 )
 
@@ -37,3 +46,24 @@ func NewMeta(name string, version string) Meta {
 		Version:   version,
 	}
 }
+
+// Header returns the value of the first header with key or an empty string.
+func (m Message) Header(key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// SetHeader replaces all headers with key by one with value.
+func (m *Message) SetHeader(key string, value string) {
+	headers := m.Headers[:0:0]
+	for _, h := range m.Headers {
+		if h.Key != key {
+			headers = append(headers, h)
+		}
+	}
+	m.Headers = append(headers, Header{Key: key, Value: []byte(value)})
+}
@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	ErrUnknownTopic = errors.New("unknown topic")
+)
+
+// Partitions returns the partition ids of topic.
+func (p *Plugin) Partitions(ctx context.Context, topic string) ([]int, error) {
+	if !p.conf.Enable {
+		return nil, ErrNotEnabled
+	}
+
+	res, err := p.client().Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range res.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, t.Error
+		}
+
+		partitions := make([]int, 0, len(t.Partitions))
+		for _, pt := range t.Partitions {
+			partitions = append(partitions, pt.ID)
+		}
+		return partitions, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownTopic, topic)
+}
+
+// OffsetsAt returns the offset of the first message at or after t for every
+// partition of topic. Partitions without such a message map to their end.
+func (p *Plugin) OffsetsAt(ctx context.Context, topic string, t time.Time) (map[int]int64, error) {
+	partitions, err := p.Partitions(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, 2*len(partitions))
+	for _, partition := range partitions {
+		requests = append(requests, kafka.TimeOffsetOf(partition, t), kafka.LastOffsetOf(partition))
+	}
+	res, err := p.client().ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: requests},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := map[int]int64{}
+	for _, po := range res.Topics[topic] {
+		if po.Error != nil {
+			return nil, fmt.Errorf("partition %d: %w", po.Partition, po.Error)
+		}
+
+		offsets[po.Partition] = po.LastOffset
+		for offset := range po.Offsets {
+			if offset >= 0 {
+				offsets[po.Partition] = offset
+			}
+		}
+	}
+	return offsets, nil
+}
+
+// Seek sets the offsets consumerGroupID resumes topic at, e.g. to replay a
+// topic. Partitions missing in offsets keep their offsets.
+//
+// Kafka only accepts this while the group has no members. Consumers that are
+// running seek with RebalanceHooks.OnAssigned instead.
+func (p *Plugin) Seek(ctx context.Context, topic string, consumerGroupID string, offsets map[int]int64) error {
+	if !p.conf.Enable {
+		return ErrNotEnabled
+	}
+
+	commits := make([]kafka.OffsetCommit, 0, len(offsets))
+	for partition, offset := range offsets {
+		commits = append(commits, kafka.OffsetCommit{Partition: partition, Offset: offset})
+	}
+
+	res, err := p.client().OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      consumerGroupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pc := range res.Topics[topic] {
+		if pc.Error != nil {
+			errs = append(errs, fmt.Errorf("partition %d: %w", pc.Partition, pc.Error))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	p.logger.WithField("topic", topic).WithField("group", consumerGroupID).WithField("offsets", offsets).Info("consumer group offsets set")
+	return nil
+}
+
+// SeekToTime makes consumerGroupID resume every partition of topic at the
+// first message at or after t, see Seek.
+func (p *Plugin) SeekToTime(ctx context.Context, topic string, consumerGroupID string, t time.Time) error {
+	offsets, err := p.OffsetsAt(ctx, topic, t)
+	if err != nil {
+		return err
+	}
+	return p.Seek(ctx, topic, consumerGroupID, offsets)
+}
+
+func (p *Plugin) client() *kafka.Client {
+	c := &kafka.Client{Addr: kafka.TCP(p.brokers()...)}
+	if p.transport != nil {
+		c.Transport = p.transport
+	}
+	return c
+}
@@ -2,13 +2,16 @@ package kafkaconfigurator
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/caarlos0/env/v6"
+	"github.com/creasty/defaults"
 	"github.com/rs/xid"
 	"github.com/segmentio/kafka-go"
 	"github.com/siklol/zinc/plugins"
+	kafkaplugin "github.com/siklol/zinc/plugins/kafka"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 )
@@ -24,7 +27,7 @@ type (
 		k         *BrokerHandler
 		messageC  chan kafka.Message
 		shutdownC chan bool
-		bootupC   chan bool
+		cancel    context.CancelFunc
 	}
 )
 
@@ -44,7 +47,6 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	p.Logger = logrus.WithField("component", "kafkaconfigurator-plugin")
 	l := p.Logger
 	p.shutdownC = make(chan bool, 1)
-	p.bootupC = make(chan bool, 1)
 	p.messageC = make(chan kafka.Message, 1024)
 
 	l.Debug("booting up...")
@@ -56,19 +58,77 @@ func (p *Plugin) IsEnabled() bool {
 	return true
 }
 
+// Close stops reading config updates. It can be called more than once.
 func (p *Plugin) Close() error {
-	p.shutdownC <- true
+	select {
+	case p.shutdownC <- true:
+	default:
+	}
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.k != nil {
+		return p.k.Close()
+	}
 	return nil
 }
 
+// LoadConfig reads the config from topic. TLS and SASL are configured by the
+// environment variables of the kafka plugin, e.g. KAFKA_SASL_MECHANISM.
 func (p *Plugin) LoadConfig(brokers string, topic string, updateFn func(conf string) error) error {
+	conf, err := brokerConfig(brokers)
+	if err != nil {
+		return err
+	}
+	return p.LoadConfigWith(conf, topic, updateFn)
+}
+
+// LoadConfigWith is LoadConfig with an explicit kafka plugin config.
+func (p *Plugin) LoadConfigWith(conf kafkaplugin.Config, topic string, updateFn func(conf string) error) error {
 	l := p.Logger.WithField("module", "kafkaconsumer-channel")
-	p.k = NewBrokerHandler(p.Logger, brokers)
+
+	k, err := NewBrokerHandler(p.Logger, conf)
+	if err != nil {
+		return err
+	}
+	p.k = k
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	bootupC := p.watch(l, updateFn, configTimeout)
+
 	go func() {
+		err := p.k.ReadFromTopicWithContext(ctx, topic, xid.New().String(), p.messageC)
+		if err != nil && ctx.Err() == nil {
+			l.WithError(err).Fatal("error getting configuration")
+		}
+	}()
+
+	if isOk := <-bootupC; !isOk {
+		return fmt.Errorf("configuration could not be loaded after %s", configTimeout)
+	}
+
+	return nil
+}
+
+// watch passes config messages to updateFn until Close. The returned channel
+// receives true after the first config or false after timeout, whichever
+// comes first. Later messages only update the config.
+func (p *Plugin) watch(l *log.Entry, updateFn func(conf string) error, timeout time.Duration) <-chan bool {
+	bootupC := make(chan bool, 1)
+	var bootOnce sync.Once
+	booted := func(isOk bool) {
+		bootOnce.Do(func() { bootupC <- isOk })
+	}
+
+	go func() {
+		timeoutC := time.After(timeout)
 		for {
 			select {
-			case <-time.After(configTimeout):
-				p.bootupC <- false
+			case <-timeoutC:
+				booted(false)
+				timeoutC = nil
 			case <-p.shutdownC:
 				l.Debug("shutdown kafkaconsumer-channel")
 				return
@@ -84,21 +144,24 @@ func (p *Plugin) LoadConfig(brokers string, topic string, updateFn func(conf str
 				}
 				l.Debug("finished updating config")
 
-				p.bootupC <- true
+				timeoutC = nil
+				booted(true)
 			}
 		}
 	}()
 
-	go func() {
-		err := p.k.ReadFromTopicWithContext(context.Background(), topic, xid.New().String(), p.messageC)
-		if err != nil {
-			l.WithError(err).Fatal("error getting configuration")
-		}
-	}()
+	return bootupC
+}
 
-	if isOk := <-p.bootupC; !isOk {
-		return errors.New(fmt.Sprintf("configuration could not be loaded after %d ms", configTimeout))
+// brokerConfig is the kafka plugin config of the environment with brokers.
+func brokerConfig(brokers string) (kafkaplugin.Config, error) {
+	conf := kafkaplugin.Config{}
+	if err := defaults.Set(&conf); err != nil {
+		return conf, err
 	}
-
-	return nil
+	if err := env.Parse(&conf); err != nil {
+		return conf, err
+	}
+	conf.Brokers = brokers
+	return conf, nil
 }
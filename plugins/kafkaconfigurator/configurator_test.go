@@ -0,0 +1,79 @@
+package kafkaconfigurator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestWatchAppliesEveryUpdate(t *testing.T) {
+	p := New().Boot(nil).(*Plugin)
+	defer p.Close()
+
+	updates := make(chan string, 10)
+	bootupC := p.watch(p.Logger, func(conf string) error {
+		updates <- conf
+		return nil
+	}, time.Hour)
+
+	for _, conf := range []string{"a", "b", "c"} {
+		p.messageC <- kafka.Message{Value: []byte(conf)}
+		select {
+		case got := <-updates:
+			if got != conf {
+				t.Fatalf("got update %q, want %q", got, conf)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("update %q not applied", conf)
+		}
+	}
+
+	if isOk := <-bootupC; !isOk {
+		t.Fatal("boot signalled failure")
+	}
+}
+
+func TestWatchKeepsUpdatingAfterTimeout(t *testing.T) {
+	p := New().Boot(nil).(*Plugin)
+	defer p.Close()
+
+	updates := make(chan string, 10)
+	bootupC := p.watch(p.Logger, func(conf string) error {
+		updates <- conf
+		return nil
+	}, time.Millisecond)
+
+	if isOk := <-bootupC; isOk {
+		t.Fatal("boot signalled success without config")
+	}
+
+	for _, conf := range []string{"late", "later"} {
+		p.messageC <- kafka.Message{Value: []byte(conf)}
+		select {
+		case got := <-updates:
+			if got != conf {
+				t.Fatalf("got update %q, want %q", got, conf)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("update %q not applied after timeout", conf)
+		}
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	p := New().Boot(nil).(*Plugin)
+
+	done := make(chan struct{})
+	go func() {
+		p.Close()
+		p.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked")
+	}
+}
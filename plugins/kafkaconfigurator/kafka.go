@@ -3,26 +3,35 @@ package kafkaconfigurator
 import (
 	"context"
 	"errors"
-	"strings"
 
 	"github.com/segmentio/kafka-go"
+	kafkaplugin "github.com/siklol/zinc/plugins/kafka"
 	"github.com/sirupsen/logrus"
 )
 
 type (
 	BrokerHandler struct {
-		logger  *logrus.Entry
-		brokers string
-		krs     map[string]*kafka.Reader
+		logger *logrus.Entry
+		conf   kafkaplugin.Config
+		dialer *kafka.Dialer
+		krs    map[string]*kafka.Reader
 	}
 )
 
-func NewBrokerHandler(l *logrus.Entry, brokers string) *BrokerHandler {
-	return &BrokerHandler{
-		logger:  l,
-		brokers: brokers,
-		krs:     map[string]*kafka.Reader{},
+// NewBrokerHandler connects with the brokers, TLS and SASL settings of the
+// kafka plugin config.
+func NewBrokerHandler(l *logrus.Entry, conf kafkaplugin.Config) (*BrokerHandler, error) {
+	dialer, err := kafkaplugin.NewDialer(conf)
+	if err != nil {
+		return nil, err
 	}
+
+	return &BrokerHandler{
+		logger: l,
+		conf:   conf,
+		dialer: dialer,
+		krs:    map[string]*kafka.Reader{},
+	}, nil
 }
 
 func (bh *BrokerHandler) ReadFromTopicWithContext(ctx context.Context, topic string, consumerGroupID string, messageC chan kafka.Message) error {
@@ -30,7 +39,8 @@ func (bh *BrokerHandler) ReadFromTopicWithContext(ctx context.Context, topic str
 
 	if _, isOK := bh.krs[topic]; !isOK {
 		bh.krs[topic] = kafka.NewReader(kafka.ReaderConfig{
-			Brokers:     strings.Split(bh.brokers, ","),
+			Brokers:     kafkaplugin.Brokers(bh.conf),
+			Dialer:      bh.dialer,
 			GroupID:     consumerGroupID,
 			Topic:       topic,
 			StartOffset: kafka.FirstOffset,
@@ -60,7 +70,11 @@ func (bh *BrokerHandler) ReadFromTopicWithContext(ctx context.Context, topic str
 				"Value":         string(m.Value),
 			}).Trace("message received") // TODO trace?
 
-			messageC <- m
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case messageC <- m:
+			}
 		}
 	}
 }
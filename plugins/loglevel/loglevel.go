@@ -33,10 +33,7 @@ func (p *Plugin) Name() string {
 
 func (p *Plugin) Start() error {
 	setLogLevel(p.conf.LogLevel)
-
-	if strings.ToLower(p.conf.LogFormat) == "json" {
-		log.SetFormatter(&log.JSONFormatter{})
-	}
+	setLogFormat(p.conf.LogFormat)
 
 	if p.conf.LogFile == "" {
 		return nil
@@ -52,6 +49,34 @@ func (p *Plugin) Start() error {
 	return nil
 }
 
+func (p *Plugin) Reload(newConf interface{}) error {
+	conf := newConf.(Config)
+
+	setLogLevel(conf.LogLevel)
+	setLogFormat(conf.LogFormat)
+
+	if conf.LogFile != p.conf.LogFile {
+		var f *os.File
+		if conf.LogFile != "" {
+			var err error
+			if f, err = os.OpenFile(conf.LogFile, os.O_WRONLY|os.O_CREATE, 0755); err != nil {
+				return err
+			}
+			log.SetOutput(f)
+		} else {
+			log.SetOutput(os.Stderr)
+		}
+
+		if p.logFile != nil {
+			p.logFile.Close()
+		}
+		p.logFile = f
+	}
+
+	p.conf = conf
+	return nil
+}
+
 func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plugin {
 	return &Plugin{
 		conf: conf.(Config),
@@ -69,6 +94,14 @@ func (p *Plugin) IsEnabled() bool {
 	return true
 }
 
+func setLogFormat(logFormat string) {
+	if strings.ToLower(logFormat) == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+		return
+	}
+	log.SetFormatter(&log.TextFormatter{})
+}
+
 func setLogLevel(logLevel string) {
 	if logLevel != "" {
 		lvl, err := log.ParseLevel(logLevel)
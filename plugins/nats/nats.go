@@ -1,6 +1,10 @@
 package nats
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/nats-io/jsm.go"
@@ -12,16 +16,17 @@ import (
 
 type (
 	Plugin struct {
-		logger *logrus.Entry
-		conf   Config
-		nc     *nats.Conn
-		sc     stan.Conn
-		js     *jsm.Manager
+		logger  *logrus.Entry
+		conf    Config
+		nc      *nats.Conn
+		sc      stan.Conn
+		js      *jsm.Manager
+		bootErr error
 	}
 
 	Config struct {
 		Enable      bool   `env:"NATS_ENABLE" default:"false" yaml:"enable"`
-		Host        string `env:"NATS_HOST" yaml:"host"`
+		Host        string `env:"NATS_HOST" yaml:"host" validate:"required"`
 		ClientName  string `default:"mesh-node" env:"NATS_CLIENT_NAME" yaml:"clientName"`
 		ClusterID   string `env:"NATS_CLUSTER_ID" yaml:"clusterId"`
 		ClientID    string `env:"NATS_CLIENT_ID" yaml:"clientId"`
@@ -34,6 +39,22 @@ type (
 
 const Name = "nats"
 
+var (
+	ErrNotConnected = errors.New("nats connection not established")
+)
+
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "nats",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -65,19 +86,25 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 		nats.ReconnectWait(1 * time.Second),
 	}...)
 	if err != nil {
-		l.WithField("error-type", "nats connection error").Fatal(err)
+		l.WithField("error-type", "nats connection error").Error(err)
+		return p.bootFailed(fmt.Errorf("nats connection: %w", err))
 	}
 	p.nc = nc
 
 	sc, err := stan.Connect(p.conf.ClusterID, p.conf.ClientID, stan.NatsConn(nc))
 	if err != nil {
-		l.WithField("error-type", "nats streaming connection error").Fatal(err)
+		l.WithField("error-type", "nats streaming connection error").Error(err)
+		p.nc.Close()
+		return p.bootFailed(fmt.Errorf("nats streaming connection: %w", err))
 	}
 	p.sc = sc
 
 	mgr, err := jsm.New(p.nc, jsm.WithTimeout(time.Duration(p.conf.JetStream.Timeout)*time.Second))
 	if err != nil {
-		l.WithField("error-type", "nats jetstream connection error").Fatal(err)
+		l.WithField("error-type", "nats jetstream connection error").Error(err)
+		p.sc.Close()
+		p.nc.Close()
+		return p.bootFailed(fmt.Errorf("nats jetstream connection: %w", err))
 	}
 	p.js = mgr
 
@@ -90,6 +117,16 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	return p
 }
 
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
+func (p *Plugin) bootFailed(err error) plugins.Plugin {
+	p.bootErr = err
+	p.conf.Enable = false
+	return p
+}
+
 func (p *Plugin) Start() error {
 	return nil
 }
@@ -106,6 +143,18 @@ func (p *Plugin) Close() error {
 	return p.sc.Close()
 }
 
+func (p *Plugin) Health(ctx context.Context) error {
+	if !p.conf.Enable {
+		return nil
+	}
+
+	if status := p.nc.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("%w: %s", ErrNotConnected, status)
+	}
+
+	return nil
+}
+
 func (p *Plugin) NC() *nats.Conn {
 	return p.nc
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -19,9 +20,11 @@ import (
 
 type (
 	Plugin struct {
-		logger *logrus.Entry
-		conf   Config
-		db     *sqlx.DB
+		logger    *logrus.Entry
+		conf      Config
+		db        *sqlx.DB
+		dependsOn []string
+		bootErr   error
 	}
 
 	Transaction struct {
@@ -36,6 +39,23 @@ type (
 
 const Name = "postgres-crud"
 
+var (
+	ErrNoDatabase = errors.New("postgres connection not available")
+)
+
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "postgresCrud",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+			plugins.TypeOf[*postgres.Plugin](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -58,10 +78,10 @@ func (p *Plugin) Boot(conf any, dependencies ...any) plugins.Plugin {
 		case *logrus.Entry:
 			p.logger = dp.WithField("component", "postgres-crud")
 		case *postgres.Plugin:
-			if !dp.IsEnabled() {
-				return p
+			p.dependsOn = append(p.dependsOn, dp.Name())
+			if dp.IsEnabled() {
+				p.db = dp.DB()
 			}
-			p.db = dp.DB()
 		}
 	}
 
@@ -70,9 +90,20 @@ func (p *Plugin) Boot(conf any, dependencies ...any) plugins.Plugin {
 		return p
 	}
 
+	if p.db == nil {
+		l.Error("postgres connection not available. failing")
+		p.bootErr = ErrNoDatabase
+		p.conf.Enable = false
+		return p
+	}
+
 	return p
 }
 
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
 func (p *Plugin) CreateTable(name string) (*Transaction, error) {
 	if !p.conf.Enable {
 		return nil, errors.New("postgres crud not enabled")
@@ -158,6 +189,10 @@ func (tx *Transaction) Delete(id string) error {
 	return err
 }
 
+func (p *Plugin) DependsOn() []string {
+	return p.dependsOn
+}
+
 func (p *Plugin) IsEnabled() bool {
 	return p.conf.Enable
 }
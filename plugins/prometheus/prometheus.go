@@ -1,7 +1,9 @@
 package prometheus
 
 import (
+	"context"
 	"net/http"
+	"reflect"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -16,6 +18,7 @@ type (
 		conf        Config
 		incCounters map[string]prometheus.Counter
 		incGauges   map[string]prometheus.Gauge
+		srv         *http.Server
 	}
 
 	Config struct {
@@ -26,6 +29,18 @@ type (
 
 const Name = "prometheus"
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "prometheus",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{
 		incCounters: map[string]prometheus.Counter{},
@@ -62,11 +77,15 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 }
 
 func (p *Plugin) Close() error {
-	if !p.conf.Enable {
+	return p.CloseContext(context.Background())
+}
+
+func (p *Plugin) CloseContext(ctx context.Context) error {
+	if !p.conf.Enable || p.srv == nil {
 		return nil
 	}
 
-	return nil
+	return p.srv.Shutdown(ctx)
 }
 
 func (p *Plugin) Start() error {
@@ -75,9 +94,17 @@ func (p *Plugin) Start() error {
 	}
 
 	p.logger.Debug("starting up prometheus server")
-	http.Handle("/metrics", promhttp.Handler())
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	p.srv = &http.Server{Addr: ":" + p.conf.Port, Handler: mux}
+
+	go func() {
+		if err := p.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			p.logger.WithError(err).Error("prometheus server stopped")
+		}
+	}()
 
-	return http.ListenAndServe(":"+p.conf.Port, nil)
+	return nil
 }
 
 func (p *Plugin) AddCounter(name string, count float64, help string) {
@@ -0,0 +1,107 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Registration describes how core builds a plugin. Plugin packages
+	// register themselves in init so that importing them is enough to make
+	// them available to core.
+	Registration struct {
+		Name string
+		// ConfigKey is the yaml/json key of the plugin section in the core
+		// config.
+		ConfigKey string
+		// Config returns a pointer to a new, empty config struct of the plugin.
+		Config func() interface{}
+		New    func() Plugin
+		// Dependencies lists the types Boot expects as dependencies. Core
+		// resolves them from the logger, the boot ID and all plugins built so
+		// far. See TypeOf.
+		Dependencies []reflect.Type
+	}
+
+	// RawConfig keeps a plugin config section undecoded until core knows the
+	// config type of the plugin.
+	RawConfig struct {
+		node *yaml.Node
+		json json.RawMessage
+	}
+)
+
+var (
+	ErrEmptyRawConfig  = errors.New("raw config is empty")
+	ErrRestartRequired = errors.New("config change requires a restart")
+
+	registryMutex = &sync.RWMutex{}
+	registry      = map[string]Registration{}
+)
+
+// Register makes a plugin available to core. It panics if Register is called
+// twice with the same name or without a factory.
+func Register(r Registration) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if r.New == nil || r.Config == nil {
+		panic("plugins: Register " + r.Name + " without factory or config")
+	}
+	if _, isOk := registry[r.Name]; isOk {
+		panic("plugins: Register called twice for plugin " + r.Name)
+	}
+	if r.ConfigKey == "" {
+		r.ConfigKey = r.Name
+	}
+
+	registry[r.Name] = r
+}
+
+// Registrations returns all registered plugins sorted by name.
+func Registrations() []Registration {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	regs := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		regs = append(regs, r)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		return regs[i].Name < regs[j].Name
+	})
+
+	return regs
+}
+
+// TypeOf returns the type of T. Use it to declare dependencies, including
+// interfaces, e.g. TypeOf[*logrus.Entry]() or TypeOf[kafka.MetricsWriter]().
+func TypeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (rc *RawConfig) UnmarshalYAML(node *yaml.Node) error {
+	rc.node = node
+	return nil
+}
+
+func (rc *RawConfig) UnmarshalJSON(data []byte) error {
+	rc.json = append(json.RawMessage{}, data...)
+	return nil
+}
+
+// Decode unmarshals the section into target.
+func (rc RawConfig) Decode(target interface{}) error {
+	switch {
+	case rc.node != nil:
+		return rc.node.Decode(target)
+	case rc.json != nil:
+		return json.Unmarshal(rc.json, target)
+	}
+	return ErrEmptyRawConfig
+}
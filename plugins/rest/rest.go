@@ -1,6 +1,9 @@
 package rest
 
 import (
+	"context"
+	"net/http"
+	"reflect"
 	"strconv"
 	"time"
 
@@ -20,12 +23,24 @@ type (
 
 	Config struct {
 		Enable bool   `env:"REST_ENABLE" default:"false" yaml:"enable"`
-		Port   string `env:"PORT" default:"8099" yaml:"port"`
+		Port   string `env:"PORT" default:"8099" yaml:"port" validate:"required"`
 	}
 )
 
 const Name = "rest"
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "rest",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -71,6 +86,13 @@ func (p *Plugin) Close() error {
 	return p.e.Close()
 }
 
+func (p *Plugin) CloseContext(ctx context.Context) error {
+	if !p.conf.Enable {
+		return nil
+	}
+	return p.e.Shutdown(ctx)
+}
+
 func (p *Plugin) IsEnabled() bool {
 	return p.conf.Enable
 }
@@ -81,7 +103,11 @@ func (p *Plugin) Start() error {
 	}
 
 	p.logger.WithField("port", p.conf.Port).Debug("rest webserver started...")
-	p.e.Logger.Error(p.e.Start(":" + p.conf.Port))
+	go func() {
+		if err := p.e.Start(":" + p.conf.Port); err != nil && err != http.ErrServerClosed {
+			p.e.Logger.Error(err)
+		}
+	}()
 	return nil
 }
 
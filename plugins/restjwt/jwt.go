@@ -4,22 +4,25 @@ import (
 	"crypto/rsa"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+
 	"github.com/golang-jwt/jwt"
 	"github.com/siklol/zinc/plugins"
 	"github.com/sirupsen/logrus"
-	"strings"
 )
 
 type (
 	Plugin struct {
-		logger *logrus.Entry
-		conf   Config
-		j      *Handler
+		logger  *logrus.Entry
+		conf    Config
+		j       *Handler
+		bootErr error
 	}
 
 	Config struct {
 		Enable     bool   `env:"REST_ENABLE" default:"false" yaml:"enable" json:"enable"`
-		ServerCert string `env:"SERVER_CERT" yaml:"serverCert" json:"serverCert"`
+		ServerCert string `env:"SERVER_CERT" yaml:"serverCert" json:"serverCert" secret:"true"`
 	}
 
 	Handler struct {
@@ -46,7 +49,19 @@ var (
 	ErrInvalidToken             = errors.New("invalid token")
 )
 
-const Name = "rest"
+const Name = "restjwt"
+
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "restJwt",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+		},
+	})
+}
 
 func New() *Plugin {
 	return &Plugin{}
@@ -71,15 +86,27 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 		return p
 	}
 
-	j, err := NewJwtHandler(p.logger, "")
+	j, err := NewJwtHandler(p.logger, p.conf.ServerCert)
 	if err != nil {
-		p.logger.WithError(err).Fatal("error init jwt handler")
+		p.logger.WithError(err).Error("error init jwt handler")
+		p.bootErr = err
+		p.conf.Enable = false
+		return p
 	}
 	p.j = j
 
 	return p
 }
 
+// Handler returns the token handler or nil if the plugin is disabled.
+func (p *Plugin) Handler() *Handler {
+	return p.j
+}
+
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
 func (p *Plugin) Close() error {
 	if !p.conf.Enable {
 		return nil
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/minio/minio-go/v7"
@@ -19,17 +20,18 @@ const Name = "s3file"
 
 type (
 	Plugin struct {
-		logger *log.Entry
-		mc     *minio.Client
-		conf   Config
+		logger  *log.Entry
+		mc      *minio.Client
+		conf    Config
+		bootErr error
 	}
 
 	Config struct {
 		Enable           bool          `env:"S3_FILE_ENABLE" default:"false" yaml:"enable"`
 		AutoCreateBucket bool          `env:"S3_FILE_AUTO_CREATE_BUCKET" default:"true" yaml:"autoCreateBucket"`
-		URL              string        `env:"S3_FILE_URL" yaml:"url"`
-		AccessKeyID      string        `env:"S3_FILE_ACCESS_KEY_ID" yaml:"accessKeyId"`
-		AccessKey        string        `env:"S3_FILE_ACCESS_KEY" yaml:"accessKey"`
+		URL              string        `env:"S3_FILE_URL" yaml:"url" validate:"required"`
+		AccessKeyID      string        `env:"S3_FILE_ACCESS_KEY_ID" yaml:"accessKeyId" secret:"true"`
+		AccessKey        string        `env:"S3_FILE_ACCESS_KEY" yaml:"accessKey" secret:"true"`
 		Timeout          time.Duration `env:"S3_FILE_TIMEOUT" default:"30s" yaml:"timeout"`
 		UseSSL           bool          `env:"S3_FILE_USE_SSL" default:"true" yaml:"useSsl"`
 	}
@@ -39,6 +41,18 @@ var (
 	policy = `{"Version": "2012-10-17","Statement": [{"Action": ["s3:GetObject"],"Effect": "Allow","Principal": {"AWS": ["*"]},"Resource": ["arn:aws:s3:::%s/*"],"Sid": ""}]}`
 )
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "s3File",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -72,7 +86,10 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 		Secure: p.conf.UseSSL,
 	})
 	if err != nil {
-		l.WithError(err).Fatal("minio client creation failed")
+		l.WithError(err).Error("minio client creation failed")
+		p.bootErr = fmt.Errorf("minio client: %w", err)
+		p.conf.Enable = false
+		return p
 	}
 	p.mc = minioClient
 
@@ -81,6 +98,10 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	return p
 }
 
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
 func (p *Plugin) IsEnabled() bool {
 	return p.conf.Enable
 }
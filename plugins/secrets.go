@@ -0,0 +1,174 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// SecretResolver returns the secret a config value refers to. ref is the
+	// config value without the prefix the resolver is registered for.
+	SecretResolver interface {
+		ResolveSecret(ref string) (string, error)
+	}
+
+	// SecretResolverFunc adapts a function to SecretResolver.
+	SecretResolverFunc func(ref string) (string, error)
+
+	// secretField is a field of a struct type that held a secret reference.
+	secretField struct {
+		t    reflect.Type
+		name string
+	}
+)
+
+var (
+	ErrSecretNotFound = errors.New("secret not found")
+
+	secretMutex     = &sync.RWMutex{}
+	secretResolvers = map[string]SecretResolver{
+		"file://": SecretResolverFunc(fileSecret),
+		"env:":    SecretResolverFunc(envSecret),
+	}
+	secretFields = map[secretField]struct{}{}
+)
+
+func (f SecretResolverFunc) ResolveSecret(ref string) (string, error) {
+	return f(ref)
+}
+
+// RegisterSecretResolver makes config values starting with prefix resolve
+// through r, e.g. RegisterSecretResolver("vault:", r). Registering a prefix
+// again replaces the resolver.
+func RegisterSecretResolver(prefix string, r SecretResolver) {
+	secretMutex.Lock()
+	defer secretMutex.Unlock()
+
+	secretResolvers[prefix] = r
+}
+
+// ResolveSecret resolves value if it starts with the prefix of a registered
+// resolver. Values without a known prefix are returned unchanged and
+// isRef is false.
+func ResolveSecret(value string) (secret string, isRef bool, err error) {
+	secretMutex.RLock()
+	prefixes := make([]string, 0, len(secretResolvers))
+	for prefix := range secretResolvers {
+		prefixes = append(prefixes, prefix)
+	}
+	secretMutex.RUnlock()
+
+	// longest prefix first so that e.g. vault:kv: wins over vault:
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(value, prefix) {
+			continue
+		}
+
+		secretMutex.RLock()
+		r := secretResolvers[prefix]
+		secretMutex.RUnlock()
+
+		secret, err := r.ResolveSecret(strings.TrimPrefix(value, prefix))
+		if err != nil {
+			return "", true, fmt.Errorf("resolve %s: %w", value, err)
+		}
+		return secret, true, nil
+	}
+
+	return value, false, nil
+}
+
+// ResolveSecrets replaces every string in conf that refers to a secret with
+// the secret. conf has to be a pointer.
+func ResolveSecrets(conf interface{}) error {
+	v := reflect.ValueOf(conf)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	return resolveSecrets(v.Elem(), nil)
+}
+
+// IsSecretField reports whether field name of struct type t held a secret
+// reference in any resolved config. Config dumps use it to hide secrets in
+// fields not tagged as secret.
+func IsSecretField(t reflect.Type, name string) bool {
+	secretMutex.RLock()
+	defer secretMutex.RUnlock()
+
+	_, isOk := secretFields[secretField{t: t, name: name}]
+	return isOk
+}
+
+// resolveSecrets resolves the strings in v. field is the struct field v
+// belongs to, strings in slices and maps belong to the field of the
+// collection.
+func resolveSecrets(v reflect.Value, field *secretField) error {
+	switch v.Kind() {
+	case reflect.String:
+		secret, isRef, err := ResolveSecret(v.String())
+		if err != nil || !isRef {
+			return err
+		}
+		v.SetString(secret)
+
+		if field != nil {
+			secretMutex.Lock()
+			secretFields[*field] = struct{}{}
+			secretMutex.Unlock()
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return resolveSecrets(v.Elem(), field)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if err := resolveSecrets(v.Field(i), &secretField{t: v.Type(), name: f.Name}); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveSecrets(v.Index(i), field); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(k))
+			if err := resolveSecrets(elem, field); err != nil {
+				return fmt.Errorf("%v: %w", k.Interface(), err)
+			}
+			v.SetMapIndex(k, elem)
+		}
+	}
+
+	return nil
+}
+
+func fileSecret(ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func envSecret(ref string) (string, error) {
+	secret, isOk := os.LookupEnv(ref)
+	if !isOk {
+		return "", fmt.Errorf("%w: env %s", ErrSecretNotFound, ref)
+	}
+	return secret, nil
+}
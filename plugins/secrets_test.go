@@ -0,0 +1,112 @@
+package plugins
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type (
+	secretTestConfig struct {
+		Password string
+		Plain    string
+		Nested   secretTestNested
+		Headers  map[string]string
+		Hosts    []string
+		Optional *secretTestNested
+	}
+
+	secretTestNested struct {
+		Token string
+	}
+)
+
+func TestResolveSecret(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRETS_TEST_TOKEN", "from-env")
+
+	tests := map[string]struct {
+		value   string
+		secret  string
+		isRef   bool
+		wantErr error
+	}{
+		"file":         {value: "file://" + file, secret: "from-file", isRef: true},
+		"env":          {value: "env:SECRETS_TEST_TOKEN", secret: "from-env", isRef: true},
+		"plain":        {value: "postgres", secret: "postgres"},
+		"unknown":      {value: "vault:kv/db", secret: "vault:kv/db"},
+		"missing env":  {value: "env:SECRETS_TEST_MISSING", isRef: true, wantErr: ErrSecretNotFound},
+		"missing file": {value: "file://" + file + ".missing", isRef: true, wantErr: os.ErrNotExist},
+		"empty":        {value: "", secret: ""},
+	}
+
+	for name, tt := range tests {
+		secret, isRef, err := ResolveSecret(tt.value)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) || !isRef {
+				t.Errorf("%s: got %t, %v, want %v", name, isRef, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || secret != tt.secret || isRef != tt.isRef {
+			t.Errorf("%s: got %q, %t, %v, want %q, %t", name, secret, isRef, err, tt.secret, tt.isRef)
+		}
+	}
+}
+
+func TestRegisterSecretResolver(t *testing.T) {
+	RegisterSecretResolver("secretstest:", SecretResolverFunc(func(ref string) (string, error) {
+		return "resolved-" + ref, nil
+	}))
+
+	secret, isRef, err := ResolveSecret("secretstest:db")
+	if err != nil || !isRef || secret != "resolved-db" {
+		t.Fatalf("got %q, %t, %v", secret, isRef, err)
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("SECRETS_TEST_PASSWORD", "password")
+	t.Setenv("SECRETS_TEST_TOKEN", "token")
+
+	conf := secretTestConfig{
+		Password: "env:SECRETS_TEST_PASSWORD",
+		Plain:    "password",
+		Nested:   secretTestNested{Token: "env:SECRETS_TEST_TOKEN"},
+		Headers:  map[string]string{"x-token": "env:SECRETS_TEST_TOKEN"},
+		Hosts:    []string{"db", "env:SECRETS_TEST_TOKEN"},
+		Optional: &secretTestNested{Token: "env:SECRETS_TEST_TOKEN"},
+	}
+	if err := ResolveSecrets(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Password != "password" || conf.Nested.Token != "token" || conf.Headers["x-token"] != "token" ||
+		conf.Hosts[1] != "token" || conf.Optional.Token != "token" {
+		t.Fatalf("got %+v", conf)
+	}
+
+	configType := reflect.TypeOf(secretTestConfig{})
+	for field, want := range map[string]bool{"Password": true, "Headers": true, "Hosts": true, "Plain": false} {
+		if got := IsSecretField(configType, field); got != want {
+			t.Errorf("IsSecretField %s: got %t, want %t", field, got, want)
+		}
+	}
+	if !IsSecretField(reflect.TypeOf(secretTestNested{}), "Token") {
+		t.Error("nested field not recorded")
+	}
+}
+
+func TestResolveSecretsError(t *testing.T) {
+	conf := secretTestConfig{Nested: secretTestNested{Token: "env:SECRETS_TEST_MISSING"}}
+
+	err := ResolveSecrets(&conf)
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrSecretNotFound)
+	}
+}
@@ -2,6 +2,7 @@ package slack
 
 import (
 	"errors"
+	"reflect"
 
 	"github.com/ashwanthkumar/slack-go-webhook"
 	"github.com/siklol/zinc/plugins"
@@ -11,19 +12,36 @@ import (
 
 const Name = "slack"
 
+var (
+	ErrEmptyWebhookURL = errors.New("slack webhook url is empty")
+)
+
 type (
 	Plugin struct {
-		logger *log.Entry
-		conf   Config
+		logger  *log.Entry
+		conf    Config
+		bootErr error
 	}
 
 	Config struct {
 		Enable bool   `env:"SLACK_ENABLE" default:"false" yaml:"enable"`
-		URL    string `env:"SLACK_URL" yaml:"url"`
+		URL    string `env:"SLACK_URL" yaml:"url" secret:"true" validate:"required"`
 		Proxy  string `env:"SLACK_PROXY" yaml:"proxy"`
 	}
 )
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "slack",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -53,7 +71,10 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	}
 
 	if p.conf.URL == "" {
-		l.Fatal("slack webhook url is empty.")
+		l.Error("slack webhook url is empty.")
+		p.bootErr = ErrEmptyWebhookURL
+		p.conf.Enable = false
+		return p
 	}
 
 	l.Debug("booting up...")
@@ -61,6 +82,23 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	return p
 }
 
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
+func (p *Plugin) Reload(newConf interface{}) error {
+	conf := newConf.(Config)
+	if conf.Enable != p.conf.Enable {
+		return plugins.ErrRestartRequired
+	}
+	if conf.Enable && conf.URL == "" {
+		return ErrEmptyWebhookURL
+	}
+
+	p.conf = conf
+	return nil
+}
+
 func (p *Plugin) IsEnabled() bool {
 	return p.conf.Enable
 }
@@ -1,9 +1,12 @@
 package telegram
 
 import (
+	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/siklol/zinc/plugins"
+	"github.com/siklol/zinc/plugins/usermanager"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 	tb "gopkg.in/telebot.v3"
@@ -13,19 +16,34 @@ const Name = "telegram"
 
 type (
 	Plugin struct {
-		Logger *log.Entry
-		b      *tb.Bot
-		conf   Config
+		Logger    *log.Entry
+		b         *tb.Bot
+		conf      Config
+		dependsOn []string
+		bootErr   error
 	}
 
 	Config struct {
 		Enable    bool   `env:"TELEGRAM_ENABLE" default:"false" yaml:"enable"`
-		APIkey    string `env:"TELEGRAM_API_KEY" yaml:"apiKey"`
+		APIkey    string `env:"TELEGRAM_API_KEY" yaml:"apiKey" secret:"true" validate:"required"`
 		URL       string `env:"TELEGRAM_URL" yaml:"url"`
 		UserTable string `env:"TELEGRAM_USER_TABLE" yaml:"userTable"`
 	}
 )
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "telegram",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+			plugins.TypeOf[*usermanager.Plugin](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -35,7 +53,7 @@ func (bp *Plugin) Start() error {
 		return nil
 	}
 	bp.Logger.Debug("start telegram bot")
-	bp.b.Start()
+	go bp.b.Start()
 	return nil
 }
 
@@ -54,6 +72,8 @@ func (bp *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Pl
 		switch dp := d.(type) {
 		case *logrus.Entry:
 			bp.Logger = dp.WithField("component", "telegram-bot")
+		case *usermanager.Plugin:
+			bp.dependsOn = append(bp.dependsOn, dp.Name())
 		}
 	}
 	l := bp.Logger
@@ -67,17 +87,27 @@ func (bp *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Pl
 		Token:  bp.conf.APIkey,
 		Poller: &tb.LongPoller{Timeout: 10 * time.Second},
 	})
-	bp.b = b
-
 	if err != nil {
-		l.WithError(err).Fatal("could not boot telegram bot")
+		l.WithError(err).Error("could not boot telegram bot")
+		bp.bootErr = fmt.Errorf("telegram bot: %w", err)
+		bp.conf.Enable = false
+		return bp
 	}
+	bp.b = b
 
 	l.Debug("done booting up...")
 
 	return bp
 }
 
+func (bp *Plugin) DependsOn() []string {
+	return bp.dependsOn
+}
+
+func (bp *Plugin) BootError() error {
+	return bp.bootErr
+}
+
 func (bp *Plugin) Close() error {
 	if !bp.IsEnabled() {
 		return nil
@@ -2,7 +2,9 @@ package usermanager
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"reflect"
 
 	"github.com/siklol/zinc/plugins/postgres"
 
@@ -14,11 +16,17 @@ import (
 
 const Name = "usermanager"
 
+var (
+	ErrNoDatabase = errors.New("postgres connection not available")
+)
+
 type (
 	Plugin struct {
-		logger *log.Entry
-		conf   Config
-		db     *sqlx.DB
+		logger    *log.Entry
+		conf      Config
+		db        *sqlx.DB
+		dependsOn []string
+		bootErr   error
 	}
 
 	Config struct {
@@ -34,6 +42,19 @@ type (
 	}
 )
 
+func init() {
+	plugins.Register(plugins.Registration{
+		Name:      Name,
+		ConfigKey: "usermanager",
+		Config:    func() interface{} { return &Config{} },
+		New:       func() plugins.Plugin { return New() },
+		Dependencies: []reflect.Type{
+			plugins.TypeOf[*logrus.Entry](),
+			plugins.TypeOf[*postgres.Plugin](),
+		},
+	})
+}
+
 func New() *Plugin {
 	return &Plugin{}
 }
@@ -53,10 +74,10 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	for _, d := range dependencies {
 		switch dp := d.(type) {
 		case *postgres.Plugin:
-			if !dp.IsEnabled() {
-				return p
+			p.dependsOn = append(p.dependsOn, dp.Name())
+			if dp.IsEnabled() {
+				p.db = dp.DB()
 			}
-			p.db = dp.DB()
 		case *logrus.Entry:
 			p.logger = dp
 		}
@@ -69,8 +90,18 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 		return p
 	}
 
+	if p.db == nil {
+		p.logger.Error("postgres connection not available. failing")
+		p.bootErr = ErrNoDatabase
+		p.conf.Enable = false
+		return p
+	}
+
 	if err := p.CreateTable(p.conf.Table); err != nil {
-		p.logger.WithError(err).Fatalf("could not create table %s.", p.conf.Table)
+		p.logger.WithError(err).Errorf("could not create table %s.", p.conf.Table)
+		p.bootErr = fmt.Errorf("create table %s: %w", p.conf.Table, err)
+		p.conf.Enable = false
+		return p
 	}
 
 	p.logger.Debug("usermanager initialized")
@@ -78,6 +109,14 @@ func (p *Plugin) Boot(conf interface{}, dependencies ...interface{}) plugins.Plu
 	return p
 }
 
+func (p *Plugin) BootError() error {
+	return p.bootErr
+}
+
+func (p *Plugin) DependsOn() []string {
+	return p.dependsOn
+}
+
 func (p *Plugin) IsEnabled() bool {
 	return p.conf.Enable
 }
@@ -59,6 +59,26 @@ func (bp *Plugin) LoadYamlConfig(name string, conf interface{}) error {
 		panic(err)
 	}
 
+	if err := bp.DecodeYamlConfig(name, conf); err != nil {
+		return err
+	}
+
+	if err := env.Parse(conf); err != nil {
+		bp.Logger.WithError(err).Error("could not parse environment variables")
+		return fmt.Errorf("parse env: %w", err)
+	}
+
+	if err := plugins.ResolveSecrets(conf); err != nil {
+		bp.Logger.WithError(err).Error("could not resolve secrets")
+		return err
+	}
+
+	return nil
+}
+
+// DecodeYamlConfig unmarshals the yaml file into conf without applying
+// defaults or environment variables.
+func (bp *Plugin) DecodeYamlConfig(name string, conf interface{}) error {
 	data, err := ioutil.ReadFile(name)
 	if err != nil {
 		return err
@@ -69,9 +89,5 @@ func (bp *Plugin) LoadYamlConfig(name string, conf interface{}) error {
 		return err
 	}
 
-	if err := env.Parse(conf); err != nil {
-		fmt.Printf("%+v\n", err)
-	}
-
-	return err
+	return nil
 }